@@ -0,0 +1,360 @@
+package peanut
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var _ Writer = &ArchiveWriter{}
+
+// ArchiveFormat is the format of the archive written by ArchiveWriter.
+type ArchiveFormat int
+
+// Archive formats supported by ArchiveWriter.
+const (
+	Zip     ArchiveFormat = iota // Zip writes a zip archive, with extension ".zip".
+	TarGzip                      // TarGzip writes a gzipped tar archive, with extension ".tar.gz".
+)
+
+// Extension returns the filename extension used for archives of format f.
+func (f ArchiveFormat) Extension() string {
+	switch f {
+	case Zip:
+		return ".zip"
+	case TarGzip:
+		return ".tar.gz"
+	}
+	return ""
+}
+
+// ArchiveManifestName is the name of the manifest member,
+// written to archives when ArchiveWriter.Manifest is set.
+const ArchiveManifestName = "manifest.json"
+
+// ArchiveWriter writes records to a single archive file,
+// containing a member for each file written by a file-based
+// writer, such as CSVWriter or JSONLWriter.
+//
+// The archive's filename is derived accordingly:
+//  filename + ".zip"
+//  filename + ".tar.gz"
+//
+// Records are written to the writer returned by NewWriter,
+// which is called when the first record is written, with
+// the prefix to use when building its output filenames.
+// The prefix locates the writer's files in a temporary
+// directory, and files written there become members of the
+// archive, named by the remainder of their filenames:
+//  w := peanut.NewArchiveWriter("/some/path/my-data", peanut.Zip,
+//  	func(prefix string) peanut.Writer {
+//  		return peanut.NewCSVWriter(prefix, "")
+//  	})
+//  // Members will be Shape.csv and Color.csv.
+//
+// If Manifest is set, the archive also holds a JSON member,
+// named ArchiveManifestName, which lists the name, size and
+// SHA-256 digest of every other member.
+//
+// The caller must call Close on successful completion
+// of all writing, to ensure the archive is properly written
+// to disk. The archive is only written once the writer
+// returned by NewWriter has closed successfully.
+//
+// In the event of an error or cancellation, the
+// caller must call Cancel before quiting, to ensure
+// closure and cleanup of any partially written files.
+type ArchiveWriter struct {
+	NewWriter func(prefix string) Writer // NewWriter returns the writer whose output files are archived.
+	Manifest  bool                       // Manifest adds a manifest member to the archive.
+	filename  string
+	format    ArchiveFormat
+	dir       string // dir holds the output files of w.
+	w         Writer
+	closed    bool
+}
+
+// NewArchiveWriter returns a new ArchiveWriter, writing an archive
+// of the given format, using the given filename + format.Extension()
+// as its output file, and archiving the output files of the writer
+// returned by newWriter.
+//
+// See ArchiveWriter (above) for details.
+func NewArchiveWriter(filename string, format ArchiveFormat, newWriter func(prefix string) Writer) *ArchiveWriter {
+	w := ArchiveWriter{
+		NewWriter: newWriter,
+		filename:  filename + format.Extension(),
+		format:    format,
+	}
+	return &w
+}
+
+// Write is called to persist records.
+// Each record is written by the writer
+// returned by NewWriter.
+func (w *ArchiveWriter) Write(x interface{}) error {
+	if w.closed {
+		return ErrClosedWriter
+	}
+	if w.w == nil {
+		if w.format.Extension() == "" {
+			return fmt.Errorf("peanut: unknown archive format %d", w.format)
+		}
+		dir, err := ioutil.TempDir("", "atomic-")
+		if err != nil {
+			return err
+		}
+		w.dir = dir
+		w.w = w.NewWriter(dir + string(filepath.Separator))
+	}
+	return w.w.Write(x)
+}
+
+// archiveMember is a file to be written as a member of an archive.
+type archiveMember struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	path   string
+}
+
+// archiveManifest is the content of the manifest member.
+type archiveManifest struct {
+	Created time.Time        `json:"created"`
+	Members []*archiveMember `json:"members"`
+}
+
+// Close closes the writer returned by NewWriter,
+// and writes its output files to the archive.
+//
+// Calling Close after a previous call to
+// Cancel is safe, and always results in a no-op.
+func (w *ArchiveWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.w != nil {
+		defer os.RemoveAll(w.dir)
+		err := w.w.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	members, err := w.members()
+	if err != nil {
+		return err
+	}
+
+	// log.Printf("Writing archive %s", w.filename)
+
+	file, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		return err
+	}
+
+	var cerr error
+	err = w.writeArchive(file, members)
+	if err != nil {
+		cerr = err
+	}
+
+	// Chmod the file world-readable (ioutil.TempFile creates files with
+	// mode 0600) before renaming.
+	err = file.Chmod(0644)
+	if err != nil {
+		cerr = err
+	}
+
+	// fsync(2) after fchmod(2) orders writes as per
+	// https://lwn.net/Articles/270891/.
+	file.Sync()
+
+	err = file.Close()
+	if err != nil {
+		cerr = err
+	}
+
+	if cerr != nil {
+		os.Remove(file.Name())
+		return cerr
+	}
+
+	return os.Rename(file.Name(), w.filename)
+}
+
+// members returns the files written to w.dir,
+// in lexical order, as archive members.
+func (w *ArchiveWriter) members() ([]*archiveMember, error) {
+	var members []*archiveMember
+	if w.dir == "" {
+		return members, nil
+	}
+	err := filepath.Walk(w.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		name, err := filepath.Rel(w.dir, path)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		if w.Manifest && name == ArchiveManifestName {
+			return fmt.Errorf("peanut: archive member %s conflicts with manifest", name)
+		}
+		members = append(members, &archiveMember{Name: name, Size: info.Size(), path: path})
+		return nil
+	})
+	return members, err
+}
+
+// writeArchive writes an archive of the given members to dst.
+func (w *ArchiveWriter) writeArchive(dst io.Writer, members []*archiveMember) error {
+	now := time.Now()
+
+	var aw archiver
+	switch w.format {
+	case Zip:
+		aw = &zipArchiver{zw: zip.NewWriter(dst)}
+	case TarGzip:
+		gw := gzip.NewWriter(dst)
+		aw = &tarArchiver{gw: gw, tw: tar.NewWriter(gw)}
+	default:
+		return fmt.Errorf("peanut: unknown archive format %d", w.format)
+	}
+
+	for _, m := range members {
+		err := writeArchiveMember(aw, m, now)
+		if err != nil {
+			aw.Close()
+			return err
+		}
+	}
+
+	if w.Manifest {
+		b, err := json.MarshalIndent(archiveManifest{Created: now.UTC(), Members: members}, "", "  ")
+		if err != nil {
+			aw.Close()
+			return err
+		}
+		b = append(b, '\n')
+		mw, err := aw.Create(ArchiveManifestName, int64(len(b)), now)
+		if err == nil {
+			_, err = mw.Write(b)
+		}
+		if err != nil {
+			aw.Close()
+			return err
+		}
+	}
+
+	return aw.Close()
+}
+
+// writeArchiveMember copies the file of m to a new member of aw,
+// recording its SHA-256 digest in m.
+func writeArchiveMember(aw archiver, m *archiveMember, modified time.Time) error {
+	f, err := os.Open(m.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	mw, err := aw.Create(m.Name, m.Size, modified)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(mw, h), f)
+	if err != nil {
+		return err
+	}
+	if n != m.Size {
+		return errors.New("peanut: archive member " + m.Name + " changed size")
+	}
+	m.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// Cancel should be called in the event of an error occurring,
+// to properly close and delete any partially written files.
+func (w *ArchiveWriter) Cancel() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.w == nil {
+		return nil
+	}
+	err := w.w.Cancel()
+	rerr := os.RemoveAll(w.dir)
+	if err != nil {
+		return err
+	}
+	return rerr
+}
+
+// archiver writes members to an archive.
+type archiver interface {
+	// Create adds a member to the archive, returning
+	// a writer to which its content must be written.
+	Create(name string, size int64, modified time.Time) (io.Writer, error)
+	// Close finishes writing the archive.
+	Close() error
+}
+
+type zipArchiver struct {
+	zw *zip.Writer
+}
+
+func (a *zipArchiver) Create(name string, size int64, modified time.Time) (io.Writer, error) {
+	return a.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+}
+
+func (a *zipArchiver) Close() error {
+	return a.zw.Close()
+}
+
+type tarArchiver struct {
+	gw *gzip.Writer
+	tw *tar.Writer
+}
+
+func (a *tarArchiver) Create(name string, size int64, modified time.Time) (io.Writer, error) {
+	err := a.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  modified,
+	})
+	return a.tw, err
+}
+
+func (a *tarArchiver) Close() error {
+	err := a.tw.Close()
+	if cerr := a.gw.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
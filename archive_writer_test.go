@@ -0,0 +1,218 @@
+package peanut_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+// readZipMembers returns the content of each member of the named zip archive.
+func readZipMembers(name string) ([]string, map[string]string) {
+	zr, err := zip.OpenReader(name)
+	Expect(err).To(BeNil())
+	defer zr.Close()
+	var names []string
+	members := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		Expect(err).To(BeNil())
+		b, err := ioutil.ReadAll(r)
+		Expect(err).To(BeNil())
+		r.Close()
+		names = append(names, f.Name)
+		members[f.Name] = string(b)
+	}
+	return names, members
+}
+
+// readTarGzipMembers returns the content of each member of the named tar.gz archive.
+func readTarGzipMembers(name string) ([]string, map[string]string) {
+	f, err := os.Open(name)
+	Expect(err).To(BeNil())
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	Expect(err).To(BeNil())
+	tr := tar.NewReader(gr)
+	var names []string
+	members := make(map[string]string)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		Expect(err).To(BeNil())
+		b, err := ioutil.ReadAll(tr)
+		Expect(err).To(BeNil())
+		names = append(names, h.Name)
+		members[h.Name] = string(b)
+	}
+	return names, members
+}
+
+var _ = Describe("ArchiveWriter", func() {
+
+	expectedOutput1 := "foo_string,foo_int\n" +
+		"test 1,1\n" +
+		"test 2,2\n" +
+		"test 3,3\n"
+
+	expectedOutput2 := `{"bar_int":1,"bar_string":"test 1"}` + "\n" +
+		`{"bar_int":2,"bar_string":"test 2"}` + "\n" +
+		`{"bar_int":3,"bar_string":"test 3"}` + "\n"
+
+	newCSVFn := func(prefix string) peanut.Writer {
+		return peanut.NewCSVWriter(prefix, "-data")
+	}
+
+	AfterEach(func() {
+		os.Remove("./test/output-archive.zip")
+		os.Remove("./test/output-archive.tar.gz")
+	})
+
+	It("should write a zip archive with a member for each record type", func() {
+		w := peanut.NewArchiveWriter("./test/output-archive", peanut.Zip, newCSVFn)
+
+		err := w.Write(testOutputFoo[0])
+		Expect(err).To(BeNil())
+		Expect("./test/output-archive.zip").ToNot(BeAnExistingFile())
+
+		for i := range testOutputFoo[1:] {
+			err = w.Write(testOutputFoo[i+1])
+			Expect(err).To(BeNil())
+		}
+		for i := range testOutputBar {
+			err = w.Write(testOutputBar[i])
+			Expect(err).To(BeNil())
+		}
+		err = w.Close()
+		Expect(err).To(BeNil())
+
+		// Calling Cancel after Close should be a no-op.
+		err = w.Cancel()
+		Expect(err).To(BeNil())
+
+		names, members := readZipMembers("./test/output-archive.zip")
+		Expect(names).To(Equal([]string{"Bar-data.csv", "Foo-data.csv"}))
+		Expect(members["Foo-data.csv"]).To(Equal(expectedOutput1))
+	})
+
+	It("should write a tar.gz archive of any file-based writer, with a manifest", func() {
+		w := peanut.NewArchiveWriter("./test/output-archive", peanut.TarGzip, func(prefix string) peanut.Writer {
+			return peanut.MultiWriter(
+				peanut.NewCSVWriter(prefix, ""),
+				peanut.NewJSONLWriter(prefix, ""),
+			)
+		})
+		w.Manifest = true
+
+		testWritesAndCloseSequential(w)
+
+		names, members := readTarGzipMembers("./test/output-archive.tar.gz")
+		Expect(names).To(Equal([]string{
+			"Bar.csv", "Bar.jsonl",
+			"Baz.csv", "Baz.jsonl",
+			"Foo.csv", "Foo.jsonl",
+			peanut.ArchiveManifestName,
+		}))
+		Expect(members["Foo.csv"]).To(Equal(expectedOutput1))
+		Expect(members["Bar.jsonl"]).To(Equal(expectedOutput2))
+
+		var manifest struct {
+			Members []struct {
+				Name   string `json:"name"`
+				Size   int64  `json:"size"`
+				SHA256 string `json:"sha256"`
+			} `json:"members"`
+		}
+		err := json.Unmarshal([]byte(members[peanut.ArchiveManifestName]), &manifest)
+		Expect(err).To(BeNil())
+		Expect(manifest.Members).To(HaveLen(6))
+		for _, m := range manifest.Members {
+			sum := sha256.Sum256([]byte(members[m.Name]))
+			Expect(m.Size).To(Equal(int64(len(members[m.Name]))))
+			Expect(m.SHA256).To(Equal(hex.EncodeToString(sum[:])))
+		}
+	})
+
+	It("should archive compressed members", func() {
+		w := peanut.NewArchiveWriter("./test/output-archive", peanut.Zip, func(prefix string) peanut.Writer {
+			cw := peanut.NewCSVWriter(prefix, "")
+			cw.Compression = peanut.Gzip
+			return cw
+		})
+
+		testWritesAndCloseSequential(w)
+
+		names, members := readZipMembers("./test/output-archive.zip")
+		Expect(names).To(Equal([]string{"Bar.csv.gz", "Baz.csv.gz", "Foo.csv.gz"}))
+		gr, err := gzip.NewReader(strings.NewReader(members["Foo.csv.gz"]))
+		Expect(err).To(BeNil())
+		b, err := ioutil.ReadAll(gr)
+		Expect(err).To(BeNil())
+		Expect(string(b)).To(Equal(expectedOutput1))
+	})
+
+	It("should write an empty archive when no records are written", func() {
+		w := peanut.NewArchiveWriter("./test/output-archive", peanut.Zip, newCSVFn)
+		w.Manifest = true
+
+		err := w.Close()
+		Expect(err).To(BeNil())
+
+		names, _ := readZipMembers("./test/output-archive.zip")
+		Expect(names).To(Equal([]string{peanut.ArchiveManifestName}))
+	})
+
+	It("should not write anything when structs are written and cancel is called", func() {
+		w := peanut.NewArchiveWriter("./test/output-archive", peanut.Zip, newCSVFn)
+
+		testWritesAndCancel(w)
+
+		Expect("./test/output-archive.zip").ToNot(BeAnExistingFile())
+	})
+
+	It("should return an error when Write is called after Close", func() {
+		w := peanut.NewArchiveWriter("./test/output-archive", peanut.TarGzip, newCSVFn)
+
+		testWriteAfterClose(w)
+	})
+
+	It("should return an error when the path is bad", func() {
+		w := peanut.NewArchiveWriter("./no-such-location/output-bogus", peanut.Zip, newCSVFn)
+
+		err := w.Write(testOutputFoo[0])
+		Expect(err).To(BeNil())
+
+		err = w.Close()
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("should return an error when a member conflicts with the manifest", func() {
+		w := peanut.NewArchiveWriter("./test/output-archive", peanut.Zip, func(prefix string) peanut.Writer {
+			jw := peanut.NewJSONWriter(prefix, "")
+			jw.NameFunc = func(reflect.Type) string { return "manifest" }
+			return jw
+		})
+		w.Manifest = true
+
+		err := w.Write(testOutputFoo[0])
+		Expect(err).To(BeNil())
+
+		err = w.Close()
+		Expect(err).ToNot(BeNil())
+		Expect("./test/output-archive.zip").ToNot(BeAnExistingFile())
+	})
+})
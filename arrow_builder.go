@@ -0,0 +1,179 @@
+package peanut
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+)
+
+// kindToArrowType maps the supported kinds to Arrow datatypes.
+var kindToArrowType = map[reflect.Kind]arrow.DataType{
+	reflect.String:  arrow.BinaryTypes.String,
+	reflect.Bool:    arrow.FixedWidthTypes.Boolean,
+	reflect.Float64: arrow.PrimitiveTypes.Float64,
+	reflect.Float32: arrow.PrimitiveTypes.Float32,
+	reflect.Int8:    arrow.PrimitiveTypes.Int8,
+	reflect.Int16:   arrow.PrimitiveTypes.Int16,
+	reflect.Int32:   arrow.PrimitiveTypes.Int32,
+	reflect.Int64:   arrow.PrimitiveTypes.Int64,
+	reflect.Int:     arrow.PrimitiveTypes.Int64,
+	reflect.Uint8:   arrow.PrimitiveTypes.Uint8,
+	reflect.Uint16:  arrow.PrimitiveTypes.Uint16,
+	reflect.Uint32:  arrow.PrimitiveTypes.Uint32,
+	reflect.Uint64:  arrow.PrimitiveTypes.Uint64,
+	reflect.Uint:    arrow.PrimitiveTypes.Uint64,
+}
+
+// arrowType returns the Arrow datatype used for fields of type t,
+// written by a writer of the given format. Times are written as UTC
+// timestamps with microsecond precision, as by AvroWriter, covering
+// all the years of time.Time, unless formatted is true, in which case
+// they are written as text. Durations are written with nanosecond precision, as plain
+// integers for Parquet, which has no duration type.
+func arrowType(t reflect.Type, format string, formatted bool) arrow.DataType {
+	t = columnType(t, format)
+	switch {
+	case t == timeType && formatted:
+		return arrow.BinaryTypes.String
+	case t == timeType:
+		return arrow.FixedWidthTypes.Timestamp_us
+	case t == durationType && format == formatParquet:
+		return arrow.PrimitiveTypes.Int64
+	case t == durationType:
+		return arrow.FixedWidthTypes.Duration_ns
+	}
+	// We ensure kindToArrowType has necessary entries using a test,
+	// so no need to check for missing entries here.
+	return kindToArrowType[t.Kind()]
+}
+
+// arrowSchema returns the Arrow schema for records of plan p,
+// written by a writer of the given format.
+func arrowSchema(p *typePlan, format string) *arrow.Schema {
+	fields := make([]arrow.Field, len(p.fields))
+	for i, f := range p.fields {
+		fields[i] = arrow.Field{
+			Name:     f.header,
+			Type:     arrowType(f.typ, format, f.formatted),
+			Nullable: f.nullable,
+		}
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+// arrowBuilder buffers records of a single type into Arrow record batches.
+type arrowBuilder struct {
+	plan   *typePlan
+	rb     *array.RecordBuilder
+	values []interface{} // values is reused for each record appended.
+	rows   int           // rows is the number of rows buffered.
+}
+
+func newArrowBuilder(p *typePlan, format string) *arrowBuilder {
+	return &arrowBuilder{
+		plan: p,
+		rb:   array.NewRecordBuilder(memory.DefaultAllocator, arrowSchema(p, format)),
+	}
+}
+
+// Schema returns the schema of the records built.
+func (b *arrowBuilder) Schema() *arrow.Schema {
+	return b.rb.Schema()
+}
+
+// Append buffers the record x as a new row.
+func (b *arrowBuilder) Append(x interface{}) error {
+	var err error
+	b.values, err = b.plan.values(x, b.values[:0])
+	if err != nil {
+		return err
+	}
+	for i, val := range b.values {
+		f := b.plan.fields[i]
+		if val == nil {
+			b.rb.Field(i).AppendNull()
+			continue
+		}
+		if f.formatted {
+			val = formatValue(val, f.layout)
+		}
+		if err := appendArrowValue(b.rb.Field(i), val); err != nil {
+			return b.plan.errorf(f, err)
+		}
+	}
+	b.rows++
+	return nil
+}
+
+// NewRecord returns a record batch holding the buffered rows,
+// and resets the builder. The caller must release the record.
+func (b *arrowBuilder) NewRecord() arrow.Record {
+	b.rows = 0
+	return b.rb.NewRecord()
+}
+
+// Release releases the builder's memory.
+func (b *arrowBuilder) Release() {
+	b.rb.Release()
+}
+
+// appendArrowValue appends the (non-nil) value val to the Arrow builder ab,
+// returning an error if the kind of val does not match the column's type.
+func appendArrowValue(ab array.Builder, val interface{}) error {
+	switch val := val.(type) {
+	case time.Time:
+		if ab, ok := ab.(*array.TimestampBuilder); ok {
+			ab.Append(arrow.Timestamp(val.UnixMicro()))
+			return nil
+		}
+		return fmt.Errorf("cannot write %T to Arrow %s column", val, ab.Type())
+	case time.Duration:
+		switch ab := ab.(type) {
+		case *array.DurationBuilder:
+			ab.Append(arrow.Duration(val))
+			return nil
+		case *array.Int64Builder:
+			// Parquet has no duration type.
+			ab.Append(int64(val))
+			return nil
+		}
+		return fmt.Errorf("cannot write %T to Arrow %s column", val, ab.Type())
+	}
+	if dt, ok := kindToArrowType[reflect.ValueOf(val).Kind()]; !ok || !arrow.TypeEqual(dt, ab.Type()) {
+		return fmt.Errorf("cannot write %T to Arrow %s column", val, ab.Type())
+	}
+	v := builtinValue(val)
+	switch ab := ab.(type) {
+	case *array.StringBuilder:
+		ab.Append(v.(string))
+	case *array.BooleanBuilder:
+		ab.Append(v.(bool))
+	case *array.Float32Builder:
+		ab.Append(v.(float32))
+	case *array.Float64Builder:
+		ab.Append(v.(float64))
+	case *array.Int8Builder:
+		ab.Append(int8(v.(int64)))
+	case *array.Int16Builder:
+		ab.Append(int16(v.(int64)))
+	case *array.Int32Builder:
+		ab.Append(int32(v.(int64)))
+	case *array.Int64Builder:
+		ab.Append(v.(int64))
+	case *array.Uint8Builder:
+		ab.Append(uint8(v.(uint64)))
+	case *array.Uint16Builder:
+		ab.Append(uint16(v.(uint64)))
+	case *array.Uint32Builder:
+		ab.Append(uint32(v.(uint64)))
+	case *array.Uint64Builder:
+		ab.Append(v.(uint64))
+	default:
+		return fmt.Errorf("unsupported Arrow builder %T for %T", ab, val)
+	}
+	return nil
+}
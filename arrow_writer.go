@@ -0,0 +1,244 @@
+package peanut
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+)
+
+var _ Writer = &ArrowWriter{}
+
+// DefaultArrowBatchSize is the number of rows written
+// to each record batch when ArrowWriter.BatchSize is zero.
+const DefaultArrowBatchSize = 64 * 1024
+
+// ArrowWriter writes records to Apache Arrow IPC files (also known
+// as Feather version 2 files), writing each record type to an
+// individual Arrow file automatically.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".arrow"
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Columns are named using the names extracted from the struct's
+// field tags, in the order that they appear within the struct,
+// and are typed according to the fields' types: strings as UTF-8
+// strings, integers as signed or unsigned integers of the same
+// width, floats as floats or doubles, bools as booleans, times
+// as UTC timestamps with microsecond precision (or as strings, if
+// their tag has a format option), and durations as durations with
+// nanosecond precision. Only nullable fields (pointers and sql.Null*
+// types) have nullable columns.
+//
+// Records are buffered in memory, and written as record batches
+// of BatchSize rows, which defaults to DefaultArrowBatchSize.
+//
+// The caller must call Close on successful completion
+// of all writing, to ensure buffers are flushed and
+// files are properly written to disk.
+//
+// In the event of an error or cancellation, the
+// caller must call Cancel before quiting, to ensure
+// closure and cleanup of any partially written files.
+type ArrowWriter struct {
+	*base
+	NameFunc      NameFunc // NameFunc names the output files, TypeName is used if nil.
+	BatchSize     int      // BatchSize is the number of rows in each record batch.
+	prefix        string
+	suffix        string
+	builderByType map[reflect.Type]*arrowFileBuilder
+}
+
+// NewArrowWriter returns a new ArrowWriter, using prefix
+// and suffix when building its output filenames.
+//
+// See ArrowWriter (above) for output filename details.
+func NewArrowWriter(prefix, suffix string) *ArrowWriter {
+	w := ArrowWriter{
+		base:          &base{format: formatArrow},
+		prefix:        prefix,
+		suffix:        suffix,
+		builderByType: make(map[reflect.Type]*arrowFileBuilder),
+	}
+	return &w
+}
+
+type arrowFileBuilder struct {
+	filename string
+	file     *os.File
+	fw       *ipc.FileWriter
+	ab       *arrowBuilder
+	size     int // size is the number of rows in each record batch.
+}
+
+func (w *ArrowWriter) register(x interface{}) (reflect.Type, error) {
+	// Register with base writer.
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t, nil
+	}
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, err
+	}
+	if len(w.base.tagsByType[t]) == 0 {
+		return t, nil
+	}
+
+	size := w.BatchSize
+	if size <= 0 {
+		size = DefaultArrowBatchSize
+	}
+
+	// log.Printf("Setting up Arrow writer for %s", t.Name())
+
+	name := w.prefix + w.nameByType[t] + w.suffix + ".arrow"
+	file, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		return nil, err
+	}
+	ab := newArrowBuilder(w.planByType[t], formatArrow)
+	fw, err := ipc.NewFileWriter(file, ipc.WithSchema(ab.Schema()), ipc.WithAllocator(memory.DefaultAllocator))
+	if err != nil {
+		ab.Release()
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	w.builderByType[t] = &arrowFileBuilder{filename: name, file: file, fw: fw, ab: ab, size: size}
+	return t, nil
+}
+
+// Write is called to persist records.
+// Each record is written to an individual row
+// in the corresponding output file, according to the
+// type of the given record.
+func (w *ArrowWriter) Write(x interface{}) error {
+	if w.closed {
+		return ErrClosedWriter
+	}
+	t, err := w.register(x)
+	if err != nil {
+		return err
+	}
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *ArrowWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	var err error
+	// log.Printf("WriteRecord for %s", t.Name())
+	b := w.builderByType[t]
+	err = b.ab.Append(x)
+	if err != nil {
+		return err
+	}
+	if b.ab.rows < b.size {
+		return nil
+	}
+	return b.flush()
+}
+
+// flush writes any buffered rows as a new record batch.
+func (b *arrowFileBuilder) flush() error {
+	if b.ab.rows == 0 {
+		return nil
+	}
+	rec := b.ab.NewRecord()
+	defer rec.Release()
+	return b.fw.Write(rec)
+}
+
+// Close flushes all buffers and writers,
+// and closes the output files.
+//
+// Calling Close after a previous call to
+// Cancel is safe, and always results in a no-op.
+func (w *ArrowWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	var rerr error
+	for _, b := range w.builderByType {
+		var cerr error
+		var err error
+
+		err = b.flush()
+		if err != nil {
+			cerr = err
+		}
+		b.ab.Release()
+
+		// Write the file footer.
+		err = b.fw.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		// Chmod the file world-readable (ioutil.TempFile creates files with
+		// mode 0600) before renaming.
+		err = b.file.Chmod(0644)
+		if err != nil {
+			cerr = err
+		}
+
+		// fsync(2) after fchmod(2) orders writes as per
+		// https://lwn.net/Articles/270891/.
+		b.file.Sync()
+
+		err = b.file.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		if cerr != nil {
+			rerr = cerr
+			continue
+		}
+
+		err = os.Rename(b.file.Name(), b.filename)
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// Cancel should be called in the event of an error occurring,
+// to properly close and delete any partially written files.
+func (w *ArrowWriter) Cancel() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	var rerr error
+	for _, b := range w.builderByType {
+		var err error
+
+		b.ab.Release()
+
+		err = b.file.Close()
+		if err != nil {
+			rerr = err
+		}
+
+		err = os.Remove(b.file.Name())
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
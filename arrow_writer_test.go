@@ -0,0 +1,228 @@
+package peanut_test
+
+import (
+	"os"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+var _ = Describe("ArrowWriter", func() {
+
+	newFn := func(suffix string) peanut.Writer {
+		w := peanut.NewArrowWriter("./test/output-", suffix)
+		return w
+	}
+
+	AfterEach(func() {
+		os.Remove("./test/output-Foo-sequential.arrow")
+		os.Remove("./test/output-Bar-sequential.arrow")
+		os.Remove("./test/output-Baz-sequential.arrow")
+		os.Remove("./test/output-Qux-sequential.arrow")
+		os.Remove("./test/output-Foo-interleave.arrow")
+		os.Remove("./test/output-Bar-interleave.arrow")
+		os.Remove("./test/output-Baz-interleave.arrow")
+		os.Remove("./test/output-Qux-interleave.arrow")
+		os.Remove("./test/output-Times-times.arrow")
+		os.Remove("./test/output-Nullable-nullable.arrow")
+		os.Remove("./test/output-Foo-batches.arrow")
+		os.Remove("./test/output-Account-valuers.arrow")
+		os.Remove("./test/output-Times-early.arrow")
+	})
+
+	expectedFoo := [][]string{
+		{"foo_string", "foo_int"},
+		{"test 1", "1"},
+		{"test 2", "2"},
+		{"test 3", "3"},
+	}
+
+	expectedBaz := [][]string{
+		{"baz_string", "baz_bool", "baz_float32", "baz_float64", "baz_int", "baz_int8", "baz_int16", "baz_int32", "baz_int64", "baz_uint", "baz_uint8", "baz_uint16", "baz_uint32", "baz_uint64"},
+		{"test 1", "true", "1.234", "9.876", "-12345", "-8", "-16", "-32", "-64", "12345", "8", "16", "32", "64"},
+	}
+
+	It("should write the correct data when sequential structs are written", func() {
+		w := newFn("-sequential")
+
+		testWritesAndCloseSequential(w)
+
+		output, schema, batches, err := readArrow("./test/output-Foo-sequential.arrow")
+		Expect(err).To(BeNil())
+		Expect(output).To(Equal(expectedFoo))
+		Expect(batches).To(Equal(1))
+		Expect(arrow.TypeEqual(schema.Field(1).Type, arrow.PrimitiveTypes.Int64)).To(BeTrue())
+		Expect(schema.Field(1).Nullable).To(BeFalse())
+
+		output, schema, _, err = readArrow("./test/output-Baz-sequential.arrow")
+		Expect(err).To(BeNil())
+		Expect(output).To(Equal(expectedBaz))
+		expectedTypes := []arrow.DataType{
+			arrow.BinaryTypes.String,
+			arrow.FixedWidthTypes.Boolean,
+			arrow.PrimitiveTypes.Float64,
+			arrow.PrimitiveTypes.Float64,
+			arrow.PrimitiveTypes.Int64,
+			arrow.PrimitiveTypes.Int8,
+			arrow.PrimitiveTypes.Int16,
+			arrow.PrimitiveTypes.Int32,
+			arrow.PrimitiveTypes.Int64,
+			arrow.PrimitiveTypes.Uint64,
+			arrow.PrimitiveTypes.Uint8,
+			arrow.PrimitiveTypes.Uint16,
+			arrow.PrimitiveTypes.Uint32,
+			arrow.PrimitiveTypes.Uint64,
+		}
+		for i, f := range schema.Fields() {
+			Expect(arrow.TypeEqual(f.Type, expectedTypes[i])).To(BeTrue(), f.Name)
+		}
+
+		_, err = os.Stat("./test/output-Qux-sequential.arrow")
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("should write the correct data when interleaved structs are written", func() {
+		w := newFn("-interleave")
+
+		testWritesAndCloseInterleaved(w)
+
+		output, _, _, err := readArrow("./test/output-Foo-interleave.arrow")
+		Expect(err).To(BeNil())
+		Expect(output).To(Equal(expectedFoo))
+
+		output, _, _, err = readArrow("./test/output-Baz-interleave.arrow")
+		Expect(err).To(BeNil())
+		Expect(output).To(Equal(expectedBaz))
+	})
+
+	It("should not leave any files when cancelled", func() {
+		w := newFn("-cancel")
+
+		testWritesAndCancel(w)
+
+		_, err := os.Stat("./test/output-Foo-cancel.arrow")
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("should return an error when Write is called after Close", func() {
+		testWriteAfterClose(newFn("-closed"))
+	})
+
+	It("should write times as timestamps, and durations", func() {
+		w := newFn("-times")
+
+		testWritesTimesAndClose(w)
+
+		output, schema, _, err := readArrow("./test/output-Times-times.arrow")
+		Expect(err).To(BeNil())
+		Expect(arrow.TypeEqual(schema.Field(1).Type, arrow.FixedWidthTypes.Timestamp_us)).To(BeTrue())
+		Expect(arrow.TypeEqual(schema.Field(2).Type, arrow.BinaryTypes.String)).To(BeTrue())
+		Expect(arrow.TypeEqual(schema.Field(3).Type, arrow.FixedWidthTypes.Duration_ns)).To(BeTrue())
+		Expect(output[1]).To(Equal([]string{"t1", "2021-04-19T13:45:30Z", "2021-04-19", "5400000000000ns"}))
+	})
+
+	It("should write zero times, and times before 1678", func() {
+		w := newFn("-early")
+		for _, x := range testOutputEarlyTimes {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		Expect(w.Close()).To(BeNil())
+
+		output, _, _, err := readArrow("./test/output-Times-early.arrow")
+		Expect(err).To(BeNil())
+		Expect(output[1:]).To(Equal([][]string{
+			{"t0", "0001-01-01T00:00:00Z", "0001-01-01", "0ns"},
+			{"t1", "1500-06-01T12:30:00.123456Z", "1500-06-01", "0ns"},
+		}))
+	})
+
+	It("should declare columns of driver.Valuer types by the kind of their values", func() {
+		w := newFn("-valuers")
+
+		testWritesValuersAndClose(w)
+
+		output, schema, _, err := readArrow("./test/output-Account-valuers.arrow")
+		Expect(err).To(BeNil())
+		Expect(arrow.TypeEqual(schema.Field(1).Type, arrow.BinaryTypes.String)).To(BeTrue())
+		Expect(arrow.TypeEqual(schema.Field(2).Type, arrow.PrimitiveTypes.Int64)).To(BeTrue())
+		Expect(output[1:]).To(Equal([][]string{{"a1", "active", "2"}}))
+	})
+
+	It("should write null values to nullable columns", func() {
+		w := newFn("-nullable")
+
+		testWritesNullableAndClose(w)
+
+		output, schema, _, err := readArrow("./test/output-Nullable-nullable.arrow")
+		Expect(err).To(BeNil())
+		Expect(schema.Field(0).Nullable).To(BeFalse())
+		for _, f := range schema.Fields()[1:] {
+			Expect(f.Nullable).To(BeTrue())
+		}
+		Expect(output[1]).To(Equal([]string{"n1", "1", "test 1", "test 1", "1", "2021-04-19T13:45:30Z", "1.5"}))
+		Expect(output[2]).To(Equal([]string{"n2", "(null)", "(null)", "(null)", "(null)", "(null)", "(null)"}))
+	})
+
+	It("should write record batches of the configured size", func() {
+		w := peanut.NewArrowWriter("./test/output-", "-batches")
+		w.BatchSize = 2
+		for _, x := range testOutputFoo {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		Expect(w.Close()).To(BeNil())
+
+		output, _, batches, err := readArrow("./test/output-Foo-batches.arrow")
+		Expect(err).To(BeNil())
+		Expect(batches).To(Equal(2))
+		Expect(output).To(Equal(expectedFoo))
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			w := peanut.NewArrowWriter("./no-such-location/output-bogus-", "")
+
+			testWriteBadType(w)
+		})
+	})
+})
+
+// readArrow returns the rows of the given Arrow IPC file as text,
+// headers first, its schema, and its number of record batches.
+func readArrow(filename string) ([][]string, *arrow.Schema, int, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer f.Close()
+	r, err := ipc.NewFileReader(f)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer r.Close()
+
+	var out [][]string
+	var headers []string
+	for _, f := range r.Schema().Fields() {
+		headers = append(headers, f.Name)
+	}
+	out = append(out, headers)
+	for i := 0; i < r.NumRecords(); i++ {
+		rec, err := r.Record(i)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		for j := 0; j < int(rec.NumRows()); j++ {
+			var row []string
+			for c := 0; c < int(rec.NumCols()); c++ {
+				row = append(row, rec.Column(c).ValueStr(j))
+			}
+			out = append(out, row)
+		}
+	}
+	return out, r.Schema(), r.NumRecords(), nil
+}
@@ -0,0 +1,361 @@
+package peanut
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+)
+
+var _ Writer = &AvroWriter{}
+
+// DefaultAvroBlockSize is the number of records written
+// to each block when AvroWriter.BlockSize is zero.
+const DefaultAvroBlockSize = 100
+
+// avroCodecs maps codec names to Avro compression codecs.
+var avroCodecs = map[string]ocf.CodecName{
+	"":        ocf.Null,
+	"null":    ocf.Null,
+	"deflate": ocf.Deflate,
+	"snappy":  ocf.Snappy,
+}
+
+// kindToAvroType maps the supported kinds to Avro primitive types.
+var kindToAvroType = map[reflect.Kind]avro.Type{
+	reflect.String:  avro.String,
+	reflect.Bool:    avro.Boolean,
+	reflect.Float64: avro.Double,
+	reflect.Float32: avro.Float,
+	reflect.Int8:    avro.Int,
+	reflect.Int16:   avro.Int,
+	reflect.Int32:   avro.Int,
+	reflect.Int64:   avro.Long,
+	reflect.Int:     avro.Long,
+	reflect.Uint8:   avro.Int,
+	reflect.Uint16:  avro.Int,
+	reflect.Uint32:  avro.Long,
+	reflect.Uint64:  avro.Long,
+	reflect.Uint:    avro.Long,
+}
+
+// AvroWriter writes records to Apache Avro Object Container Files,
+// writing each record type to an individual Avro file automatically.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".avro"
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Each file's schema is a record named after its type, with
+// fields named using the names extracted from the struct's
+// field tags, in the order that they appear within the struct.
+// Field types are derived from the struct fields' types:
+// strings as string, bools as boolean, floats as float or double,
+// integers as int or long (unsigned values too large for a long
+// result in an error), times as long with a logical type of
+// timestamp-micros (or as string, if their tag has a format option),
+// and durations as long nanoseconds. Nullable fields (pointers and
+// sql.Null* types) are unions of null and their type, defaulting
+// to null. Names must therefore be valid Avro names.
+//
+// Records are written in blocks of BlockSize records, which
+// defaults to DefaultAvroBlockSize.
+//
+// Blocks are compressed using the named Codec, which is
+// one of "null" (the default, no compression), "deflate"
+// or "snappy".
+//
+// The caller must call Close on successful completion
+// of all writing, to ensure buffers are flushed and
+// files are properly written to disk.
+//
+// In the event of an error or cancellation, the
+// caller must call Cancel before quiting, to ensure
+// closure and cleanup of any partially written files.
+type AvroWriter struct {
+	*base
+	NameFunc      NameFunc // NameFunc names the output files, TypeName is used if nil.
+	BlockSize     int      // BlockSize is the number of records in each block.
+	Codec         string   // Codec names the compression codec used.
+	prefix        string
+	suffix        string
+	builderByType map[reflect.Type]*avroBuilder
+}
+
+// NewAvroWriter returns a new AvroWriter, using prefix
+// and suffix when building its output filenames.
+//
+// See AvroWriter (above) for output filename details.
+func NewAvroWriter(prefix, suffix string) *AvroWriter {
+	w := AvroWriter{
+		base:          &base{format: formatAvro},
+		prefix:        prefix,
+		suffix:        suffix,
+		builderByType: make(map[reflect.Type]*avroBuilder),
+	}
+	return &w
+}
+
+type avroBuilder struct {
+	filename string
+	file     *os.File
+	enc      *ocf.Encoder
+	w        *avro.Writer  // w encodes each record, and is reused.
+	values   []interface{} // values is reused for each record written.
+}
+
+// avroSchema returns the Avro record schema, with the given name,
+// for records of plan p.
+func avroSchema(p *typePlan, name string) (*avro.RecordSchema, error) {
+	fields := make([]*avro.Field, len(p.fields))
+	for i, f := range p.fields {
+		var s avro.Schema
+		t := columnType(f.typ, formatAvro)
+		switch {
+		case t == timeType && f.formatted:
+			s = avro.NewPrimitiveSchema(avro.String, nil)
+		case t == timeType:
+			s = avro.NewPrimitiveSchema(avro.Long, avro.NewPrimitiveLogicalSchema(avro.TimestampMicros))
+		case t == durationType:
+			s = avro.NewPrimitiveSchema(avro.Long, nil)
+		default:
+			// We ensure kindToAvroType has necessary entries using a test,
+			// so no need to check for missing entries here.
+			s = avro.NewPrimitiveSchema(kindToAvroType[t.Kind()], nil)
+		}
+		var opts []avro.SchemaOption
+		if f.nullable {
+			u, err := avro.NewUnionSchema([]avro.Schema{avro.NewNullSchema(), s})
+			if err != nil {
+				return nil, err
+			}
+			s = u
+			opts = append(opts, avro.WithDefault(nil))
+		}
+		field, err := avro.NewField(f.header, s, opts...)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = field
+	}
+	return avro.NewRecordSchema(name, "", fields)
+}
+
+func (w *AvroWriter) register(x interface{}) (reflect.Type, error) {
+	// Register with base writer.
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t, nil
+	}
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, err
+	}
+	if len(w.base.tagsByType[t]) == 0 {
+		return t, nil
+	}
+
+	codec, ok := avroCodecs[w.Codec]
+	if !ok {
+		return nil, fmt.Errorf("peanut: unsupported Avro codec: %q", w.Codec)
+	}
+	size := w.BlockSize
+	if size <= 0 {
+		size = DefaultAvroBlockSize
+	}
+	schema, err := avroSchema(w.planByType[t], w.nameByType[t])
+	if err != nil {
+		return nil, fmt.Errorf("peanut: invalid Avro schema for %s: %w", t.Name(), err)
+	}
+
+	// log.Printf("Setting up Avro writer for %s", t.Name())
+
+	name := w.prefix + w.nameByType[t] + w.suffix + ".avro"
+	file, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		return nil, err
+	}
+	// Write the full schema, as the canonical form omits defaults.
+	enc, err := ocf.NewEncoderWithSchema(schema, file,
+		ocf.WithCodec(codec),
+		ocf.WithBlockLength(size),
+		ocf.WithSchemaMarshaler(ocf.FullSchemaMarshaler),
+	)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	w.builderByType[t] = &avroBuilder{
+		filename: name,
+		file:     file,
+		enc:      enc,
+		w:        avro.NewWriter(nil, 512),
+	}
+	return t, nil
+}
+
+// Write is called to persist records.
+// Each record is written to an individual block entry
+// in the corresponding output file, according to the
+// type of the given record.
+func (w *AvroWriter) Write(x interface{}) error {
+	if w.closed {
+		return ErrClosedWriter
+	}
+	t, err := w.register(x)
+	if err != nil {
+		return err
+	}
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *AvroWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	var err error
+	// log.Printf("WriteRecord for %s", t.Name())
+	b := w.builderByType[t]
+	b.values, err = p.values(x, b.values[:0])
+	if err != nil {
+		return err
+	}
+	b.w.Reset(nil)
+	for i, val := range b.values {
+		f := p.fields[i]
+		if f.nullable {
+			// Unions are encoded with the index of their branch.
+			if val == nil {
+				b.w.WriteLong(0)
+				continue
+			}
+			b.w.WriteLong(1)
+		}
+		if f.formatted {
+			val = formatValue(val, f.layout)
+		}
+		if err := writeAvroValue(b.w, val); err != nil {
+			return p.errorf(f, err)
+		}
+	}
+	_, err = b.enc.Write(b.w.Buffer())
+	return err
+}
+
+// writeAvroValue writes the (non-nil) value val to the Avro writer aw,
+// using the encoding of its type in the schema built by avroSchema.
+// Integers are written as longs, as Avro ints and longs share the
+// same variable-length zig-zag encoding.
+func writeAvroValue(aw *avro.Writer, val interface{}) error {
+	switch v := builtinValue(val).(type) {
+	case time.Time:
+		aw.WriteLong(v.UnixMicro())
+	case time.Duration:
+		aw.WriteLong(int64(v))
+	case string:
+		aw.WriteString(v)
+	case bool:
+		aw.WriteBool(v)
+	case float32:
+		aw.WriteFloat(v)
+	case float64:
+		aw.WriteDouble(v)
+	case int64:
+		aw.WriteLong(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return fmt.Errorf("value %d overflows Avro long", v)
+		}
+		aw.WriteLong(int64(v))
+	default:
+		return fmt.Errorf("unsupported type %T", val)
+	}
+	return nil
+}
+
+// Close flushes all buffers and writers,
+// and closes the output files.
+//
+// Calling Close after a previous call to
+// Cancel is safe, and always results in a no-op.
+func (w *AvroWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	var rerr error
+	for _, b := range w.builderByType {
+		var cerr error
+		var err error
+
+		// Write any remaining block.
+		err = b.enc.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		// Chmod the file world-readable (ioutil.TempFile creates files with
+		// mode 0600) before renaming.
+		err = b.file.Chmod(0644)
+		if err != nil {
+			cerr = err
+		}
+
+		// fsync(2) after fchmod(2) orders writes as per
+		// https://lwn.net/Articles/270891/.
+		b.file.Sync()
+
+		err = b.file.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		if cerr != nil {
+			rerr = cerr
+			continue
+		}
+
+		err = os.Rename(b.file.Name(), b.filename)
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// Cancel should be called in the event of an error occurring,
+// to properly close and delete any partially written files.
+func (w *AvroWriter) Cancel() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	var rerr error
+	for _, b := range w.builderByType {
+		var err error
+
+		err = b.file.Close()
+		if err != nil {
+			rerr = err
+		}
+
+		err = os.Remove(b.file.Name())
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
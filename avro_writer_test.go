@@ -0,0 +1,223 @@
+package peanut_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+
+	"github.com/hamba/avro/v2/ocf"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+var _ = Describe("AvroWriter", func() {
+
+	newFn := func(suffix string) peanut.Writer {
+		w := peanut.NewAvroWriter("./test/output-", suffix)
+		return w
+	}
+
+	AfterEach(func() {
+		os.Remove("./test/output-Foo-sequential.avro")
+		os.Remove("./test/output-Bar-sequential.avro")
+		os.Remove("./test/output-Baz-sequential.avro")
+		os.Remove("./test/output-Qux-sequential.avro")
+		os.Remove("./test/output-Foo-interleave.avro")
+		os.Remove("./test/output-Bar-interleave.avro")
+		os.Remove("./test/output-Baz-interleave.avro")
+		os.Remove("./test/output-Qux-interleave.avro")
+		os.Remove("./test/output-Times-times.avro")
+		os.Remove("./test/output-Nullable-nullable.avro")
+		os.Remove("./test/output-Foo-blocks.avro")
+	})
+
+	It("should write the correct data when sequential structs are written", func() {
+		w := newFn("-sequential")
+
+		testWritesAndCloseSequential(w)
+
+		schema, records, err := readAvro("./test/output-Foo-sequential.avro")
+		Expect(err).To(BeNil())
+		Expect(schema).To(Equal(`{"name":"Foo","type":"record","fields":[{"name":"foo_string","type":"string"},{"name":"foo_int","type":"long"}]}`))
+		Expect(records).To(Equal([]map[string]interface{}{
+			{"foo_string": "test 1", "foo_int": int64(1)},
+			{"foo_string": "test 2", "foo_int": int64(2)},
+			{"foo_string": "test 3", "foo_int": int64(3)},
+		}))
+
+		schema, records, err = readAvro("./test/output-Baz-sequential.avro")
+		Expect(err).To(BeNil())
+		Expect(schema).To(Equal(`{"name":"Baz","type":"record","fields":[` +
+			`{"name":"baz_string","type":"string"},{"name":"baz_bool","type":"boolean"},` +
+			`{"name":"baz_float32","type":"double"},{"name":"baz_float64","type":"double"},` +
+			`{"name":"baz_int","type":"long"},{"name":"baz_int8","type":"int"},` +
+			`{"name":"baz_int16","type":"int"},{"name":"baz_int32","type":"int"},` +
+			`{"name":"baz_int64","type":"long"},{"name":"baz_uint","type":"long"},` +
+			`{"name":"baz_uint8","type":"int"},{"name":"baz_uint16","type":"int"},` +
+			`{"name":"baz_uint32","type":"long"},{"name":"baz_uint64","type":"long"}]}`))
+		Expect(records).To(Equal([]map[string]interface{}{
+			{
+				"baz_string":  "test 1",
+				"baz_bool":    true,
+				"baz_float32": 1.234,
+				"baz_float64": 9.876,
+				"baz_int":     int64(-12345),
+				"baz_int8":    -8,
+				"baz_int16":   -16,
+				"baz_int32":   -32,
+				"baz_int64":   int64(-64),
+				"baz_uint":    int64(12345),
+				"baz_uint8":   8,
+				"baz_uint16":  16,
+				"baz_uint32":  int64(32),
+				"baz_uint64":  int64(64),
+			},
+		}))
+
+		_, err = os.Stat("./test/output-Qux-sequential.avro")
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("should write the correct data when interleaved structs are written", func() {
+		w := newFn("-interleave")
+
+		testWritesAndCloseInterleaved(w)
+
+		_, records, err := readAvro("./test/output-Foo-interleave.avro")
+		Expect(err).To(BeNil())
+		Expect(records).To(HaveLen(3))
+		Expect(records[2]).To(Equal(map[string]interface{}{"foo_string": "test 3", "foo_int": int64(3)}))
+
+		_, records, err = readAvro("./test/output-Baz-interleave.avro")
+		Expect(err).To(BeNil())
+		Expect(records).To(HaveLen(1))
+	})
+
+	It("should not leave any files when cancelled", func() {
+		w := newFn("-cancel")
+
+		testWritesAndCancel(w)
+
+		_, err := os.Stat("./test/output-Foo-cancel.avro")
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("should return an error when Write is called after Close", func() {
+		testWriteAfterClose(newFn("-closed"))
+	})
+
+	It("should write times as timestamps, and durations", func() {
+		w := newFn("-times")
+
+		testWritesTimesAndClose(w)
+
+		schema, records, err := readAvro("./test/output-Times-times.avro")
+		Expect(err).To(BeNil())
+		Expect(schema).To(ContainSubstring(`{"name":"time","type":{"type":"long","logicalType":"timestamp-micros"}}`))
+		Expect(schema).To(ContainSubstring(`{"name":"date","type":"string"}`))
+		Expect(schema).To(ContainSubstring(`{"name":"duration","type":"long"}`))
+		Expect(records).To(HaveLen(1))
+		Expect(records[0]["time"]).To(BeTemporally("==", testOutputTimes[0].Time))
+		Expect(records[0]["date"]).To(Equal("2021-04-19"))
+		Expect(records[0]["duration"]).To(Equal(int64(testOutputTimes[0].Duration)))
+	})
+
+	It("should write null values to nullable fields", func() {
+		w := newFn("-nullable")
+
+		testWritesNullableAndClose(w)
+
+		schema, records, err := readAvro("./test/output-Nullable-nullable.avro")
+		Expect(err).To(BeNil())
+		Expect(schema).To(ContainSubstring(`{"name":"id","type":"string"}`))
+		Expect(schema).To(ContainSubstring(`{"name":"int_ptr","type":["null","long"],"default":null}`))
+		Expect(records).To(HaveLen(2))
+		Expect(records[0]["int_ptr"]).To(Equal(int64(1)))
+		Expect(records[0]["string_ptr"]).To(Equal("test 1"))
+		Expect(records[0]["null_float"]).To(Equal(1.5))
+		Expect(records[1]).To(Equal(map[string]interface{}{
+			"id":          "n2",
+			"int_ptr":     nil,
+			"string_ptr":  nil,
+			"null_string": nil,
+			"null_int64":  nil,
+			"null_time":   nil,
+			"null_float":  nil,
+		}))
+	})
+
+	It("should write blocks of the configured size, using the configured codec", func() {
+		w := peanut.NewAvroWriter("./test/output-", "-blocks")
+		w.BlockSize = 2
+		w.Codec = "deflate"
+		for _, x := range testOutputFoo {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		Expect(w.Close()).To(BeNil())
+
+		f, err := os.Open("./test/output-Foo-blocks.avro")
+		Expect(err).To(BeNil())
+		defer f.Close()
+		dec, err := ocf.NewDecoder(f)
+		Expect(err).To(BeNil())
+		Expect(string(dec.Metadata()["avro.codec"])).To(Equal("deflate"))
+
+		// Each block is followed by the file's sync marker,
+		// which also ends the header.
+		data, err := ioutil.ReadFile("./test/output-Foo-blocks.avro")
+		Expect(err).To(BeNil())
+		sync := data[len(data)-16:]
+		Expect(bytes.Count(data, sync) - 1).To(Equal(2))
+
+		_, records, err := readAvro("./test/output-Foo-blocks.avro")
+		Expect(err).To(BeNil())
+		Expect(records).To(HaveLen(3))
+	})
+
+	It("should return an error for an unsupported codec", func() {
+		w := peanut.NewAvroWriter("./test/output-", "-codec")
+		w.Codec = "lzo"
+		err := w.Write(testOutputFoo[0])
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(MatchRegexp("lzo"))
+		Expect(w.Cancel()).To(BeNil())
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			w := peanut.NewAvroWriter("./no-such-location/output-bogus-", "")
+
+			testWriteBadType(w)
+		})
+	})
+})
+
+// readAvro returns the schema, as written in the file header,
+// and the records of the given Avro file.
+func readAvro(filename string) (string, []map[string]interface{}, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+	dec, err := ocf.NewDecoder(f)
+	if err != nil {
+		return "", nil, err
+	}
+	var records []map[string]interface{}
+	for dec.HasNext() {
+		var r map[string]interface{}
+		err := dec.Decode(&r)
+		if err != nil {
+			return "", nil, err
+		}
+		records = append(records, r)
+	}
+	if err := dec.Error(); err != nil {
+		return "", nil, err
+	}
+	return string(dec.Metadata()["avro.schema"]), records, nil
+}
@@ -1,6 +1,7 @@
 package peanut
 
 import (
+	"fmt"
 	"reflect"
 )
 
@@ -8,41 +9,87 @@ type base struct {
 	headersByType map[reflect.Type][]string       // headersByType is a list of headers for each struct type.
 	typesByType   map[reflect.Type][]reflect.Type // typesByType is a list of reflected field types for each struct type.
 	tagsByType    map[reflect.Type][]string       // tagsByType is a list of field tags for each struct type.
+	nameByType    map[reflect.Type]string         // nameByType is the output name for each struct type.
+	typeByName    map[string]reflect.Type         // typeByName is the struct type for each output name.
+	planByType    map[reflect.Type]*typePlan      // planByType is the encoding plan for each struct type.
+	format        string                          // format is the name of the output format, as passed to Marshaler.
 	closed        bool
 }
 
-// register a type and collect its metadata.
+// recordWriter is implemented by writers that can write records
+// of an already registered type using its plan, without looking up
+// the type of each record. TypedWriter uses it when available.
+type recordWriter interface {
+	Writer
+	// outputFormat returns the name of the writer's output format.
+	outputFormat() string
+	// isClosed reports whether the writer has been closed or cancelled.
+	isClosed() bool
+	// writeRecord writes the record x, of the registered type t,
+	// using the plan p. The writer must not be closed.
+	writeRecord(t reflect.Type, p *typePlan, x interface{}) error
+}
+
+func (w *base) outputFormat() string { return w.format }
+
+func (w *base) isClosed() bool { return w.closed }
+
+// register a type and collect its metadata,
+// using nameFn to name its output (or TypeName, if nameFn is nil).
 // If the type is a newly registered type
 // (has not been seen before),
 // return true. Otherwise return false.
-func (w *base) register(x interface{}) (reflect.Type, bool) {
+// An error is returned if the type's name is empty,
+// or is already used by another registered type.
+func (w *base) register(x interface{}, nameFn NameFunc) (reflect.Type, bool, error) {
 	// Lazy init.
 	if w.headersByType == nil {
 		w.headersByType = make(map[reflect.Type][]string)
 		w.typesByType = make(map[reflect.Type][]reflect.Type)
 		w.tagsByType = make(map[reflect.Type][]string)
+		w.nameByType = make(map[reflect.Type]string)
+		w.typeByName = make(map[string]reflect.Type)
+		w.planByType = make(map[reflect.Type]*typePlan)
 	}
 
 	t := baseType(x)
 	// Is this type already registered?
 	if _, ok := w.headersByType[t]; ok {
 		// Yes.
-		return t, false
+		return t, false, nil
+	}
+
+	if nameFn == nil {
+		nameFn = TypeName
+	}
+	name := nameFn(t)
+	if name == "" {
+		return nil, false, fmt.Errorf("peanut: empty name for %s", fullTypeName(t))
+	}
+	if u, ok := w.typeByName[name]; ok {
+		return nil, false, fmt.Errorf("peanut: name collision: %s and %s are both named %s", fullTypeName(u), fullTypeName(t), name)
+	}
+
+	p := planFor(t, w.format)
+	if p.err != nil {
+		return nil, false, p.err
 	}
 
 	var headers []string
 	var types []reflect.Type
 	var tags []string
 
-	reflectStructFields(x, func(name string, t reflect.Type, tag string) {
-		h := firstTagValue(tag)
-		headers = append(headers, h)
-		types = append(types, t)
-		tags = append(tags, tag)
-	})
+	for _, f := range p.fields {
+		headers = append(headers, f.header)
+		types = append(types, f.typ)
+		tags = append(tags, f.tag)
+	}
 
+	w.planByType[t] = p
 	w.headersByType[t] = headers
 	w.typesByType[t] = types
 	w.tagsByType[t] = tags
-	return t, true
+	w.nameByType[t] = name
+	w.typeByName[name] = t
+	return t, true, nil
 }
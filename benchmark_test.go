@@ -0,0 +1,58 @@
+package peanut_test
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/jimsmart/peanut"
+)
+
+func benchmarkWrite(b *testing.B, w peanut.Writer) {
+	defer w.Cancel()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := w.Write(&testOutputBaz[0])
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCSVWriter(b *testing.B) {
+	benchmarkWrite(b, peanut.NewCSVWriter(os.TempDir()+"/peanut-bench-", ""))
+}
+
+func BenchmarkTypedCSVWriter(b *testing.B) {
+	w, err := peanut.NewTypedWriter[*Baz](peanut.NewCSVWriter(os.TempDir()+"/peanut-bench-", ""))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer w.Cancel()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := w.Write(&testOutputBaz[0])
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONLWriter(b *testing.B) {
+	benchmarkWrite(b, peanut.NewJSONLWriter(os.TempDir()+"/peanut-bench-", ""))
+}
+
+func BenchmarkExcelWriter(b *testing.B) {
+	benchmarkWrite(b, peanut.NewExcelWriter(os.TempDir()+"/peanut-bench-", ""))
+}
+
+func BenchmarkSQLiteWriter(b *testing.B) {
+	benchmarkWrite(b, peanut.NewSQLiteWriter(os.TempDir()+"/peanut-bench"))
+}
+
+func BenchmarkLogWriter(b *testing.B) {
+	benchmarkWrite(b, &peanut.LogWriter{Logger: log.New(ioutil.Discard, "", 0)})
+}
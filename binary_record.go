@@ -0,0 +1,68 @@
+package peanut
+
+import (
+	"reflect"
+	"time"
+)
+
+// binaryHeader is the header record written at the start of each file
+// by MsgpackWriter and CBORWriter, describing the records that follow it.
+type binaryHeader struct {
+	Name     string   `msgpack:"name" cbor:"name"`
+	Columns  []string `msgpack:"columns" cbor:"columns"`
+	Types    []string `msgpack:"types" cbor:"types"`
+	Nullable []bool   `msgpack:"nullable" cbor:"nullable"`
+}
+
+// newBinaryHeader returns the header record for
+// the named type, encoded using plan p.
+func newBinaryHeader(name string, p *typePlan, format string) *binaryHeader {
+	h := &binaryHeader{Name: name}
+	for _, f := range p.fields {
+		h.Columns = append(h.Columns, f.header)
+		h.Types = append(h.Types, binaryTypeName(f, format))
+		h.Nullable = append(h.Nullable, f.nullable)
+	}
+	return h
+}
+
+// binaryTypeName returns the name of the type
+// of the values written for field f.
+func binaryTypeName(f *fieldPlan, format string) string {
+	t := columnType(f.typ, format)
+	switch {
+	case t == timeType && f.formatted:
+		return "string"
+	case t == timeType:
+		return "timestamp"
+	case t == durationType:
+		return "duration"
+	}
+	switch t.Kind() {
+	case reflect.Int:
+		return "int64"
+	case reflect.Uint:
+		return "uint64"
+	}
+	return t.Kind().String()
+}
+
+// binaryValue returns val converted to the built-in type of its kind,
+// for encoding as a native value by MsgpackWriter and CBORWriter.
+// Durations are converted to integer nanoseconds.
+func binaryValue(val interface{}, f *fieldPlan) interface{} {
+	switch v := builtinValue(val).(type) {
+	case time.Time:
+		if f.formatted {
+			return v.Format(f.layout)
+		}
+		return v
+	case time.Duration:
+		return int64(v)
+	case []byte:
+		return string(v)
+	case nil, string, bool, int64, uint64, float32, float64:
+		return v
+	}
+	return formatValue(val, f.layout)
+}
@@ -0,0 +1,256 @@
+package peanut
+
+// CBORWriter
+// - See https://www.rfc-editor.org/rfc/rfc8949
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+var _ Writer = &CBORWriter{}
+
+// cborEncMode is the encoding mode used by CBORWriter.
+var cborEncMode = func() cbor.EncMode {
+	em, err := cbor.EncOptions{
+		Time:    cbor.TimeRFC3339Nano,
+		TimeTag: cbor.EncTagRequired,
+	}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return em
+}()
+
+// CBORWriter writes records to CBOR files, writing
+// each record type to an individual file automatically.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".cbor"
+//
+// If Compression is set, files are compressed accordingly,
+// and named with its Extension, as in ".cbor.gz".
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Each file holds a sequence of CBOR data items. The first is
+// a header map, describing the records that follow it, with keys:
+//  name:     the name of the record type
+//  columns:  an array of column names
+//  types:    an array of column type names, such as "string",
+//            "int32", "float64", "bool", "timestamp" or "duration"
+//  nullable: an array of booleans, true for nullable columns
+// Each record is then written as an array of values, in column
+// order, using native CBOR types. Integers are written in
+// their most compact form, and floats at their own precision.
+// Times are written as RFC 3339 date/time strings (tag 0), with
+// nanosecond precision, unless they have a format option, durations
+// are written as integer nanoseconds, and null values are written
+// as null.
+//
+// The caller must call Close on successful completion
+// of all writing, to ensure buffers are flushed and
+// files are properly written to disk.
+//
+// In the event of an error or cancellation, the
+// caller must call Cancel before quiting, to ensure
+// closure and cleanup of any partially written files.
+type CBORWriter struct {
+	*base
+	NameFunc      NameFunc    // NameFunc names the output files, TypeName is used if nil.
+	Compression   Compression // Compression compresses the output files, which are uncompressed by default.
+	prefix        string
+	suffix        string
+	builderByType map[reflect.Type]*cborBuilder
+}
+
+// NewCBORWriter returns a new CBORWriter, using prefix
+// and suffix when building its output filenames.
+//
+// See CBORWriter (above) for output filename details.
+func NewCBORWriter(prefix, suffix string) *CBORWriter {
+	w := CBORWriter{
+		base:          &base{format: formatCBOR},
+		prefix:        prefix,
+		suffix:        suffix,
+		builderByType: make(map[reflect.Type]*cborBuilder),
+	}
+	return &w
+}
+
+type cborBuilder struct {
+	filename string
+	file     *os.File
+	zw       io.WriteCloser // zw compresses output written to file.
+	bw       *bufio.Writer
+	enc      *cbor.Encoder
+	values   []interface{} // values is reused for each record written.
+}
+
+func (w *CBORWriter) register(x interface{}) (reflect.Type, error) {
+	// Register with base writer.
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t, nil
+	}
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, err
+	}
+	if len(w.base.tagsByType[t]) == 0 {
+		return t, nil
+	}
+
+	// log.Printf("Setting up cbor.Encoder for %s", t.Name())
+
+	name := w.prefix + w.nameByType[t] + w.suffix + ".cbor" + w.Compression.Extension()
+	file, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		return nil, err
+	}
+	zw, err := w.Compression.newWriter(file)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	bw := bufio.NewWriter(zw)
+	enc := cborEncMode.NewEncoder(bw)
+	c := &cborBuilder{filename: name, file: file, zw: zw, bw: bw, enc: enc}
+	w.builderByType[t] = c
+
+	return t, enc.Encode(newBinaryHeader(w.nameByType[t], w.planByType[t], w.format))
+}
+
+// Write is called to persist records.
+// Each record is written as an individual array
+// in the corresponding output file, according to the
+// type of the given record.
+func (w *CBORWriter) Write(x interface{}) error {
+	if w.closed {
+		return ErrClosedWriter
+	}
+	t, err := w.register(x)
+	if err != nil {
+		return err
+	}
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *CBORWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	var err error
+	// log.Printf("WriteRecord for %s", t.Name())
+	c := w.builderByType[t]
+	c.values, err = p.values(x, c.values[:0])
+	if err != nil {
+		return err
+	}
+	for i, val := range c.values {
+		val = binaryValue(val, p.fields[i])
+		if tm, ok := val.(time.Time); ok {
+			// Tagged explicitly, as the encoder writes
+			// the zero time.Time as null.
+			val = cbor.Tag{Number: 0, Content: tm.Format(time.RFC3339Nano)}
+		}
+		c.values[i] = val
+	}
+	return c.enc.Encode(c.values)
+}
+
+// Close flushes all buffers and writers,
+// and closes the output files.
+//
+// Calling Close after a previous call to
+// Cancel is safe, and always results in a no-op.
+func (w *CBORWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, c := range w.builderByType {
+		var cerr error
+		var err error
+
+		err = c.bw.Flush()
+		if err != nil {
+			cerr = err
+		}
+
+		err = c.zw.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		// Chmod the file world-readable (ioutil.TempFile creates files with
+		// mode 0600) before renaming.
+		err = c.file.Chmod(0644)
+		if err != nil {
+			cerr = err
+		}
+
+		// fsync(2) after fchmod(2) orders writes as per
+		// https://lwn.net/Articles/270891/.
+		c.file.Sync()
+
+		err = c.file.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		if cerr != nil {
+			rerr = cerr
+			continue
+		}
+
+		err = os.Rename(c.file.Name(), c.filename)
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// Cancel should be called in the event of an error occurring,
+// to properly close and delete any partially written files.
+func (w *CBORWriter) Cancel() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, c := range w.builderByType {
+		var err error
+
+		// Release any resources held by the compressor.
+		c.zw.Close()
+
+		err = c.file.Close()
+		if err != nil {
+			rerr = err
+		}
+
+		err = os.Remove(c.file.Name())
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
@@ -0,0 +1,164 @@
+package peanut_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+type Single struct {
+	Value float32 `peanut:"value"`
+}
+
+var _ = Describe("CBORWriter", func() {
+
+	AfterEach(func() {
+		os.Remove("./test/output-Foo-sequential.cbor")
+		os.Remove("./test/output-Bar-sequential.cbor")
+		os.Remove("./test/output-Baz-sequential.cbor")
+		os.Remove("./test/output-Times-times.cbor")
+		os.Remove("./test/output-Times-exact.cbor")
+		os.Remove("./test/output-Nullable-nullable.cbor")
+		os.Remove("./test/output-Single-float32.cbor")
+	})
+
+	It("should write a header and native values when sequential structs are written", func() {
+		w := peanut.NewCBORWriter("./test/output-", "-sequential")
+
+		testWritesAndCloseSequential(w)
+
+		header, records, err := readCBOR("./test/output-Foo-sequential.cbor")
+		Expect(err).To(BeNil())
+		Expect(header).To(Equal(map[string]interface{}{
+			"name":     "Foo",
+			"columns":  []interface{}{"foo_string", "foo_int"},
+			"types":    []interface{}{"string", "int64"},
+			"nullable": []interface{}{false, false},
+		}))
+		Expect(records).To(Equal([][]interface{}{
+			{"test 1", uint64(1)},
+			{"test 2", uint64(2)},
+			{"test 3", uint64(3)},
+		}))
+
+		header, records, err = readCBOR("./test/output-Baz-sequential.cbor")
+		Expect(err).To(BeNil())
+		Expect(header["types"]).To(Equal([]interface{}{
+			"string", "bool", "float64", "float64", "int64", "int8", "int16", "int32",
+			"int64", "uint64", "uint8", "uint16", "uint32", "uint64",
+		}))
+		Expect(records).To(Equal([][]interface{}{
+			{"test 1", true, 1.234, 9.876, int64(-12345), int64(-8), int64(-16), int64(-32), int64(-64),
+				uint64(12345), uint64(8), uint64(16), uint64(32), uint64(64)},
+		}))
+
+		Expect("./test/output-Qux-sequential.cbor").ToNot(BeAnExistingFile())
+	})
+
+	It("should write times, durations and nulls", func() {
+		w := peanut.NewCBORWriter("./test/output-", "-times")
+
+		testWritesTimesAndClose(w)
+
+		header, records, err := readCBOR("./test/output-Times-times.cbor")
+		Expect(err).To(BeNil())
+		Expect(header["types"]).To(Equal([]interface{}{"string", "timestamp", "string", "duration"}))
+		Expect(records).To(HaveLen(1))
+		Expect(records[0][1].(time.Time).Equal(testOutputTimes[0].Time)).To(BeTrue())
+		Expect(records[0][2]).To(Equal("2021-04-19"))
+		Expect(records[0][3]).To(Equal(uint64(90 * time.Minute)))
+
+		w = peanut.NewCBORWriter("./test/output-", "-nullable")
+
+		testWritesNullableAndClose(w)
+
+		header, records, err = readCBOR("./test/output-Nullable-nullable.cbor")
+		Expect(err).To(BeNil())
+		Expect(header["nullable"]).To(Equal([]interface{}{false, true, true, true, true, true, true}))
+		Expect(records[1]).To(Equal([]interface{}{"n2", nil, nil, nil, nil, nil, nil}))
+	})
+
+	It("should write zero times, and times with nanoseconds, exactly", func() {
+		w := peanut.NewCBORWriter("./test/output-", "-exact")
+		tm := time.Date(2021, 4, 19, 13, 45, 30, 123456789, time.UTC)
+		Expect(w.Write(&Times{ID: "t0"})).To(BeNil())
+		Expect(w.Write(&Times{ID: "t1", Time: tm})).To(BeNil())
+		Expect(w.Close()).To(BeNil())
+
+		_, records, err := readCBOR("./test/output-Times-exact.cbor")
+		Expect(err).To(BeNil())
+		Expect(records).To(HaveLen(2))
+		Expect(records[0][1]).ToNot(BeNil())
+		Expect(records[0][1].(time.Time).IsZero()).To(BeTrue())
+		Expect(records[1][1].(time.Time).Equal(tm)).To(BeTrue())
+	})
+
+	It("should write float32 values at single precision", func() {
+		w := peanut.NewCBORWriter("./test/output-", "-float32")
+		Expect(w.Write(&Single{Value: 1.1})).To(BeNil())
+		Expect(w.Close()).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Single-float32.cbor")
+		Expect(err).To(BeNil())
+		// Array of 1 item, single precision float.
+		Expect(output).To(HaveSuffix(string([]byte{0x81, 0xfa, 0x3f, 0x8c, 0xcc, 0xcd})))
+	})
+
+	It("should not write anything when structs are written and cancel is called", func() {
+		w := peanut.NewCBORWriter("./test/output-", "-cancel")
+
+		testWritesAndCancel(w)
+
+		Expect("./test/output-Foo-cancel.cbor").ToNot(BeAnExistingFile())
+		Expect("./test/output-Bar-cancel.cbor").ToNot(BeAnExistingFile())
+	})
+
+	It("should return an error when Write is called after Close", func() {
+		w := peanut.NewCBORWriter("./test/output-", "-close-write")
+
+		testWriteAfterClose(w)
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			w := peanut.NewCBORWriter("./no-such-location/output-bogus-", "")
+
+			testWriteBadType(w)
+		})
+	})
+})
+
+// readCBOR reads the header and records of a file written by CBORWriter.
+func readCBOR(filename string) (map[string]interface{}, [][]interface{}, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	dec := cbor.NewDecoder(f)
+	var header map[string]interface{}
+	if err := dec.Decode(&header); err != nil {
+		return nil, nil, err
+	}
+	var records [][]interface{}
+	for {
+		var r []interface{}
+		err := dec.Decode(&r)
+		if err == io.EOF {
+			return header, records, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, r)
+	}
+}
@@ -0,0 +1,560 @@
+// Peanutgen generates reflection-free encoding methods for peanut record types.
+//
+// Given the name of one or more struct types, having fields tagged for
+// use with peanut, peanutgen creates a new Go source file containing
+// methods implementing peanut.Encoder for each type. Writers detect
+// these methods and use them in place of reflection when writing records.
+//
+// For example, given this snippet,
+//  package models
+//
+//  type Shape struct {
+//  	ShapeID  string `peanut:"shape_id,pk"`
+//  	Name     string `peanut:"name"`
+//  	NumSides int    `peanut:"num_sides"`
+//  }
+// running this command
+//  peanutgen -type=Shape
+// in the same directory will create the file shape_peanut.go,
+// in package models, containing the generated methods.
+//
+// Typically this process is run using go generate, like this:
+//  //go:generate peanutgen -type=Shape
+//
+// With no arguments, the package in the current directory is processed.
+// Otherwise, a single argument names the directory of the package.
+//
+// The -output flag may be used to name the output file.
+// By default it is named after the first type,
+// lowercased, with the suffix _peanut.go.
+//
+// Generated methods must be regenerated whenever a type changes,
+// writers return an error when the generated code is out of date.
+//
+// Peanutgen supports fields of the supported datatypes (see the peanut
+// package documentation), pointers to them, the sql.Null* types, and
+// fields promoted from embedded structs or flattened from inline nested
+// structs. Types that implement any of the interfaces honoured by
+// peanut for custom types are not supported.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of type names; must be set")
+	output    = flag.String("output", "", "output file name; default <type>_peanut.go")
+)
+
+// Usage is a replacement usage function for the flags package.
+func Usage() {
+	fmt.Fprintf(os.Stderr, "Usage of peanutgen:\n")
+	fmt.Fprintf(os.Stderr, "\tpeanutgen [flags] -type T [directory]\n")
+	fmt.Fprintf(os.Stderr, "For more information, see:\n")
+	fmt.Fprintf(os.Stderr, "\thttps://pkg.go.dev/github.com/jimsmart/peanut/cmd/peanutgen\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("peanutgen: ")
+	flag.Usage = Usage
+	flag.Parse()
+	if len(*typeNames) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	names := strings.Split(*typeNames, ",")
+
+	dir := "."
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		dir = flag.Arg(0)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	args := []string{"-type=" + *typeNames}
+	if *output != "" {
+		args = append(args, "-output="+*output)
+	}
+	src, err := generate(dir, names, "peanutgen "+strings.Join(args, " "))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	name := *output
+	if name == "" {
+		name = strings.ToLower(names[0]) + "_peanut.go"
+	}
+	err = os.WriteFile(filepath.Join(dir, name), src, 0644)
+	if err != nil {
+		log.Fatalf("writing output: %s", err)
+	}
+}
+
+// generate returns the formatted source of a file containing encoding
+// methods for the named types, declared in the package in dir.
+func generate(dir string, typeNames []string, command string) ([]byte, error) {
+	pkg, err := loadPackage(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{pkg: pkg, imports: map[string]string{"reflect": "reflect"}}
+	for _, name := range typeNames {
+		obj := pkg.Scope().Lookup(name)
+		if obj == nil {
+			return nil, fmt.Errorf("type %s not found in package %s", name, pkg.Name())
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a named type", name)
+		}
+		st, ok := named.Underlying().(*types.Struct)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a struct type", name)
+		}
+		var fields []*field
+		fields, err = collectFields(fields, st, nil, nil, "", false, map[types.Type]bool{})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		g.generate(name, fields)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by \"%s\"; DO NOT EDIT.\n\n", command)
+	fmt.Fprintf(&buf, "package %s\n\n", pkg.Name())
+	var paths []string
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	fmt.Fprintf(&buf, "import (\n")
+	for _, path := range paths {
+		fmt.Fprintf(&buf, "\t%q\n", path)
+	}
+	fmt.Fprintf(&buf, ")\n")
+	buf.Write(g.buf.Bytes())
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("internal error: invalid Go generated: %s", err)
+	}
+	return src, nil
+}
+
+// loadPackage parses and type-checks the package in dir, ignoring test files.
+func loadPackage(dir string) (*types.Package, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	return conf.Check(bp.ImportPath, fset, files, nil)
+}
+
+// field is a tagged field to be encoded by the generated methods.
+type field struct {
+	header string     // header is the column name.
+	tag    string     // tag is the field tag, with any inline prefix applied to its name.
+	path   []string   // path is the sequence of field names selecting the field.
+	ptrs   []bool     // ptrs records which of the fields selected along path, other than the last, are pointers.
+	typ    types.Type // typ is the declared type of the field.
+}
+
+// collectFields appends the tagged fields of st to out, in the same
+// manner as the peanut package, promoting fields of untagged embedded
+// structs and flattening nested structs tagged as inline.
+func collectFields(out []*field, st *types.Struct, path []string, ptrs []bool, prefix string, nullable bool, seen map[types.Type]bool) ([]*field, error) {
+	// Guard against recursive embedding.
+	if seen[st] {
+		return out, nil
+	}
+	seen[st] = true
+	defer delete(seen, st)
+
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		tag := reflect.StructTag(st.Tag(i)).Get("peanut")
+
+		p := append(append([]string(nil), path...), f.Name())
+
+		// Is this a struct (or a pointer to a struct) to be flattened?
+		ft := f.Type()
+		ptr, isPtr := ft.(*types.Pointer)
+		if isPtr {
+			ft = ptr.Elem()
+		}
+		nested, isStruct := ft.Underlying().(*types.Struct)
+		flatten := isStruct && !builtin(ft) && !customMarshaler(ft)
+
+		// Promote fields of untagged embedded structs.
+		if flatten && f.Anonymous() && tag == "" {
+			var err error
+			out, err = collectFields(out, nested, p, append(append([]bool(nil), ptrs...), isPtr), prefix, nullable || isPtr, seen)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Only process fields with appropriate tags.
+		if tag == "" {
+			continue
+		}
+
+		// Filter out unexported fields.
+		r, _ := utf8.DecodeRuneInString(f.Name())
+		if !unicode.IsUpper(r) {
+			continue
+		}
+
+		// Flatten nested structs tagged as inline.
+		if flatten && hasTagOption(tag, "inline") {
+			pfx, ok := tagOptionValue(tag, "prefix")
+			if !ok {
+				pfx = firstTagValue(tag)
+				if pfx != "" {
+					pfx += "_"
+				}
+			}
+			var err error
+			out, err = collectFields(out, nested, p, append(append([]bool(nil), ptrs...), isPtr), prefix+pfx, nullable || isPtr, seen)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := checkSupported(f.Type()); err != nil {
+			return nil, fmt.Errorf("field %s: %s", strings.Join(p, "."), err)
+		}
+		out = append(out, &field{
+			header: firstTagValue(prefix + tag),
+			tag:    prefix + tag,
+			path:   p,
+			ptrs:   ptrs,
+			typ:    f.Type(),
+		})
+	}
+	return out, nil
+}
+
+// nullFields maps the names of the nullable types of package database/sql
+// to the names of the fields holding their values.
+var nullFields = map[string]string{
+	"NullString":  "String",
+	"NullBool":    "Bool",
+	"NullFloat64": "Float64",
+	"NullInt64":   "Int64",
+	"NullInt32":   "Int32",
+	"NullInt16":   "Int16",
+	"NullByte":    "Byte",
+	"NullTime":    "Time",
+}
+
+// isNamed reports whether t is the named type pkg.name.
+func isNamed(t types.Type, pkg, name string) bool {
+	n, ok := t.(*types.Named)
+	return ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == pkg && n.Obj().Name() == name
+}
+
+// nullField returns the name of the field holding the
+// value of t, if t is one of the sql.Null* types.
+func nullField(t types.Type) (string, bool) {
+	n, ok := t.(*types.Named)
+	if !ok || n.Obj().Pkg() == nil || n.Obj().Pkg().Path() != "database/sql" {
+		return "", false
+	}
+	f, ok := nullFields[n.Obj().Name()]
+	return f, ok
+}
+
+// builtin reports whether t is a type with built-in support in peanut.
+func builtin(t types.Type) bool {
+	if isNamed(t, "time", "Time") || isNamed(t, "time", "Duration") {
+		return true
+	}
+	_, ok := nullField(t)
+	return ok
+}
+
+// customMarshaler reports whether values of type t, or pointers to them,
+// have any of the methods that peanut uses to marshal custom types.
+func customMarshaler(t types.Type) bool {
+	if builtin(t) {
+		return false
+	}
+	ms := types.NewMethodSet(types.NewPointer(t))
+	for _, name := range []string{"MarshalPeanut", "MarshalJSON", "MarshalText", "String", "Value"} {
+		if ms.Lookup(nil, name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSupported returns an error if peanutgen cannot
+// generate code for fields of type t.
+func checkSupported(t types.Type) error {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	if builtin(t) {
+		return nil
+	}
+	if customMarshaler(t) {
+		return fmt.Errorf("custom marshaling of type %s is not supported", t)
+	}
+	if b, ok := t.Underlying().(*types.Basic); ok && b.Info()&(types.IsBoolean|types.IsInteger|types.IsFloat|types.IsString) != 0 &&
+		b.Kind() != types.Uintptr && b.Info()&types.IsUntyped == 0 {
+		return nil
+	}
+	return fmt.Errorf("unsupported type: %s", t)
+}
+
+// generator accumulates generated methods, and the imports they require.
+type generator struct {
+	pkg     *types.Package
+	buf     bytes.Buffer
+	imports map[string]string // imports maps import paths to package names.
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// qualifier qualifies types from other packages, recording their imports.
+func (g *generator) qualifier(p *types.Package) string {
+	if p == g.pkg {
+		return ""
+	}
+	g.imports[p.Path()] = p.Name()
+	return p.Name()
+}
+
+// typeString returns the Go syntax for type t, for use in generated code.
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, g.qualifier)
+}
+
+// generate generates the methods for the named type, having the given fields.
+func (g *generator) generate(name string, fields []*field) {
+	g.printf("\n")
+	g.printf("// PeanutHeaders returns the column names of %s's tagged fields.\n", name)
+	g.printf("func (%s) PeanutHeaders() []string {\n", name)
+	g.printf("\treturn []string{\n")
+	for _, f := range fields {
+		g.printf("\t\t%q,\n", f.header)
+	}
+	g.printf("\t}\n")
+	g.printf("}\n")
+
+	g.printf("\n")
+	g.printf("// PeanutTags returns the tags of %s's tagged fields.\n", name)
+	g.printf("func (%s) PeanutTags() []string {\n", name)
+	g.printf("\treturn []string{\n")
+	for _, f := range fields {
+		g.printf("\t\t%q,\n", f.tag)
+	}
+	g.printf("\t}\n")
+	g.printf("}\n")
+
+	g.printf("\n")
+	g.printf("// PeanutTypes returns the types of %s's tagged fields.\n", name)
+	g.printf("func (%s) PeanutTypes() []reflect.Type {\n", name)
+	g.printf("\treturn []reflect.Type{\n")
+	for _, f := range fields {
+		t := f.typ
+		if f.nullable() {
+			if _, ok := t.(*types.Pointer); !ok {
+				if _, ok := nullField(t); !ok {
+					t = types.NewPointer(t)
+				}
+			}
+		}
+		g.printf("\t\treflect.TypeOf((*%s)(nil)).Elem(),\n", g.typeString(t))
+	}
+	g.printf("\t}\n")
+	g.printf("}\n")
+
+	g.printf("\n")
+	g.printf("// PeanutValues appends the values of %s's tagged fields to dst,\n", name)
+	g.printf("// using nil for null values, and returns the result.\n")
+	g.printf("func (x %s) PeanutValues(dst []interface{}) []interface{} {\n", name)
+	for _, f := range fields {
+		g.field(f, func(expr string) string { return expr }, "nil")
+	}
+	g.printf("\treturn dst\n")
+	g.printf("}\n")
+
+	g.printf("\n")
+	g.printf("// PeanutStrings appends the text of the values of %s's tagged fields\n", name)
+	g.printf("// to dst, using null for null values, and returns the result.\n")
+	g.printf("func (x %s) PeanutStrings(dst []string, null string) []string {\n", name)
+	for _, f := range fields {
+		g.field(f, func(expr string) string { return g.text(expr, f) }, "null")
+	}
+	g.printf("\treturn dst\n")
+	g.printf("}\n")
+}
+
+// nullable reports whether the field is reached via a pointer.
+func (f *field) nullable() bool {
+	for _, p := range f.ptrs {
+		if p {
+			return true
+		}
+	}
+	return false
+}
+
+// field generates code appending the value of field f to dst,
+// using conv to convert the (non-null) value, and null for null values.
+func (g *generator) field(f *field, conv func(expr string) string, null string) {
+	sel := "x." + strings.Join(f.path, ".")
+
+	// Conditions for the value being non-null.
+	var conds []string
+	for i, p := range f.ptrs {
+		if p {
+			conds = append(conds, "x."+strings.Join(f.path[:i+1], ".")+" != nil")
+		}
+	}
+	expr := sel
+	if _, ok := f.typ.(*types.Pointer); ok {
+		conds = append(conds, sel+" != nil")
+		expr = "*" + sel
+	} else if nf, ok := nullField(f.typ); ok {
+		conds = append(conds, sel+".Valid")
+		expr = sel + "." + nf
+	}
+
+	if len(conds) == 0 {
+		g.printf("\tdst = append(dst, %s)\n", conv(expr))
+		return
+	}
+	g.printf("\tif %s {\n", strings.Join(conds, " && "))
+	g.printf("\t\tdst = append(dst, %s)\n", conv(expr))
+	g.printf("\t} else {\n")
+	g.printf("\t\tdst = append(dst, %s)\n", null)
+	g.printf("\t}\n")
+}
+
+// text returns an expression converting the value expr,
+// of field f, to text, in the same manner as the peanut package.
+func (g *generator) text(expr string, f *field) string {
+	t := f.typ
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	} else if _, ok := nullField(t); ok {
+		t = t.Underlying().(*types.Struct).Field(0).Type()
+	}
+
+	switch {
+	case isNamed(t, "time", "Time"):
+		layout := "2006-01-02T15:04:05Z07:00" // time.RFC3339
+		if l, ok := tagOptionValue(f.tag, "format"); ok {
+			layout = l
+		}
+		return fmt.Sprintf("%s.Format(%q)", paren(expr), layout)
+	case isNamed(t, "time", "Duration"):
+		return paren(expr) + ".String()"
+	}
+
+	b := t.Underlying().(*types.Basic)
+	switch {
+	case b.Info()&types.IsString != 0:
+		return convert(t, types.String, expr)
+	case b.Info()&types.IsBoolean != 0:
+		g.imports["strconv"] = "strconv"
+		return "strconv.FormatBool(" + convert(t, types.Bool, expr) + ")"
+	case b.Info()&types.IsUnsigned != 0:
+		g.imports["strconv"] = "strconv"
+		return "strconv.FormatUint(" + convert(t, types.Uint64, expr) + ", 10)"
+	case b.Info()&types.IsInteger != 0:
+		g.imports["strconv"] = "strconv"
+		return "strconv.FormatInt(" + convert(t, types.Int64, expr) + ", 10)"
+	case b.Kind() == types.Float32:
+		g.imports["strconv"] = "strconv"
+		return "strconv.FormatFloat(" + convert(t, types.Float64, expr) + ", 'g', -1, 32)"
+	default:
+		g.imports["strconv"] = "strconv"
+		return "strconv.FormatFloat(" + convert(t, types.Float64, expr) + ", 'g', -1, 64)"
+	}
+}
+
+// convert returns an expression converting expr, of type t,
+// to the basic type of the given kind, if t is not already that type.
+func convert(t types.Type, kind types.BasicKind, expr string) string {
+	if types.Identical(t, types.Typ[kind]) {
+		return expr
+	}
+	return types.Typ[kind].Name() + "(" + expr + ")"
+}
+
+// paren returns expr, parenthesized if it is a pointer indirection.
+func paren(expr string) string {
+	if strings.HasPrefix(expr, "*") {
+		return "(" + expr + ")"
+	}
+	return expr
+}
+
+func firstTagValue(s string) string {
+	return strings.Split(s, ",")[0]
+}
+
+// hasTagOption reports whether the tag s contains
+// the given option, following the name.
+func hasTagOption(s, opt string) bool {
+	for _, o := range strings.Split(s, ",")[1:] {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// tagOptionValue returns the value of a key=value option
+// in the tag s, and whether the option was present.
+func tagOptionValue(s, key string) (string, bool) {
+	for _, o := range strings.Split(s, ",")[1:] {
+		if strings.HasPrefix(o, key+"=") {
+			return o[len(key)+1:], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestGenerate checks that the committed generated code
+// for package gentest is up to date.
+func TestGenerate(t *testing.T) {
+	dir := filepath.Join("..", "..", "internal", "gentest")
+	got, err := generate(dir, []string{"Record", "Shape"}, "peanutgen -type=Record,Shape -output=gentest_peanut.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join(dir, "gentest_peanut.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("generated code differs from gentest_peanut.go, run go generate")
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	dir := filepath.Join("..", "..", "internal", "gentest")
+	for _, name := range []string{"Missing", "Level"} {
+		if _, err := generate(dir, []string{name}, "peanutgen"); err == nil {
+			t.Errorf("expected an error generating %s", name)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package peanut
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Compression is a compression format, used by
+// file-based writers to compress their output files.
+type Compression int
+
+// Compression formats supported by file-based writers.
+const (
+	NoCompression Compression = iota // NoCompression writes files uncompressed.
+	Gzip                             // Gzip compresses files using gzip, with extension ".gz".
+	Zstd                             // Zstd compresses files using Zstandard, with extension ".zst".
+	Bzip2                            // Bzip2 compresses files using bzip2, with extension ".bz2".
+	XZ                               // XZ compresses files using xz, with extension ".xz".
+)
+
+// Extension returns the filename extension used for files
+// compressed using c, which is appended to the extension of
+// the uncompressed format, as in ".csv.gz".
+func (c Compression) Extension() string {
+	switch c {
+	case Gzip:
+		return ".gz"
+	case Zstd:
+		return ".zst"
+	case Bzip2:
+		return ".bz2"
+	case XZ:
+		return ".xz"
+	}
+	return ""
+}
+
+// decompressCommand returns the command
+// that decompresses files compressed using c.
+func (c Compression) decompressCommand() string {
+	switch c {
+	case Gzip:
+		return "gzip -dc"
+	case Zstd:
+		return "zstd -dc"
+	case Bzip2:
+		return "bzip2 -dc"
+	case XZ:
+		return "xz -dc"
+	}
+	return "cat"
+}
+
+// newWriter returns a writer compressing data to w.
+// Closing the returned writer flushes any compressed data
+// to w, but does not close w.
+func (c Compression) newWriter(w io.Writer) (io.WriteCloser, error) {
+	switch c {
+	case NoCompression:
+		return nopWriteCloser{w}, nil
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		return zstd.NewWriter(w)
+	case Bzip2:
+		return bzip2.NewWriter(w, nil)
+	case XZ:
+		return xz.NewWriter(w)
+	}
+	return nil, fmt.Errorf("peanut: unknown compression %d", c)
+}
+
+// shellQuote returns s quoted for use as a
+// single argument in a POSIX shell command.
+func shellQuote(s string) string {
+	for _, r := range s {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || strings.ContainsRune("_-./", r) {
+			continue
+		}
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+	return s
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,180 @@
+package peanut
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"reflect"
+)
+
+var _ Reader = &CSVReader{}
+
+// CSVReader reads records from CSV files, such as those
+// written by CSVWriter, reading each record type from
+// an individual CSV file.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + extension
+//
+// Where extension is ".csv" or ".tsv" accordingly.
+//
+// The first row of each CSV file must contain headers,
+// which are matched to the names in the struct's field tags.
+// Columns may appear in any order. Unless Lenient is set,
+// missing and unexpected columns result in an error.
+//
+// Nullable fields (pointers and sql.Null* types) are read
+// as null when their text is equal to NullValue, which is
+// empty by default.
+//
+// The caller must call Close when finished reading.
+type CSVReader struct {
+	*base
+	NullValue    string   // NullValue is the text read as null for nullable fields.
+	NameFunc     NameFunc // NameFunc names the input files, TypeName is used if nil.
+	Lenient      bool     // Lenient permits missing and unexpected columns.
+	prefix       string
+	suffix       string
+	extension    string
+	comma        rune
+	sourceByType map[reflect.Type]*csvSource
+}
+
+// NewCSVReader returns a new CSVReader, using prefix
+// and suffix when building its input filenames,
+// and using ".csv" file extension with comma ',' as a field separator.
+//
+// See CSVReader (above) for input filename details.
+func NewCSVReader(prefix, suffix string) *CSVReader {
+	r := CSVReader{
+		base:         &base{format: formatCSV},
+		prefix:       prefix,
+		suffix:       suffix,
+		extension:    ".csv",
+		comma:        ',',
+		sourceByType: make(map[reflect.Type]*csvSource),
+	}
+	return &r
+}
+
+// NewTSVReader returns a new CSVReader configured to read
+// TSV files, using prefix and suffix when building its input filenames,
+// and using ".tsv" file extension with tab '\t' as a field separator.
+//
+// See CSVReader (above) for input filename details.
+func NewTSVReader(prefix, suffix string) *CSVReader {
+	r := NewCSVReader(prefix, suffix)
+	r.extension = ".tsv"
+	r.base.format = formatTSV
+	r.comma = '\t'
+	return r
+}
+
+type csvSource struct {
+	filename string
+	file     *os.File
+	csvr     *csv.Reader
+	fields   []*fieldPlan // fields holds the field for each column, or nil if unused.
+	row      int          // row is the number of the last row read.
+}
+
+func (r *CSVReader) source(x interface{}) (*csvSource, error) {
+	// Register with base.
+	t, _, err := r.base.registerRead(x, r.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := r.sourceByType[t]; ok {
+		return s, nil
+	}
+
+	name := r.prefix + r.nameByType[t] + r.suffix + r.extension
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	cr := csv.NewReader(file)
+	cr.Comma = r.comma
+	cr.ReuseRecord = true
+	s := &csvSource{filename: name, file: file, csvr: cr}
+
+	headers, err := cr.Read()
+	switch {
+	case err == io.EOF:
+		// An empty file has no records.
+	case err != nil:
+		file.Close()
+		return nil, &ReadError{File: name, Row: 1, Err: err}
+	default:
+		s.row = 1
+		s.fields, err = mapColumns(r.planByType[t], headers, r.Lenient, name)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	r.sourceByType[t] = s
+	return s, nil
+}
+
+// Read reads the next record from the input file
+// corresponding to the type of the given record,
+// which must be a pointer to a struct, returning
+// io.EOF when no more records remain.
+func (r *CSVReader) Read(x interface{}) error {
+	if r.closed {
+		return ErrClosedReader
+	}
+	v, err := readTarget(x)
+	if err != nil {
+		return err
+	}
+	s, err := r.source(x)
+	if err != nil {
+		return err
+	}
+	if s.row == 0 {
+		return io.EOF
+	}
+	row, err := s.csvr.Read()
+	if err == io.EOF {
+		return err
+	}
+	s.row++
+	if err != nil {
+		return &ReadError{File: s.filename, Row: s.row, Err: err}
+	}
+
+	v.Set(reflect.Zero(v.Type()))
+	for i, f := range s.fields {
+		if f == nil {
+			continue
+		}
+		text := row[i]
+		err := f.set(v, text, f.nullable && text == r.NullValue)
+		if err != nil {
+			return &ReadError{File: s.filename, Row: s.row, Column: f.header, Err: err}
+		}
+	}
+	return nil
+}
+
+// Close closes all input files.
+//
+// Calling Close more than once is safe,
+// and subsequent calls are a no-op.
+func (r *CSVReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	var rerr error
+	for _, s := range r.sourceByType {
+		err := s.file.Close()
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
@@ -0,0 +1,187 @@
+package peanut_test
+
+import (
+	"encoding/csv"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"reflect"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+// Cents implements peanut.Marshaler and peanut.Unmarshaler.
+type Cents int64
+
+func (Cents) PeanutType() reflect.Type { return reflect.TypeOf(float64(0)) }
+
+func (c Cents) MarshalPeanut(format string) (interface{}, error) {
+	return float64(c) / 100, nil
+}
+
+func (c *Cents) UnmarshalPeanut(format string, v interface{}) error {
+	*c = Cents(math.Round(v.(float64) * 100))
+	return nil
+}
+
+type Price struct {
+	ID     string `peanut:"id"`
+	Amount Cents  `peanut:"amount"`
+	IP     net.IP `peanut:"ip"`
+}
+
+var _ = Describe("CSVReader", func() {
+
+	AfterEach(func() {
+		os.Remove("./test/output-Foo-read.csv")
+		os.Remove("./test/output-Bar-read.csv")
+		os.Remove("./test/output-Baz-read.csv")
+		os.Remove("./test/output-Qux-read.csv")
+		os.Remove("./test/output-Times-read.csv")
+		os.Remove("./test/output-Nullable-read.csv")
+		os.Remove("./test/output-Nested-read.csv")
+		os.Remove("./test/output-Price-read.csv")
+		os.Remove("./test/input-Foo.csv")
+	})
+
+	It("should read back the records written by CSVWriter", func() {
+		testWritesAndCloseSequential(peanut.NewCSVWriter("./test/output-", "-read"))
+
+		testReadsAndClose(peanut.NewCSVReader("./test/output-", "-read"))
+	})
+
+	It("should read back times, nulls and nested structs", func() {
+		testWritesTimesAndClose(peanut.NewCSVWriter("./test/output-", "-read"))
+		testWritesNullableAndClose(peanut.NewCSVWriter("./test/output-", "-read"))
+		testWritesNestedAndClose(peanut.NewCSVWriter("./test/output-", "-read"))
+
+		testReadsTimesNullableAndNested(peanut.NewCSVReader("./test/output-", "-read"))
+	})
+
+	It("should read back custom types that unmarshal themselves", func() {
+		prices := []*Price{{ID: "p1", Amount: 1234, IP: net.IPv4(192, 168, 0, 1)}}
+		w := peanut.NewCSVWriter("./test/output-", "-read")
+		Expect(w.Write(prices[0])).To(BeNil())
+		Expect(w.Close()).To(BeNil())
+
+		r := peanut.NewCSVReader("./test/output-", "-read")
+		defer r.Close()
+		out, err := readAll[Price](r)
+		Expect(err).To(BeNil())
+		Expect(out).To(HaveLen(1))
+		Expect(out[0].Amount).To(Equal(prices[0].Amount))
+		Expect(out[0].IP.Equal(prices[0].IP)).To(BeTrue())
+	})
+
+	It("should match columns by header in any order", func() {
+		err := ioutil.WriteFile("./test/input-Foo.csv", []byte("foo_int,foo_string\n1,test 1\n"), 0644)
+		Expect(err).To(BeNil())
+
+		r := peanut.NewCSVReader("./test/input-", "")
+		defer r.Close()
+		foo, err := readAll[Foo](r)
+		Expect(err).To(BeNil())
+		Expect(foo).To(Equal(testOutputFoo[:1]))
+	})
+
+	It("should return an error for missing and unexpected columns, unless lenient", func() {
+		err := ioutil.WriteFile("./test/input-Foo.csv", []byte("foo_string,extra\ntest 1,x\n"), 0644)
+		Expect(err).To(BeNil())
+
+		r := peanut.NewCSVReader("./test/input-", "")
+		err = r.Read(&Foo{})
+		Expect(errors.Is(err, peanut.ErrUnexpectedColumn)).To(BeTrue())
+		Expect(err.Error()).To(MatchRegexp("extra"))
+		Expect(r.Close()).To(BeNil())
+
+		err = ioutil.WriteFile("./test/input-Foo.csv", []byte("foo_string\ntest 1\n"), 0644)
+		Expect(err).To(BeNil())
+
+		r = peanut.NewCSVReader("./test/input-", "")
+		err = r.Read(&Foo{})
+		Expect(errors.Is(err, peanut.ErrMissingColumn)).To(BeTrue())
+		Expect(err.Error()).To(MatchRegexp("foo_int"))
+		Expect(r.Close()).To(BeNil())
+
+		r = peanut.NewCSVReader("./test/input-", "")
+		r.Lenient = true
+		defer r.Close()
+		foo, err := readAll[Foo](r)
+		Expect(err).To(BeNil())
+		Expect(foo).To(Equal([]*Foo{{StringField: "test 1"}}))
+	})
+
+	It("should report the file, row and column of conversion errors", func() {
+		err := ioutil.WriteFile("./test/input-Foo.csv", []byte("foo_string,foo_int\ntest 1,1\ntest 2,two\n"), 0644)
+		Expect(err).To(BeNil())
+
+		r := peanut.NewCSVReader("./test/input-", "")
+		defer r.Close()
+		_, err = readAll[Foo](r)
+		var rerr *peanut.ReadError
+		Expect(errors.As(err, &rerr)).To(BeTrue())
+		Expect(rerr.File).To(Equal("./test/input-Foo.csv"))
+		Expect(rerr.Row).To(Equal(3))
+		Expect(rerr.Column).To(Equal("foo_int"))
+		Expect(err.Error()).To(MatchRegexp(`input-Foo\.csv: row 3, column foo_int`))
+	})
+
+	It("should report the file and row of malformed CSV", func() {
+		err := ioutil.WriteFile("./test/input-Foo.csv", []byte("foo_string,foo_int\ntest 1,1\ntest 2\n"), 0644)
+		Expect(err).To(BeNil())
+
+		r := peanut.NewCSVReader("./test/input-", "")
+		_, err = readAll[Foo](r)
+		Expect(errors.Is(err, csv.ErrFieldCount)).To(BeTrue())
+		var rerr *peanut.ReadError
+		Expect(errors.As(err, &rerr)).To(BeTrue())
+		Expect(rerr.File).To(Equal("./test/input-Foo.csv"))
+		Expect(rerr.Row).To(Equal(3))
+		Expect(r.Close()).To(BeNil())
+
+		err = ioutil.WriteFile("./test/input-Foo.csv", []byte("foo_string,\"foo_int\ntest 1,1\n"), 0644)
+		Expect(err).To(BeNil())
+
+		r = peanut.NewCSVReader("./test/input-", "")
+		defer r.Close()
+		err = r.Read(&Foo{})
+		Expect(errors.Is(err, csv.ErrQuote)).To(BeTrue())
+		Expect(errors.As(err, &rerr)).To(BeTrue())
+		Expect(rerr.Row).To(Equal(1))
+	})
+
+	It("should return an error when the input file does not exist", func() {
+		r := peanut.NewCSVReader("./test/no-such-file-", "")
+		defer r.Close()
+		err := r.Read(&Foo{})
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			testReadBadType(peanut.NewCSVReader("./test/output-", "-read"))
+		})
+	})
+})
+
+var _ = Describe("CSVReader (TSV)", func() {
+
+	AfterEach(func() {
+		os.Remove("./test/output-Foo-read.tsv")
+		os.Remove("./test/output-Bar-read.tsv")
+		os.Remove("./test/output-Baz-read.tsv")
+		os.Remove("./test/output-Qux-read.tsv")
+	})
+
+	It("should read back the records written by a TSV CSVWriter", func() {
+		testWritesAndCloseSequential(peanut.NewTSVWriter("./test/output-", "-read"))
+
+		testReadsAndClose(peanut.NewTSVReader("./test/output-", "-read"))
+	})
+})
@@ -2,6 +2,7 @@ package peanut
 
 import (
 	"encoding/csv"
+	"io"
 	"io/ioutil"
 	"os"
 	"reflect"
@@ -14,10 +15,13 @@ var _ Writer = &CSVWriter{}
 //
 // Filenames for each corresponding record type are derived
 // accordingly:
-//  prefix + type.Name() + suffix + extension
+//  prefix + NameFunc(type) + suffix + extension
 //
 // Where extension is ".csv" or ".tsv" accordingly.
 //
+// If Compression is set, files are compressed accordingly,
+// and named with its Extension, as in ".csv.gz".
+//
 // The first row of resulting CSV file(s) will contain
 // headers using names extracted from the struct's
 // field tags. Records' fields are written in the order
@@ -30,8 +34,17 @@ var _ Writer = &CSVWriter{}
 // In the event of an error or cancellation, the
 // caller must call Cancel before quiting, to ensure
 // closure and cleanup of any partially written files.
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Null values, from nil pointers and invalid sql.Null* values,
+// are written using NullValue, which is empty by default.
 type CSVWriter struct {
 	*base
+	NullValue     string      // NullValue is the text written for null values.
+	NameFunc      NameFunc    // NameFunc names the output files, TypeName is used if nil.
+	Compression   Compression // Compression compresses the output files, which are uncompressed by default.
 	prefix        string
 	suffix        string
 	extension     string
@@ -46,7 +59,7 @@ type CSVWriter struct {
 // See CSVWriter (above) for output filename details.
 func NewCSVWriter(prefix, suffix string) *CSVWriter {
 	w := CSVWriter{
-		base:          &base{},
+		base:          &base{format: formatCSV},
 		prefix:        prefix,
 		suffix:        suffix,
 		extension:     ".csv",
@@ -64,6 +77,7 @@ func NewCSVWriter(prefix, suffix string) *CSVWriter {
 func NewTSVWriter(prefix, suffix string) *CSVWriter {
 	w := NewCSVWriter(prefix, suffix)
 	w.extension = ".tsv"
+	w.base.format = formatTSV
 	w.comma = '\t'
 	return w
 }
@@ -71,12 +85,17 @@ func NewTSVWriter(prefix, suffix string) *CSVWriter {
 type csvBuilder struct {
 	filename string
 	file     *os.File
+	zw       io.WriteCloser // zw compresses output written to file.
 	csvw     *csv.Writer
+	row      []string // row is reused for each record written.
 }
 
 func (w *CSVWriter) register(x interface{}) (reflect.Type, error) {
 	// Register with base writer.
-	t, ok := w.base.register(x)
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
 		return t, nil
 	}
@@ -89,14 +108,20 @@ func (w *CSVWriter) register(x interface{}) (reflect.Type, error) {
 
 	// log.Printf("Setting up csv.Writer for %s", t.Name())
 
-	name := w.prefix + t.Name() + w.suffix + w.extension
+	name := w.prefix + w.nameByType[t] + w.suffix + w.extension + w.Compression.Extension()
 	file, err := ioutil.TempFile("", "atomic-")
 	if err != nil {
 		return nil, err
 	}
-	cw := csv.NewWriter(file)
+	zw, err := w.Compression.newWriter(file)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	cw := csv.NewWriter(zw)
 	cw.Comma = w.comma
-	w.builderByType[t] = &csvBuilder{filename: name, file: file, csvw: cw}
+	w.builderByType[t] = &csvBuilder{filename: name, file: file, zw: zw, csvw: cw}
 
 	err = cw.Write(w.headersByType[t])
 	if err != nil {
@@ -117,12 +142,23 @@ func (w *CSVWriter) Write(x interface{}) error {
 	if err != nil {
 		return err
 	}
-	if len(w.base.tagsByType[t]) == 0 {
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *CSVWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
 		return nil
 	}
+	var err error
 	// log.Printf("WriteRecord for %s", t.Name())
-	cw := w.builderByType[t].csvw
-	return cw.Write(stringValues(x))
+	c := w.builderByType[t]
+	c.row, err = p.strings(x, w.NullValue, c.row[:0])
+	if err != nil {
+		return err
+	}
+	return c.csvw.Write(c.row)
 }
 
 // Close flushes all buffers and writers,
@@ -145,6 +181,11 @@ func (w *CSVWriter) Close() error {
 			cerr = err
 		}
 
+		err = c.zw.Close()
+		if err != nil {
+			cerr = err
+		}
+
 		// Chmod the file world-readable (ioutil.TempFile creates files with
 		// mode 0600) before renaming.
 		err = c.file.Chmod(0644)
@@ -194,6 +235,9 @@ func (w *CSVWriter) Cancel() error {
 	for _, c := range w.builderByType {
 		var err error
 
+		// Release any resources held by the compressor.
+		c.zw.Close()
+
 		err = c.file.Close()
 		if err != nil {
 			rerr = err
@@ -1,6 +1,7 @@
 package peanut_test
 
 import (
+	"archive/tar"
 	"io/ioutil"
 	"os"
 
@@ -90,6 +91,41 @@ var _ = Describe("CSVWriter", func() {
 		Expect("./test/output-Bar-cancel.csv").ToNot(BeAnExistingFile())
 	})
 
+	It("should compress the output files when configured to", func() {
+		exts := map[peanut.Compression]string{
+			peanut.Gzip:  ".csv.gz",
+			peanut.Zstd:  ".csv.zst",
+			peanut.Bzip2: ".csv.bz2",
+			peanut.XZ:    ".csv.xz",
+		}
+		for c, ext := range exts {
+			w := peanut.NewCSVWriter("./test/output-", "-compressed")
+			w.Compression = c
+
+			testWritesAndCloseSequential(w)
+
+			Expect("./test/output-Foo-compressed.csv").ToNot(BeAnExistingFile())
+			Expect(readCompressedFile("./test/output-Foo-compressed"+ext, c)).To(Equal(expectedOutput1))
+			Expect(readCompressedFile("./test/output-Bar-compressed"+ext, c)).To(Equal(expectedOutput2))
+			Expect(readCompressedFile("./test/output-Baz-compressed"+ext, c)).To(Equal(expectedOutput3))
+			Expect("./test/output-Qux-compressed" + ext).ToNot(BeAnExistingFile())
+
+			for _, name := range []string{"Foo", "Bar", "Baz"} {
+				os.Remove("./test/output-" + name + "-compressed" + ext)
+			}
+		}
+	})
+
+	It("should not write anything when compressing and cancel is called", func() {
+		w := peanut.NewCSVWriter("./test/output-", "-cancel")
+		w.Compression = peanut.Zstd
+
+		testWritesAndCancel(w)
+
+		Expect("./test/output-Foo-cancel.csv.zst").ToNot(BeAnExistingFile())
+		Expect("./test/output-Bar-cancel.csv.zst").ToNot(BeAnExistingFile())
+	})
+
 	It("should return an error when Write is called after Close", func() {
 		w := newFn("-close-write")
 
@@ -108,6 +144,104 @@ var _ = Describe("CSVWriter", func() {
 		Expect(err).ToNot(BeNil())
 	})
 
+	It("should write time fields using RFC 3339 or the tagged format", func() {
+		w := newFn("-times")
+
+		testWritesTimesAndClose(w)
+		defer os.Remove("./test/output-Times-times.csv")
+
+		output, err := ioutil.ReadFile("./test/output-Times-times.csv")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("id,time,date,duration\n" +
+			"t1,2021-04-19T13:45:30Z,2021-04-19,1h30m0s\n"))
+	})
+
+	It("should write null values using the configured null value", func() {
+		w := peanut.NewCSVWriter("./test/output-", "-nullable")
+		w.NullValue = "NULL"
+
+		testWritesNullableAndClose(w)
+		defer os.Remove("./test/output-Nullable-nullable.csv")
+
+		output, err := ioutil.ReadFile("./test/output-Nullable-nullable.csv")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("id,int_ptr,string_ptr,null_string,null_int64,null_time,null_float\n" +
+			"n1,1,test 1,test 1,1,2021-04-19T13:45:30Z,1.5\n" +
+			"n2,NULL,NULL,NULL,NULL,NULL,NULL\n"))
+	})
+
+	It("should flatten embedded and inline nested structs", func() {
+		w := newFn("-nested")
+
+		testWritesNestedAndClose(w)
+		defer os.Remove("./test/output-Nested-nested.csv")
+
+		output, err := ioutil.ReadFile("./test/output-Nested-nested.csv")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("id,home_street,home_city,work_street,work_city,created_by,updated_by\n" +
+			"n1,1 Home St,Hometown,1 Work St,Worktown,alice,bob\n" +
+			"n2,2 Home St,Hometown,,,carol,dave\n"))
+	})
+
+	It("should write custom types using the interfaces they implement", func() {
+		w := newFn("-custom")
+
+		testWritesCustomAndClose(w)
+		defer os.Remove("./test/output-Custom-custom.csv")
+
+		output, err := ioutil.ReadFile("./test/output-Custom-custom.csv")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("id,amount,level,ip,point,code\n" +
+			`c1,12.34,warning,192.168.0.1,"{""x"":1,""y"":2}",CODE-a` + "\n"))
+	})
+
+	It("should use the name given by a struct-level tag", func() {
+		w := newFn("-renamed")
+
+		err := w.Write(&Renamed{ID: "r1"})
+		Expect(err).To(BeNil())
+		err = w.Close()
+		Expect(err).To(BeNil())
+		defer os.Remove("./test/output-renamed_records-renamed.csv")
+
+		output, err := ioutil.ReadFile("./test/output-renamed_records-renamed.csv")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("id\nr1\n"))
+	})
+
+	It("should return an error when types from different packages have the same name", func() {
+		w := newFn("-collision")
+
+		err := w.Write(&Header{Name: "h1"})
+		Expect(err).To(BeNil())
+		err = w.Write(&tar.Header{})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(SatisfyAll(
+			MatchRegexp("archive/tar.Header"),
+			MatchRegexp("peanut_test.Header"),
+		))
+
+		err = w.Cancel()
+		Expect(err).To(BeNil())
+	})
+
+	It("should use package-qualified names when configured to", func() {
+		w := peanut.NewCSVWriter("./test/output-", "-qualified")
+		w.NameFunc = peanut.QualifiedTypeName
+
+		err := w.Write(&Header{Name: "h1"})
+		Expect(err).To(BeNil())
+		err = w.Write(&tar.Header{})
+		Expect(err).To(BeNil())
+		err = w.Close()
+		Expect(err).To(BeNil())
+		defer os.Remove("./test/output-peanut_test.Header-qualified.csv")
+
+		output, err := ioutil.ReadFile("./test/output-peanut_test.Header-qualified.csv")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("name\nh1\n"))
+	})
+
 	Context("when given a struct with an unsupported field type", func() {
 
 		It("should return an error with an informative message", func() {
@@ -7,7 +7,7 @@
 // Field/column names in each file/table are derived from struct tags.
 // All writers use the same tags.
 //
-// Currently supported formats are CSV, TSV, Excel (.xlsx), JSON Lines (JSONL), and SQLite.
+// Currently supported formats are CSV, TSV, Excel (.xlsx), JSON, JSON Lines (JSONL), SQLite, SQL scripts (for SQLite, PostgreSQL and MySQL), PostgreSQL COPY text, MessagePack, CBOR, Protocol Buffers, YAML, TOML, Apache Parquet, Apache Avro, Apache Arrow IPC (Feather), XML, Markdown and HTML tables, and fixed-width text.
 // Additional writers are also provided to assist with testing and debugging.
 // Mutiple writers can be combined using MultiWriter.
 //
@@ -42,6 +42,100 @@
 //
 // Fields without tags do not get written as output.
 //
+// Fields of embedded structs are promoted, as with encoding/json,
+// and written as if they were declared in the outer struct.
+// Nested struct fields tagged with the inline option are flattened,
+// with each of their fields prefixed by the nested field's name
+// and an underscore, or by the value of a prefix option:
+//  type Address struct {
+//  	Street string `peanut:"street"`
+//  	City   string `peanut:"city"`
+//  }
+//
+//  type Person struct {
+//  	Name string   `peanut:"name"`
+//  	Home Address  `peanut:"home,inline"`           // home_street, home_city
+//  	Work *Address `peanut:",inline,prefix=office_"` // office_street, office_city
+//  	Audit                                          // Fields of Audit are promoted.
+//  }
+// Fields reached via a nil pointer are written as null values.
+// Field names must be unique once flattened.
+//
+// Fields of type time.Time are written as RFC 3339 text by text-based
+// writers, as date cells by ExcelWriter, as DATETIME columns by
+// SQLiteWriter, as timestamp columns by SQLDumpWriter and PGCopyWriter,
+// as native timestamps by MsgpackWriter and CBORWriter, as
+// google.protobuf.Timestamp messages by ProtobufWriter, and as UTC
+// timestamps by ParquetWriter, AvroWriter and ArrowWriter.
+// The layout used for text can be set per field, using a format option
+// with a layout as understood by time.Time.Format:
+//  type Event struct {
+//  	EventID string    `peanut:"event_id"`
+//  	Created time.Time `peanut:"created_at,format=2006-01-02"`
+//  }
+// Layouts may not contain commas.
+// Fields of type time.Duration are written as text using its String method,
+// as integer nanoseconds by SQLiteWriter, SQLDumpWriter, PGCopyWriter,
+// MsgpackWriter, CBORWriter, ParquetWriter and AvroWriter, as
+// google.protobuf.Duration messages by ProtobufWriter, and as Arrow
+// durations by ArrowWriter.
+//
+// Nullable values are supported using pointer fields, such as *int64 or *string,
+// or the nullable types of package database/sql, such as sql.NullString,
+// sql.NullInt64 and sql.NullTime. Null values (nil pointers, or sql.Null* values
+// that are not Valid) are written as NULL by SQLiteWriter, SQLDumpWriter,
+// ParquetWriter and ArrowWriter, as \N by PGCopyWriter, as null by JSONWriter,
+// JSONLWriter, YAMLWriter, AvroWriter, MsgpackWriter and CBORWriter, as empty
+// cells by ExcelWriter, as CSVWriter.NullValue by CSVWriter, and are omitted
+// by XMLWriter, TOMLWriter and ProtobufWriter.
+//
+// Output Names
+//
+// Output files and tables are named using the name of each struct type.
+// A struct-level tag, given on a blank field, can be used to choose a different name:
+//  type Shape struct {
+//  	_        struct{} `peanut:"shapes"`
+//  	ShapeID  string   `peanut:"shape_id"`
+//  }
+//
+// Alternatively, types can name themselves by implementing Namer,
+// so that renaming a struct does not change the names of its outputs:
+//  func (Color) PeanutName() string { return "colors_v2" }
+//
+// Writers also have a NameFunc field, allowing a different naming
+// policy to be used. For example, QualifiedTypeName qualifies each
+// name with its package name:
+//  w := peanut.NewCSVWriter("/some/path/my-", "-data")
+//  w.NameFunc = peanut.QualifiedTypeName
+//
+// Or a custom policy can be used, falling back to TypeName as needed:
+//  w.NameFunc = func(t reflect.Type) string {
+//  	return strings.ToLower(peanut.TypeName(t)) + "s"
+//  }
+//
+// Custom Types
+//
+// Fields of other types can be written if their type implements
+// Marshaler, which allows a type to declare both the type of its
+// column and the value to be written by each writer.
+//
+// Otherwise, the following interfaces are honoured, in order of preference:
+//  JSONWriter,
+//  JSONLWriter:   json.Marshaler, encoding.TextMarshaler, fmt.Stringer, driver.Valuer
+//  SQLiteWriter,
+//  SQLDumpWriter,
+//  PGCopyWriter:  driver.Valuer, encoding.TextMarshaler, fmt.Stringer, json.Marshaler
+//  Other writers: encoding.TextMarshaler, fmt.Stringer, driver.Valuer, json.Marshaler
+// Such values are written as text, with the exception of json.Marshaler
+// values written by JSONWriter and JSONLWriter, which are written as JSON, and
+// driver.Valuer values written by SQLiteWriter, which are passed to the
+// database driver, and by SQLDumpWriter and PGCopyWriter, which are
+// written according to their kind.
+//
+// Columns of driver.Valuer types are typed according to the value
+// returned by the type's zero value, or by the type's kind if that
+// value is nil. Writing a value of a different kind returns an error.
+//
 // Usage
 //
 // First create a writer, for example:
@@ -91,15 +185,88 @@
 //  w := peanut.MultiWriter(w1, w2, w3)
 // Here w will write records to CSV files, Excel files, and a logger.
 //
+// TypedWriter
+//
+// Any writer can be wrapped by a TypedWriter, for type-safe
+// writing of records of a single type:
+//  w, err := peanut.NewTypedWriter[*Shape](peanut.NewCSVWriter("/some/path/my-", "-data"))
+//  // ...
+//  err = w.WriteAll(shapes)
+// The record type is validated when the TypedWriter is created,
+// rather than when the first record is written.
+//
+// Compression
+//
+// Writers producing files written as a stream, such as CSVWriter,
+// JSONLWriter, XMLWriter and SQLDumpWriter, can compress their output
+// files using gzip, Zstandard, bzip2 or xz, by setting their Compression
+// field. Compressed files are named with an additional extension:
+//  w := peanut.NewCSVWriter("/some/path/my-", "-data")
+//  w.Compression = peanut.Gzip
+//  // Output files will be named like /some/path/my-Shape-data.csv.gz
+// Writers of container formats, such as ExcelWriter, SQLiteWriter and
+// ParquetWriter, do not support compression.
+//
+// Archives
+//
+// The output files of any file-based writer can instead be bundled into
+// a single .zip or .tar.gz archive, with a member for each file, using
+// ArchiveWriter. The archive is written atomically, when Close is called:
+//  w := peanut.NewArchiveWriter("/some/path/my-data", peanut.Zip,
+//  	func(prefix string) peanut.Writer {
+//  		return peanut.NewCSVWriter(prefix, "")
+//  	})
+//  w.Manifest = true
+//  // Output file will be /some/path/my-data.zip, holding
+//  // Shape.csv, Color.csv and manifest.json.
+//
+// Readers
+//
+// Records can be read back from CSV, TSV, JSON Lines, Excel and SQLite
+// outputs using the corresponding Reader, which maps columns onto tagged
+// fields by header name:
+//  r := peanut.NewCSVReader("/some/path/my-", "-data")
+//  defer r.Close()
+//  for {
+//  	var s Shape
+//  	err := r.Read(&s)
+//  	if err == io.EOF {
+//  		break
+//  	}
+//  	// ...
+//  }
+// Unless a reader's Lenient field is set, missing and unexpected columns
+// result in an error. Errors converting values are reported as a
+// ReadError, giving the file, row and column of the value.
+//
+// Custom types are read by implementing Unmarshaler, or any of
+// encoding.TextUnmarshaler, json.Unmarshaler or sql.Scanner.
+//
+// Code Generation
+//
+// Writers use reflection to read the tagged fields of records.
+// For faster writing, the peanutgen command can generate methods
+// implementing Encoder, which writers use in place of reflection:
+//  //go:generate go run github.com/jimsmart/peanut/cmd/peanutgen -type=Shape
+// Generated methods must be regenerated whenever the type changes,
+// writers return an error when registering a type whose generated
+// methods are out of date. See the peanutgen command for details.
+//
 // Limitations
 //
-// Behaviour is undefined for types with the same name
-// but in different packages, such as package1.Foo and package2.Foo.
+// Writing types that share the same name but are declared
+// in different packages, such as package1.Foo and package2.Foo,
+// results in an error when the second type is written. Such types
+// can be disambiguated using struct-level tags, or by naming outputs
+// using a NameFunc such as QualifiedTypeName (see Output Names, above).
 //
 // Supported datatypes for struct fields: string, bool, float32, float64,
-// int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64.
+// int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+// time.Time and time.Duration, pointers to any of these, the sql.Null* types,
+// and types implementing any of the interfaces listed in Custom Types (above).
 //
-// Pointer following and nested structs are currently unsupported.
+// Pointers to pointers are unsupported, and nested structs
+// are only supported when embedded or tagged as inline.
 //
 // Tagging a field that has an unsupported datatype will result in a
 // error when Write is called.
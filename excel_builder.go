@@ -1,6 +1,10 @@
 package peanut
 
 import (
+	"reflect"
+	"strings"
+	"time"
+
 	"github.com/360EntSecGroup-Skylar/excelize/v2"
 )
 
@@ -11,12 +15,34 @@ type excelBuilder struct {
 	sw       *excelize.StreamWriter
 	row      int // TODO Expose this? we can report number of rows written (to be wary of Excel's row-limit)
 	filename string
+	styles   []int         // styles holds the style ID for each column, zero meaning none.
+	data     []interface{} // data is reused by ExcelWriter for each record written.
+}
+
+// excelSheetName returns name made valid as the name of a sheet,
+// with the characters Excel forbids replaced by underscores,
+// and cut to Excel's limit of 31 characters.
+func excelSheetName(name string) string {
+	r := []rune(strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name))
+	if len(r) > 31 {
+		r = r[:31]
+	}
+	return string(r)
 }
 
-func newExcelBuilder(filename string) (*excelBuilder, error) {
+// newExcelBuilder returns a new excelBuilder, writing a file
+// named filename, holding a single sheet named sheet.
+func newExcelBuilder(filename, sheet string) (*excelBuilder, error) {
 	xlsx := excelize.NewFile()
-	xlsx.SetPanes("Sheet1", `{"freeze":true,"split":false,"x_split":0,"y_split":1,"top_left_cell":"A2","active_pane":"bottomLeft","panes":[{"sqref":"A2","active_cell":"A2","pane":"bottomLeft"}]}`)
-	sw, err := xlsx.NewStreamWriter("Sheet1")
+	// New files hold a single sheet, named Sheet1.
+	xlsx.SetSheetName("Sheet1", sheet)
+	xlsx.SetPanes(sheet, `{"freeze":true,"split":false,"x_split":0,"y_split":1,"top_left_cell":"A2","active_pane":"bottomLeft","panes":[{"sqref":"A2","active_cell":"A2","pane":"bottomLeft"}]}`)
+	sw, err := xlsx.NewStreamWriter(sheet)
 	if err != nil {
 		return nil, err
 	}
@@ -29,6 +55,42 @@ func newExcelBuilder(filename string) (*excelBuilder, error) {
 	return &e, nil
 }
 
+// Bounds of the times that Excel can represent as date/time cells.
+var (
+	excelMinTime = time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+	excelMaxTime = time.Date(10000, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// Built-in Excel number formats used for time-based columns.
+const (
+	excelDateTimeFormat = 22 // m/d/yy h:mm
+	excelDurationFormat = 46 // [h]:mm:ss
+)
+
+// SetColumnTypes creates styles for any columns that need them,
+// so that times and durations appear as proper date/time cells.
+// Styles are applied to all subsequent rows, but not to headers.
+func (e *excelBuilder) SetColumnTypes(types []reflect.Type) error {
+	e.styles = make([]int, len(types))
+	for i, t := range types {
+		var format int
+		switch t {
+		case timeType:
+			format = excelDateTimeFormat
+		case durationType:
+			format = excelDurationFormat
+		default:
+			continue
+		}
+		id, err := e.xlsx.NewStyle(&excelize.Style{NumFmt: format})
+		if err != nil {
+			return err
+		}
+		e.styles[i] = id
+	}
+	return nil
+}
+
 func (e *excelBuilder) AddRow(data ...interface{}) error {
 	c, err := excelize.CoordinatesToCellName(1, e.row)
 	if err != nil {
@@ -42,6 +104,16 @@ func (e *excelBuilder) AddRow(data ...interface{}) error {
 	return nil
 }
 
+// AddStyledRow adds a row of data, applying any column styles.
+func (e *excelBuilder) AddStyledRow(data ...interface{}) error {
+	for i := range data {
+		if i < len(e.styles) && e.styles[i] != 0 {
+			data[i] = excelize.Cell{StyleID: e.styles[i], Value: data[i]}
+		}
+	}
+	return e.AddRow(data...)
+}
+
 func (e *excelBuilder) Save() error {
 	err := e.sw.Flush()
 	if err != nil {
@@ -0,0 +1,212 @@
+package peanut
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+var _ Reader = &ExcelReader{}
+
+// ExcelReader reads records from Excel files, such as those
+// written by ExcelWriter, reading each record type from
+// an individual Excel file.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".xlsx"
+//
+// Records are read from the first sheet of each file, whose
+// first row must contain headers, which are matched to the
+// names in the struct's field tags. Columns may appear in any
+// order. Unless Lenient is set, missing and unexpected columns
+// result in an error.
+//
+// Nullable fields (pointers and sql.Null* types)
+// are read as null when their cells are empty.
+//
+// Time and duration fields may be read from either numeric
+// date/time cells, or from text. Numeric cells are read
+// with millisecond precision.
+//
+// Each file is read into memory in its entirety when
+// the first record of its type is read.
+//
+// The caller must call Close when finished reading.
+type ExcelReader struct {
+	*base
+	NameFunc     NameFunc // NameFunc names the input files, TypeName is used if nil.
+	Lenient      bool     // Lenient permits missing and unexpected columns.
+	prefix       string
+	suffix       string
+	sourceByType map[reflect.Type]*excelSource
+}
+
+// NewExcelReader returns a new ExcelReader, using prefix
+// and suffix when building its input filenames.
+//
+// See ExcelReader (above) for input filename details.
+func NewExcelReader(prefix, suffix string) *ExcelReader {
+	r := ExcelReader{
+		base:         &base{format: formatExcel},
+		prefix:       prefix,
+		suffix:       suffix,
+		sourceByType: make(map[reflect.Type]*excelSource),
+	}
+	return &r
+}
+
+type excelSource struct {
+	filename string
+	rows     [][]string
+	fields   []*fieldPlan   // fields holds the field for each column, or nil if unused.
+	types    []reflect.Type // types holds the column type of each used column.
+	row      int            // row is the number of the last row read.
+}
+
+func (r *ExcelReader) source(x interface{}) (*excelSource, error) {
+	// Register with base.
+	t, _, err := r.base.registerRead(x, r.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := r.sourceByType[t]; ok {
+		return s, nil
+	}
+
+	name := r.prefix + r.nameByType[t] + r.suffix + ".xlsx"
+	xlsx, err := excelize.OpenFile(name)
+	if err != nil {
+		return nil, err
+	}
+	sheets := xlsx.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("peanut: no sheets in %s", name)
+	}
+	sheet := sheets[0]
+	rows, err := xlsx.GetRows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	s := &excelSource{filename: name}
+	if len(rows) == 0 {
+		// An empty sheet has no records.
+		r.sourceByType[t] = s
+		return s, nil
+	}
+
+	s.fields, err = mapColumns(r.planByType[t], rows[0], r.Lenient, name)
+	if err != nil {
+		return nil, err
+	}
+	s.types = make([]reflect.Type, len(s.fields))
+	restyled := false
+	for i, f := range s.fields {
+		if f == nil {
+			continue
+		}
+		s.types[i] = columnType(f.typ, formatExcel)
+		if s.types[i] != timeType && s.types[i] != durationType {
+			continue
+		}
+		// Remove the styles of time-based columns, so that
+		// their cells are read as numbers, not formatted text.
+		top, err := excelize.CoordinatesToCellName(i+1, 2)
+		if err != nil {
+			return nil, err
+		}
+		bottom, err := excelize.CoordinatesToCellName(i+1, len(rows))
+		if err != nil {
+			return nil, err
+		}
+		err = xlsx.SetCellStyle(sheet, top, bottom, 0)
+		if err != nil {
+			return nil, err
+		}
+		restyled = true
+	}
+	if restyled {
+		rows, err = xlsx.GetRows(sheet)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.rows = rows
+	s.row = 1
+	r.sourceByType[t] = s
+	return s, nil
+}
+
+// Read reads the next record from the input file
+// corresponding to the type of the given record,
+// which must be a pointer to a struct, returning
+// io.EOF when no more records remain.
+func (r *ExcelReader) Read(x interface{}) error {
+	if r.closed {
+		return ErrClosedReader
+	}
+	v, err := readTarget(x)
+	if err != nil {
+		return err
+	}
+	s, err := r.source(x)
+	if err != nil {
+		return err
+	}
+	if s.row >= len(s.rows) {
+		return io.EOF
+	}
+	row := s.rows[s.row]
+	s.row++
+
+	v.Set(reflect.Zero(v.Type()))
+	for i, f := range s.fields {
+		if f == nil {
+			continue
+		}
+		// Trailing empty cells are omitted from rows.
+		var text string
+		if i < len(row) {
+			text = row[i]
+		}
+		var val interface{} = text
+		if n, err := strconv.ParseFloat(text, 64); err == nil {
+			switch s.types[i] {
+			case timeType:
+				tm, err := excelize.ExcelDateToTime(n, false)
+				if err != nil {
+					return &ReadError{File: s.filename, Row: s.row, Column: f.header, Err: err}
+				}
+				// Excel date/time values have millisecond precision.
+				val = tm.Round(time.Millisecond)
+			case durationType:
+				// Durations are held as fractions of a day.
+				ms := math.Round(n * float64(24*time.Hour/time.Millisecond))
+				val = time.Duration(ms) * time.Millisecond
+			}
+		}
+		err := f.set(v, val, f.nullable && text == "")
+		if err != nil {
+			return &ReadError{File: s.filename, Row: s.row, Column: f.header, Err: err}
+		}
+	}
+	return nil
+}
+
+// Close releases all resources used by the reader.
+//
+// Calling Close more than once is safe,
+// and subsequent calls are a no-op.
+func (r *ExcelReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.sourceByType = nil
+	return nil
+}
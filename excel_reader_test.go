@@ -0,0 +1,73 @@
+package peanut_test
+
+import (
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+var _ = Describe("ExcelReader", func() {
+
+	AfterEach(func() {
+		os.Remove("./test/output-Foo-read.xlsx")
+		os.Remove("./test/output-Bar-read.xlsx")
+		os.Remove("./test/output-Baz-read.xlsx")
+		os.Remove("./test/output-Qux-read.xlsx")
+		os.Remove("./test/output-Times-read.xlsx")
+		os.Remove("./test/output-Nullable-read.xlsx")
+		os.Remove("./test/output-Nested-read.xlsx")
+		os.Remove("./test/output-Times-early.xlsx")
+	})
+
+	It("should read back the records written by ExcelWriter", func() {
+		testWritesAndCloseSequential(peanut.NewExcelWriter("./test/output-", "-read"))
+
+		testReadsAndClose(peanut.NewExcelReader("./test/output-", "-read"))
+	})
+
+	It("should read back times, nulls and nested structs", func() {
+		testWritesTimesAndClose(peanut.NewExcelWriter("./test/output-", "-read"))
+		testWritesNullableAndClose(peanut.NewExcelWriter("./test/output-", "-read"))
+		testWritesNestedAndClose(peanut.NewExcelWriter("./test/output-", "-read"))
+
+		testReadsTimesNullableAndNested(peanut.NewExcelReader("./test/output-", "-read"))
+	})
+
+	It("should read back times that Excel cannot represent, such as the zero time", func() {
+		times := []*Times{
+			{ID: "t0"},
+			{
+				ID:   "t1",
+				Time: time.Date(1850, 6, 1, 12, 30, 0, 0, time.UTC),
+				Date: time.Date(1850, 6, 1, 0, 0, 0, 0, time.UTC),
+			},
+			{
+				ID:   "t2",
+				Time: time.Date(2021, 4, 19, 13, 45, 30, 0, time.UTC),
+				Date: time.Date(2021, 4, 19, 0, 0, 0, 0, time.UTC),
+			},
+		}
+		w := peanut.NewExcelWriter("./test/output-", "-early")
+		for _, x := range times {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		Expect(w.Close()).To(BeNil())
+
+		r := peanut.NewExcelReader("./test/output-", "-early")
+		defer r.Close()
+		out, err := readAll[Times](r)
+		Expect(err).To(BeNil())
+		Expect(out).To(Equal(times))
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			testReadBadType(peanut.NewExcelReader("./test/output-", "-read"))
+		})
+	})
+})
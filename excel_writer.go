@@ -2,6 +2,7 @@ package peanut
 
 import (
 	"reflect"
+	"time"
 )
 
 var _ Writer = &ExcelWriter{}
@@ -11,13 +12,25 @@ var _ Writer = &ExcelWriter{}
 //
 // Filenames for each corresponding record type are derived
 // accordingly:
-//  prefix + type.Name() + suffix + ".xslx"
+//  prefix + NameFunc(type) + suffix + ".xslx"
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Each file holds a single sheet, also named by NameFunc,
+// with any characters that Excel forbids in sheet names
+// ([]:*?/\) replaced by underscores, and cut to Excel's
+// limit of 31 characters.
 //
 // The first row of resulting Excel file(s) will contain
 // headers using names extracted from the struct's
 // field tags, and will be frozen. Records' fields are
 // written in the order that they appear within the struct.
 //
+// Times are written as date/time cells, except for times
+// that Excel cannot represent, before 1900 or after 9999,
+// such as the zero time.Time, which are written as text.
+//
 // The caller must call Close on successful completion
 // of all writing, to ensure buffers are flushed and
 // files are properly written to disk.
@@ -27,6 +40,7 @@ var _ Writer = &ExcelWriter{}
 // closure and cleanup of any partially written files.
 type ExcelWriter struct {
 	*base
+	NameFunc      NameFunc // NameFunc names the output files, TypeName is used if nil.
 	prefix        string
 	suffix        string
 	builderByType map[reflect.Type]*excelBuilder
@@ -38,7 +52,7 @@ type ExcelWriter struct {
 // See ExcelWriter (above) for output filename details.
 func NewExcelWriter(prefix, suffix string) *ExcelWriter {
 	w := ExcelWriter{
-		base:          &base{},
+		base:          &base{format: formatExcel},
 		prefix:        prefix,
 		suffix:        suffix,
 		builderByType: make(map[reflect.Type]*excelBuilder),
@@ -48,7 +62,10 @@ func NewExcelWriter(prefix, suffix string) *ExcelWriter {
 
 func (w *ExcelWriter) register(x interface{}) (reflect.Type, error) {
 	// Register with base writer.
-	t, ok := w.base.register(x)
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
 		return t, nil
 	}
@@ -59,12 +76,21 @@ func (w *ExcelWriter) register(x interface{}) (reflect.Type, error) {
 		return t, nil
 	}
 
-	excel, err := newExcelBuilder(w.prefix + t.Name() + w.suffix + ".xlsx")
+	excel, err := newExcelBuilder(w.prefix+w.nameByType[t]+w.suffix+".xlsx", excelSheetName(w.nameByType[t]))
 	if err != nil {
 		return nil, err
 	}
 	w.builderByType[t] = excel
 
+	var types []reflect.Type
+	for _, ft := range w.typesByType[t] {
+		types = append(types, columnType(ft, formatExcel))
+	}
+	err = excel.SetColumnTypes(types)
+	if err != nil {
+		return nil, err
+	}
+
 	h := convert(w.headersByType[t])
 	err = excel.AddRow(h...)
 	if err != nil {
@@ -93,11 +119,27 @@ func (w *ExcelWriter) Write(x interface{}) error {
 	if err != nil {
 		return err
 	}
-	if len(w.base.tagsByType[t]) == 0 {
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *ExcelWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
 		return nil
 	}
+	var err error
 	excel := w.builderByType[t]
-	return excel.AddRow(excelValuesFrom(x)...)
+	excel.data, err = p.values(x, excel.data[:0])
+	if err != nil {
+		return err
+	}
+	for i, val := range excel.data {
+		if tm, ok := val.(time.Time); ok && (tm.Before(excelMinTime) || !tm.Before(excelMaxTime)) {
+			excel.data[i] = formatValue(tm, p.fields[i].layout)
+		}
+	}
+	return excel.AddStyledRow(excel.data...)
 }
 
 // Close the writer, ensuring all files are saved.
@@ -125,22 +167,3 @@ func (w *ExcelWriter) Cancel() error {
 	w.closed = true
 	return nil
 }
-
-// func excelHeaders(x interface{}) []interface{} {
-// 	var out []interface{}
-// 	reflectStructFields(x, func(name string, t reflect.Type, tag string) {
-// 		tag = firstTagValue(tag)
-// 		out = append(out, tag)
-// 	})
-// 	return out
-// }
-
-func excelValuesFrom(x interface{}) []interface{} {
-	// TODO This is badly named, it's not just used by ExcelWriter.
-	var out []interface{}
-	reflectStructValues(x, func(name string, t reflect.Type, v interface{}, tag string) {
-		// Add value to list.
-		out = append(out, v)
-	})
-	return out
-}
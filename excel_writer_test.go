@@ -2,6 +2,7 @@ package peanut_test
 
 import (
 	"os"
+	"reflect"
 
 	"github.com/360EntSecGroup-Skylar/excelize/v2"
 	. "github.com/onsi/ginkgo"
@@ -115,6 +116,77 @@ var _ = Describe("ExcelWriter", func() {
 		Expect(err).ToNot(BeNil())
 	})
 
+	It("should write time fields as date cells", func() {
+		w := newFn("-times")
+
+		testWritesTimesAndClose(w)
+		defer os.Remove("./test/output-Times-times.xlsx")
+
+		f, err := excelize.OpenFile("./test/output-Times-times.xlsx")
+		Expect(err).To(BeNil())
+		for _, axis := range []string{"B2", "C2", "D2"} {
+			s, err := f.GetCellStyle("Times", axis)
+			Expect(err).To(BeNil())
+			Expect(s).ToNot(BeZero())
+		}
+		v, err := f.GetCellValue("Times", "A2")
+		Expect(err).To(BeNil())
+		Expect(v).To(Equal("t1"))
+	})
+
+	It("should write null values as empty cells", func() {
+		w := newFn("-nullable")
+
+		testWritesNullableAndClose(w)
+		defer os.Remove("./test/output-Nullable-nullable.xlsx")
+
+		output, err := readExcel("./test/output-Nullable-nullable.xlsx")
+		Expect(err).To(BeNil())
+		Expect(output[1][:5]).To(Equal([]string{"n1", "1", "test 1", "test 1", "1"}))
+		Expect(output[2]).To(Equal([]string{"n2", "", "", "", "", "", ""}))
+	})
+
+	It("should use the name given by a type implementing Namer", func() {
+		w := newFn("-namer")
+
+		err := w.Write(&SelfNamed{ID: "s1"})
+		Expect(err).To(BeNil())
+		err = w.Close()
+		Expect(err).To(BeNil())
+		defer os.Remove("./test/output-self_named_v2-namer.xlsx")
+
+		output, err := readExcel("./test/output-self_named_v2-namer.xlsx")
+		Expect(err).To(BeNil())
+		Expect(output).To(Equal([][]string{{"id"}, {"s1"}}))
+
+		f, err := excelize.OpenFile("./test/output-self_named_v2-namer.xlsx")
+		Expect(err).To(BeNil())
+		Expect(f.GetSheetList()).To(Equal([]string{"self_named_v2"}))
+	})
+
+	It("should name sheets within Excel's limits", func() {
+		w := peanut.NewExcelWriter("./test/output-", "-sheet")
+		w.NameFunc = func(reflect.Type) string { return "Report:2021*Q1?[draft]-and-some-more-text" }
+
+		err := w.Write(testOutputFoo[0])
+		Expect(err).To(BeNil())
+		err = w.Close()
+		Expect(err).To(BeNil())
+		filename := "./test/output-Report:2021*Q1?[draft]-and-some-more-text-sheet.xlsx"
+		defer os.Remove(filename)
+
+		f, err := excelize.OpenFile(filename)
+		Expect(err).To(BeNil())
+		Expect(f.GetSheetList()).To(Equal([]string{"Report_2021_Q1__draft_-and-some"}))
+
+		r := peanut.NewExcelReader("./test/output-", "-sheet")
+		r.NameFunc = w.NameFunc
+		defer r.Close()
+		foo, err := readAll[Foo](r)
+		Expect(err).To(BeNil())
+		Expect(foo).To(Equal(testOutputFoo[:1]))
+	})
+
 	Context("when given a struct with an unsupported field type", func() {
 
 		It("should return an error with an informative message", func() {
@@ -133,7 +205,7 @@ func readExcel(filename string) ([][]string, error) {
 		return nil, err
 	}
 	var out [][]string
-	rows, err := f.GetRows("Sheet1")
+	rows, err := f.GetRows(f.GetSheetName(0))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,376 @@
+package peanut
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var _ Writer = &FixedWidthWriter{}
+
+// FixedWidthWriter writes records to fixed-width text files,
+// writing each record type to an individual file automatically.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".txt"
+//
+// If Compression is set, files are compressed accordingly,
+// and named with its Extension, as in ".txt.gz".
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Each record is written as a line of text, with its fields in
+// the order that they appear within the struct, each occupying
+// the number of characters given by the width option of its tag.
+// Values are aligned to the left, unless the tag has an align
+// option of right, and are padded with spaces, unless the tag has
+// a pad option giving another character:
+//  type Payment struct {
+//  	Account string  `peanut:"account,width=8"`
+//  	Amount  float64 `peanut:"amount,width=12,align=right,pad=0"`
+//  }
+// Numbers padded with zeros keep any sign as their first character.
+//
+// Values are written as text, as by CSVWriter. Values longer
+// than their width result in an error, unless Truncate is set,
+// in which case they are truncated to their width, keeping their
+// leading characters. Numbers are never truncated, as that would
+// change their value, so numbers longer than their width always
+// result in an error. Values containing line breaks also result
+// in an error.
+//
+// If Header is set, the first line of each file contains the
+// names extracted from the struct's field tags, aligned and
+// padded with spaces.
+//
+// Null values, from nil pointers and invalid sql.Null* values,
+// are written using NullValue, which is empty by default,
+// padded with spaces.
+//
+// The caller must call Close on successful completion
+// of all writing, to ensure buffers are flushed and
+// files are properly written to disk.
+//
+// In the event of an error or cancellation, the
+// caller must call Cancel before quiting, to ensure
+// closure and cleanup of any partially written files.
+type FixedWidthWriter struct {
+	*base
+	NullValue     string      // NullValue is the text written for null values.
+	NameFunc      NameFunc    // NameFunc names the output files, TypeName is used if nil.
+	Compression   Compression // Compression compresses the output files, which are uncompressed by default.
+	Header        bool        // Header writes a header line to each file.
+	Truncate      bool        // Truncate truncates values that overflow their width.
+	prefix        string
+	suffix        string
+	builderByType map[reflect.Type]*fixedWidthBuilder
+}
+
+// NewFixedWidthWriter returns a new FixedWidthWriter, using prefix
+// and suffix when building its output filenames.
+//
+// See FixedWidthWriter (above) for output filename details.
+func NewFixedWidthWriter(prefix, suffix string) *FixedWidthWriter {
+	w := FixedWidthWriter{
+		base:          &base{format: formatFixedWidth},
+		prefix:        prefix,
+		suffix:        suffix,
+		builderByType: make(map[reflect.Type]*fixedWidthBuilder),
+	}
+	return &w
+}
+
+type fixedWidthBuilder struct {
+	filename string
+	file     *os.File
+	zw       io.WriteCloser // zw compresses output written to file.
+	bw       *bufio.Writer
+	columns  []fixedWidthColumn
+	values   []interface{} // values is reused for each record written.
+	line     []byte        // line is reused for each record written.
+}
+
+// fixedWidthColumn holds the layout of a column, from its field's tag.
+type fixedWidthColumn struct {
+	width   int
+	right   bool // right aligns values to the right.
+	pad     rune
+	numeric bool // numeric columns are never truncated.
+}
+
+// fixedWidthColumns returns the layout of each column of plan p,
+// as given by the options of its fields' tags.
+func fixedWidthColumns(p *typePlan) ([]fixedWidthColumn, error) {
+	columns := make([]fixedWidthColumn, len(p.fields))
+	for i, f := range p.fields {
+		c := fixedWidthColumn{pad: ' '}
+		s, ok := tagOptionValue(f.tag, "width")
+		if !ok {
+			return nil, fmt.Errorf("peanut: missing width option for field %s.%s", p.name, f.name)
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("peanut: invalid width option for field %s.%s: %q", p.name, f.name, s)
+		}
+		c.width = n
+		if ct := columnType(f.typ, formatFixedWidth); ct != durationType {
+			switch ct.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+				reflect.Float32, reflect.Float64:
+				c.numeric = true
+			}
+		}
+		if s, ok := tagOptionValue(f.tag, "align"); ok {
+			switch s {
+			case "left":
+			case "right":
+				c.right = true
+			default:
+				return nil, fmt.Errorf("peanut: invalid align option for field %s.%s: %q", p.name, f.name, s)
+			}
+		}
+		if s, ok := tagOptionValue(f.tag, "pad"); ok {
+			r, size := utf8.DecodeRuneInString(s)
+			if size == 0 || size != len(s) {
+				return nil, fmt.Errorf("peanut: invalid pad option for field %s.%s: %q", p.name, f.name, s)
+			}
+			c.pad = r
+		}
+		columns[i] = c
+	}
+	return columns, nil
+}
+
+// appendTo appends s to dst, aligned and padded to the column's width.
+// Values that overflow the width are truncated if truncate is true,
+// otherwise ok is false and dst is returned unchanged.
+func (c fixedWidthColumn) appendTo(dst []byte, s string, pad rune, truncate bool) (_ []byte, ok bool) {
+	n := utf8.RuneCountInString(s)
+	if n > c.width {
+		if !truncate {
+			return dst, false
+		}
+		// Keep the leading characters.
+		i := 0
+		for j := 0; j < c.width; j++ {
+			_, size := utf8.DecodeRuneInString(s[i:])
+			i += size
+		}
+		s, n = s[:i], c.width
+	}
+	if !c.right {
+		dst = append(dst, s...)
+	}
+	if c.right && pad == '0' && n > 0 && (s[0] == '-' || s[0] == '+') {
+		// Keep the sign before any zeros.
+		dst = append(dst, s[0])
+		s = s[1:]
+	}
+	for i := n; i < c.width; i++ {
+		dst = utf8.AppendRune(dst, pad)
+	}
+	if c.right {
+		dst = append(dst, s...)
+	}
+	return dst, true
+}
+
+func (w *FixedWidthWriter) register(x interface{}) (reflect.Type, error) {
+	// Register with base writer.
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t, nil
+	}
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, err
+	}
+	if len(w.base.tagsByType[t]) == 0 {
+		return t, nil
+	}
+	p := w.planByType[t]
+	columns, err := fixedWidthColumns(p)
+	if err != nil {
+		return nil, err
+	}
+	var header []byte
+	if w.Header {
+		for i, h := range w.headersByType[t] {
+			header, ok = columns[i].appendTo(header, h, ' ', w.Truncate)
+			if !ok {
+				return nil, fmt.Errorf("peanut: header of field %s.%s overflows width %d", p.name, p.fields[i].name, columns[i].width)
+			}
+		}
+	}
+
+	// log.Printf("Setting up fixed-width writer for %s", t.Name())
+
+	name := w.prefix + w.nameByType[t] + w.suffix + ".txt" + w.Compression.Extension()
+	file, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		return nil, err
+	}
+	zw, err := w.Compression.newWriter(file)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	b := &fixedWidthBuilder{filename: name, file: file, zw: zw, bw: bufio.NewWriter(zw), columns: columns}
+	w.builderByType[t] = b
+
+	if w.Header {
+		b.bw.Write(header)
+		err = b.bw.WriteByte('\n')
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Write is called to persist records.
+// Each record is written to an individual line
+// in the corresponding output file, according to the
+// type of the given record.
+func (w *FixedWidthWriter) Write(x interface{}) error {
+	if w.closed {
+		return ErrClosedWriter
+	}
+	t, err := w.register(x)
+	if err != nil {
+		return err
+	}
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *FixedWidthWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	var err error
+	// log.Printf("WriteRecord for %s", t.Name())
+	b := w.builderByType[t]
+	b.values, err = p.values(x, b.values[:0])
+	if err != nil {
+		return err
+	}
+
+	// Build the whole line before writing,
+	// so that no partial line is written.
+	b.line = b.line[:0]
+	for i, val := range b.values {
+		c := b.columns[i]
+		s, pad := w.NullValue, ' '
+		if val != nil {
+			s, pad = formatValue(val, p.fields[i].layout), c.pad
+		}
+		if strings.ContainsAny(s, "\r\n") {
+			return fmt.Errorf("peanut: value of field %s.%s contains a line break: %q", p.name, p.fields[i].name, s)
+		}
+		var ok bool
+		b.line, ok = c.appendTo(b.line, s, pad, w.Truncate && !c.numeric)
+		if !ok {
+			return fmt.Errorf("peanut: value of field %s.%s overflows width %d: %q", p.name, p.fields[i].name, c.width, s)
+		}
+	}
+	b.line = append(b.line, '\n')
+	_, err = b.bw.Write(b.line)
+	return err
+}
+
+// Close flushes all buffers and writers,
+// and closes the output files.
+//
+// Calling Close after a previous call to
+// Cancel is safe, and always results in a no-op.
+func (w *FixedWidthWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, b := range w.builderByType {
+		var cerr error
+		var err error
+
+		err = b.bw.Flush()
+		if err != nil {
+			cerr = err
+		}
+
+		err = b.zw.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		// Chmod the file world-readable (ioutil.TempFile creates files with
+		// mode 0600) before renaming.
+		err = b.file.Chmod(0644)
+		if err != nil {
+			cerr = err
+		}
+
+		// fsync(2) after fchmod(2) orders writes as per
+		// https://lwn.net/Articles/270891/.
+		b.file.Sync()
+
+		err = b.file.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		if cerr != nil {
+			rerr = cerr
+			continue
+		}
+
+		err = os.Rename(b.file.Name(), b.filename)
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// Cancel should be called in the event of an error occurring,
+// to properly close and delete any partially written files.
+func (w *FixedWidthWriter) Cancel() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, b := range w.builderByType {
+		var err error
+
+		// Release any resources held by the compressor.
+		b.zw.Close()
+
+		err = b.file.Close()
+		if err != nil {
+			rerr = err
+		}
+
+		err = os.Remove(b.file.Name())
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
@@ -0,0 +1,173 @@
+package peanut_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+type Payment struct {
+	Account string  `peanut:"account,width=8"`
+	Amount  float64 `peanut:"amount,width=10,align=right,pad=0"`
+	Count   int     `peanut:"count,width=5,align=right"`
+	Note    *string `peanut:"note,width=6,pad=."`
+}
+
+type PaymentNoWidth struct {
+	Account string `peanut:"account,width=8"`
+	Amount  int    `peanut:"amount"`
+}
+
+type PaymentBadAlign struct {
+	Account string `peanut:"account,width=8,align=middle"`
+}
+
+type PaymentBadPad struct {
+	Account string `peanut:"account,width=8,pad=ab"`
+}
+
+var _ = Describe("FixedWidthWriter", func() {
+
+	AfterEach(func() {
+		os.Remove("./test/output-Payment-basic.txt")
+		os.Remove("./test/output-Payment-header.txt")
+		os.Remove("./test/output-Payment-truncate.txt")
+		os.Remove("./test/output-Payment-overflow.txt")
+	})
+
+	note := "hi"
+	testOutputPayment := []*Payment{
+		{Account: "ACC1", Amount: 12.5, Count: 3, Note: &note},
+		{Account: "ACC-0002", Amount: -7.25, Count: -42},
+	}
+
+	It("should write fields aligned and padded to their widths", func() {
+		w := peanut.NewFixedWidthWriter("./test/output-", "-basic")
+		w.NullValue = "-"
+		for _, x := range testOutputPayment {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		Expect(w.Close()).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Payment-basic.txt")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("" +
+			"ACC1    00000012.5    3hi....\n" +
+			"ACC-0002-000007.25  -42-     \n"))
+	})
+
+	It("should write a header line when Header is set", func() {
+		w := peanut.NewFixedWidthWriter("./test/output-", "-header")
+		w.Header = true
+		Expect(w.Write(testOutputPayment[0])).To(BeNil())
+		Expect(w.Close()).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Payment-header.txt")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("" +
+			"account     amountcountnote  \n" +
+			"ACC1    00000012.5    3hi....\n"))
+	})
+
+	It("should truncate values that overflow their width when Truncate is set", func() {
+		w := peanut.NewFixedWidthWriter("./test/output-", "-truncate")
+		w.Truncate = true
+		long := "résumé!"
+		Expect(w.Write(&Payment{Account: "ACCOUNT-123", Amount: 1, Count: 12345, Note: &long})).To(BeNil())
+
+		// Numbers are never truncated.
+		err := w.Write(&Payment{Account: "ACC1", Count: 123456})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Payment.Count"))
+		Expect(err.Error()).To(ContainSubstring("overflows width 5"))
+		Expect(w.Close()).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Payment-truncate.txt")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("ACCOUNT-000000000112345résumé\n"))
+	})
+
+	It("should return an error when a value overflows its width", func() {
+		w := peanut.NewFixedWidthWriter("./test/output-", "-overflow")
+		Expect(w.Write(testOutputPayment[0])).To(BeNil())
+		err := w.Write(&Payment{Account: "ACCOUNT-123"})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Payment.Account"))
+		Expect(err.Error()).To(ContainSubstring("overflows width 8"))
+		Expect(w.Close()).To(BeNil())
+
+		// No partial line should be written.
+		output, err := ioutil.ReadFile("./test/output-Payment-overflow.txt")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("ACC1    00000012.5    3hi....\n"))
+	})
+
+	It("should return an error when a value contains a line break", func() {
+		w := peanut.NewFixedWidthWriter("./test/output-", "-overflow")
+		w.Truncate = true
+		Expect(w.Write(testOutputPayment[0])).To(BeNil())
+		err := w.Write(&Payment{Account: "ACC\n2"})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Payment.Account contains a line break"))
+		note := "a\rb"
+		err = w.Write(&Payment{Account: "ACC3", Note: &note})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Payment.Note contains a line break"))
+		Expect(w.Close()).To(BeNil())
+
+		// No partial line should be written.
+		output, err := ioutil.ReadFile("./test/output-Payment-overflow.txt")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("ACC1    00000012.5    3hi....\n"))
+	})
+
+	It("should return an error when tag options are missing or invalid", func() {
+		w := peanut.NewFixedWidthWriter("./test/output-", "-invalid")
+
+		err := w.Write(&PaymentNoWidth{})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("missing width option for field PaymentNoWidth.Amount"))
+
+		err = w.Write(&PaymentBadAlign{})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("invalid align option"))
+
+		err = w.Write(&PaymentBadPad{})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("invalid pad option"))
+
+		err = w.Write(testOutputFoo[0])
+		Expect(err).ToNot(BeNil())
+
+		Expect(w.Cancel()).To(BeNil())
+	})
+
+	It("should not write anything when structs are written and cancel is called", func() {
+		w := peanut.NewFixedWidthWriter("./test/output-", "-cancel")
+
+		Expect(w.Write(testOutputPayment[0])).To(BeNil())
+		Expect(w.Cancel()).To(BeNil())
+
+		Expect("./test/output-Payment-cancel.txt").ToNot(BeAnExistingFile())
+	})
+
+	It("should return an error when Write is called after Close", func() {
+		w := peanut.NewFixedWidthWriter("./test/output-", "-close-write")
+
+		Expect(w.Close()).To(BeNil())
+		Expect(w.Write(testOutputPayment[0])).To(Equal(peanut.ErrClosedWriter))
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			w := peanut.NewFixedWidthWriter("./no-such-location/output-bogus-", "")
+
+			testWriteBadType(w)
+		})
+	})
+})
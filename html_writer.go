@@ -0,0 +1,276 @@
+package peanut
+
+import (
+	"bufio"
+	"html"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+)
+
+var _ Writer = &HTMLWriter{}
+
+// HTMLWriter writes records to HTML files, as tables,
+// writing each record type to an individual HTML file
+// automatically.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".html"
+//
+// If Compression is set, files are compressed accordingly,
+// and named with its Extension, as in ".html.gz".
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Each file holds a single table element, suitable for
+// including within a larger document. The table's header
+// row contains the names extracted from the struct's field
+// tags, and records' fields are written in the order that
+// they appear within the struct.
+//
+// If TableClass is non-empty, it is used as the class attribute
+// of each table. If ColumnClass is non-nil, it is called with
+// each column's header, and the class it returns, if non-empty,
+// is used as the class attribute of the column's cells.
+//
+// Values are written as text, as by CSVWriter, and are escaped.
+//
+// Null values, from nil pointers and invalid sql.Null* values,
+// are written using NullValue, which is empty by default.
+//
+// The caller must call Close on successful completion
+// of all writing, to ensure buffers are flushed and
+// files are properly written to disk.
+//
+// In the event of an error or cancellation, the
+// caller must call Cancel before quiting, to ensure
+// closure and cleanup of any partially written files.
+type HTMLWriter struct {
+	*base
+	NullValue     string                     // NullValue is the text written for null values.
+	NameFunc      NameFunc                   // NameFunc names the output files, TypeName is used if nil.
+	Compression   Compression                // Compression compresses the output files, which are uncompressed by default.
+	TableClass    string                     // TableClass is the class of each table.
+	ColumnClass   func(header string) string // ColumnClass returns the class of each column's cells.
+	prefix        string
+	suffix        string
+	builderByType map[reflect.Type]*htmlBuilder
+}
+
+// NewHTMLWriter returns a new HTMLWriter, using prefix
+// and suffix when building its output filenames.
+//
+// See HTMLWriter (above) for output filename details.
+func NewHTMLWriter(prefix, suffix string) *HTMLWriter {
+	w := HTMLWriter{
+		base:          &base{format: formatHTML},
+		prefix:        prefix,
+		suffix:        suffix,
+		builderByType: make(map[reflect.Type]*htmlBuilder),
+	}
+	return &w
+}
+
+type htmlBuilder struct {
+	filename string
+	file     *os.File
+	zw       io.WriteCloser // zw compresses output written to file.
+	bw       *bufio.Writer
+	th       []string // th holds the opening tag of each header cell.
+	td       []string // td holds the opening tag of each data cell.
+	row      []string // row is reused for each record written.
+}
+
+// writeRow writes cells as a row of a table, using the given opening tags.
+func (b *htmlBuilder) writeRow(cells []string, tags []string, end string) error {
+	b.bw.WriteString("<tr>")
+	for i, s := range cells {
+		b.bw.WriteString(tags[i])
+		b.bw.WriteString(html.EscapeString(s))
+		b.bw.WriteString(end)
+	}
+	_, err := b.bw.WriteString("</tr>\n")
+	return err
+}
+
+func (w *HTMLWriter) register(x interface{}) (reflect.Type, error) {
+	// Register with base writer.
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t, nil
+	}
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, err
+	}
+	if len(w.base.tagsByType[t]) == 0 {
+		return t, nil
+	}
+
+	// log.Printf("Setting up HTML writer for %s", t.Name())
+
+	name := w.prefix + w.nameByType[t] + w.suffix + ".html" + w.Compression.Extension()
+	file, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		return nil, err
+	}
+	zw, err := w.Compression.newWriter(file)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	headers := w.headersByType[t]
+	b := &htmlBuilder{
+		filename: name,
+		file:     file,
+		zw:       zw,
+		bw:       bufio.NewWriter(zw),
+		th:       make([]string, len(headers)),
+		td:       make([]string, len(headers)),
+	}
+	for i, h := range headers {
+		b.th[i], b.td[i] = "<th>", "<td>"
+		if w.ColumnClass == nil {
+			continue
+		}
+		if class := w.ColumnClass(h); class != "" {
+			attr := ` class="` + html.EscapeString(class) + `">`
+			b.th[i], b.td[i] = "<th"+attr, "<td"+attr
+		}
+	}
+	w.builderByType[t] = b
+
+	b.bw.WriteString("<table")
+	if w.TableClass != "" {
+		b.bw.WriteString(` class="` + html.EscapeString(w.TableClass) + `"`)
+	}
+	b.bw.WriteString(">\n<thead>\n")
+	b.writeRow(headers, b.th, "</th>")
+	_, err = b.bw.WriteString("</thead>\n<tbody>\n")
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Write is called to persist records.
+// Each record is written to an individual row
+// in the corresponding output file, according to the
+// type of the given record.
+func (w *HTMLWriter) Write(x interface{}) error {
+	if w.closed {
+		return ErrClosedWriter
+	}
+	t, err := w.register(x)
+	if err != nil {
+		return err
+	}
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *HTMLWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	var err error
+	// log.Printf("WriteRecord for %s", t.Name())
+	b := w.builderByType[t]
+	b.row, err = p.strings(x, w.NullValue, b.row[:0])
+	if err != nil {
+		return err
+	}
+	return b.writeRow(b.row, b.td, "</td>")
+}
+
+// Close flushes all buffers and writers,
+// and closes the output files.
+//
+// Calling Close after a previous call to
+// Cancel is safe, and always results in a no-op.
+func (w *HTMLWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, b := range w.builderByType {
+		var cerr error
+		var err error
+
+		// End the table.
+		b.bw.WriteString("</tbody>\n</table>\n")
+		err = b.bw.Flush()
+		if err != nil {
+			cerr = err
+		}
+
+		err = b.zw.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		// Chmod the file world-readable (ioutil.TempFile creates files with
+		// mode 0600) before renaming.
+		err = b.file.Chmod(0644)
+		if err != nil {
+			cerr = err
+		}
+
+		// fsync(2) after fchmod(2) orders writes as per
+		// https://lwn.net/Articles/270891/.
+		b.file.Sync()
+
+		err = b.file.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		if cerr != nil {
+			rerr = cerr
+			continue
+		}
+
+		err = os.Rename(b.file.Name(), b.filename)
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// Cancel should be called in the event of an error occurring,
+// to properly close and delete any partially written files.
+func (w *HTMLWriter) Cancel() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, b := range w.builderByType {
+		var err error
+
+		// Release any resources held by the compressor.
+		b.zw.Close()
+
+		err = b.file.Close()
+		if err != nil {
+			rerr = err
+		}
+
+		err = os.Remove(b.file.Name())
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
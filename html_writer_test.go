@@ -0,0 +1,159 @@
+package peanut_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+var _ = Describe("HTMLWriter", func() {
+
+	newFn := func(suffix string) peanut.Writer {
+		w := peanut.NewHTMLWriter("./test/output-", suffix)
+		return w
+	}
+
+	expectedOutput1 := "<table>\n<thead>\n" +
+		"<tr><th>foo_string</th><th>foo_int</th></tr>\n" +
+		"</thead>\n<tbody>\n" +
+		"<tr><td>test 1</td><td>1</td></tr>\n" +
+		"<tr><td>test 2</td><td>2</td></tr>\n" +
+		"<tr><td>test 3</td><td>3</td></tr>\n" +
+		"</tbody>\n</table>\n"
+
+	expectedOutput2 := "<table>\n<thead>\n" +
+		"<tr><th>bar_int</th><th>bar_string</th></tr>\n" +
+		"</thead>\n<tbody>\n" +
+		"<tr><td>1</td><td>test 1</td></tr>\n" +
+		"<tr><td>2</td><td>test 2</td></tr>\n" +
+		"<tr><td>3</td><td>test 3</td></tr>\n" +
+		"</tbody>\n</table>\n"
+
+	AfterEach(func() {
+		os.Remove("./test/output-Foo-sequential.html")
+		os.Remove("./test/output-Bar-sequential.html")
+		os.Remove("./test/output-Baz-sequential.html")
+		os.Remove("./test/output-Qux-sequential.html")
+		os.Remove("./test/output-Foo-interleave.html")
+		os.Remove("./test/output-Bar-interleave.html")
+		os.Remove("./test/output-Baz-interleave.html")
+		os.Remove("./test/output-Qux-interleave.html")
+		os.Remove("./test/output-Foo-escape.html")
+		os.Remove("./test/output-Foo-class.html")
+		os.Remove("./test/output-Nullable-nullable.html")
+	})
+
+	It("should write the correct data when sequential structs are written", func() {
+		w := newFn("-sequential")
+
+		testWritesAndCloseSequential(w)
+
+		output1, err := ioutil.ReadFile("./test/output-Foo-sequential.html")
+		Expect(err).To(BeNil())
+		Expect(string(output1)).To(Equal(expectedOutput1))
+
+		output2, err := ioutil.ReadFile("./test/output-Bar-sequential.html")
+		Expect(err).To(BeNil())
+		Expect(string(output2)).To(Equal(expectedOutput2))
+
+		output3, err := ioutil.ReadFile("./test/output-Baz-sequential.html")
+		Expect(err).To(BeNil())
+		Expect(string(output3)).To(ContainSubstring("<tr><td>test 1</td><td>true</td><td>1.234</td>"))
+
+		Expect("./test/output-Qux-sequential.html").ToNot(BeAnExistingFile())
+	})
+
+	It("should write the correct data when interleaved structs are written", func() {
+		w := newFn("-interleave")
+
+		testWritesAndCloseInterleaved(w)
+
+		output1, err := ioutil.ReadFile("./test/output-Foo-interleave.html")
+		Expect(err).To(BeNil())
+		Expect(string(output1)).To(Equal(expectedOutput1))
+
+		output2, err := ioutil.ReadFile("./test/output-Bar-interleave.html")
+		Expect(err).To(BeNil())
+		Expect(string(output2)).To(Equal(expectedOutput2))
+
+		Expect("./test/output-Qux-interleave.html").ToNot(BeAnExistingFile())
+	})
+
+	It("should escape values", func() {
+		w := newFn("-escape")
+
+		err := w.Write(&Foo{StringField: `<b>"bold"</b> & 'more'`, IntField: 1})
+		Expect(err).To(BeNil())
+		err = w.Close()
+		Expect(err).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Foo-escape.html")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(ContainSubstring("<tr><td>&lt;b&gt;&#34;bold&#34;&lt;/b&gt; &amp; &#39;more&#39;</td><td>1</td></tr>\n"))
+	})
+
+	It("should use the configured table and column classes", func() {
+		w := peanut.NewHTMLWriter("./test/output-", "-class")
+		w.TableClass = "report"
+		w.ColumnClass = func(header string) string {
+			if header == "foo_int" {
+				return "number"
+			}
+			return ""
+		}
+
+		err := w.Write(testOutputFoo[0])
+		Expect(err).To(BeNil())
+		err = w.Close()
+		Expect(err).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Foo-class.html")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal(`<table class="report">` + "\n<thead>\n" +
+			`<tr><th>foo_string</th><th class="number">foo_int</th></tr>` + "\n" +
+			"</thead>\n<tbody>\n" +
+			`<tr><td>test 1</td><td class="number">1</td></tr>` + "\n" +
+			"</tbody>\n</table>\n"))
+	})
+
+	It("should write null values using NullValue", func() {
+		w := peanut.NewHTMLWriter("./test/output-", "-nullable")
+		w.NullValue = "<null>"
+
+		testWritesNullableAndClose(w)
+
+		output, err := ioutil.ReadFile("./test/output-Nullable-nullable.html")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(ContainSubstring("<tr><td>n2</td><td>&lt;null&gt;</td>"))
+	})
+
+	It("should not write anything when structs are written and cancel is called", func() {
+		w := newFn("-cancel")
+
+		testWritesAndCancel(w)
+
+		Expect("./test/output-Foo-cancel.html").ToNot(BeAnExistingFile())
+		Expect("./test/output-Bar-cancel.html").ToNot(BeAnExistingFile())
+	})
+
+	It("should return an error when Write is called after Close", func() {
+		w := newFn("-close-write")
+
+		testWriteAfterClose(w)
+
+		Expect("./test/output-Foo-close-write.html").ToNot(BeAnExistingFile())
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			w := peanut.NewHTMLWriter("./no-such-location/output-bogus-", "")
+
+			testWriteBadType(w)
+		})
+	})
+})
@@ -0,0 +1,65 @@
+// Package gentest holds record types having methods generated by
+// peanutgen, used to test the generated code against peanut's
+// reflective encoding.
+package gentest
+
+import (
+	"database/sql"
+	"time"
+)
+
+//go:generate go run ../../cmd/peanutgen -type=Record,Shape -output=gentest_peanut.go
+
+// Level is a named type without methods.
+type Level int8
+
+// Audit is embedded, and its fields promoted.
+type Audit struct {
+	CreatedBy string `peanut:"created_by"`
+}
+
+// Address is nested inline.
+type Address struct {
+	Street string `peanut:"street"`
+	City   string `peanut:"city"`
+}
+
+// Record has fields of every type supported by peanutgen.
+type Record struct {
+	String   string         `peanut:"string,pk"`
+	Bool     bool           `peanut:"bool"`
+	Float32  float32        `peanut:"float32"`
+	Float64  float64        `peanut:"float64"`
+	Int      int            `peanut:"int"`
+	Int8     int8           `peanut:"int8"`
+	Int16    int16          `peanut:"int16"`
+	Int32    int32          `peanut:"int32"`
+	Int64    int64          `peanut:"int64"`
+	Uint     uint           `peanut:"uint"`
+	Uint8    uint8          `peanut:"uint8"`
+	Uint16   uint16         `peanut:"uint16"`
+	Uint32   uint32         `peanut:"uint32"`
+	Uint64   uint64         `peanut:"uint64"`
+	Level    Level          `peanut:"level"`
+	Time     time.Time      `peanut:"time"`
+	Date     time.Time      `peanut:"date,format=2006-01-02"`
+	Duration time.Duration  `peanut:"duration"`
+	PtrInt   *int           `peanut:"ptr_int"`
+	PtrTime  *time.Time     `peanut:"ptr_time"`
+	PtrDur   *time.Duration `peanut:"ptr_duration"`
+	NullStr  sql.NullString `peanut:"null_string"`
+	NullInt  sql.NullInt64  `peanut:"null_int"`
+	NullTime sql.NullTime   `peanut:"null_time"`
+	Home     Address        `peanut:"home,inline"`
+	Work     *Address       `peanut:",inline,prefix=work_"`
+	ignored  string         `peanut:"ignored"`
+	Untagged string
+	Audit
+}
+
+// Shape is a simple record type.
+type Shape struct {
+	ShapeID  string `peanut:"shape_id,pk"`
+	Name     string `peanut:"name"`
+	NumSides int    `peanut:"num_sides"`
+}
@@ -0,0 +1,294 @@
+// Code generated by "peanutgen -type=Record,Shape -output=gentest_peanut.go"; DO NOT EDIT.
+
+package gentest
+
+import (
+	"database/sql"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// PeanutHeaders returns the column names of Record's tagged fields.
+func (Record) PeanutHeaders() []string {
+	return []string{
+		"string",
+		"bool",
+		"float32",
+		"float64",
+		"int",
+		"int8",
+		"int16",
+		"int32",
+		"int64",
+		"uint",
+		"uint8",
+		"uint16",
+		"uint32",
+		"uint64",
+		"level",
+		"time",
+		"date",
+		"duration",
+		"ptr_int",
+		"ptr_time",
+		"ptr_duration",
+		"null_string",
+		"null_int",
+		"null_time",
+		"home_street",
+		"home_city",
+		"work_street",
+		"work_city",
+		"created_by",
+	}
+}
+
+// PeanutTags returns the tags of Record's tagged fields.
+func (Record) PeanutTags() []string {
+	return []string{
+		"string,pk",
+		"bool",
+		"float32",
+		"float64",
+		"int",
+		"int8",
+		"int16",
+		"int32",
+		"int64",
+		"uint",
+		"uint8",
+		"uint16",
+		"uint32",
+		"uint64",
+		"level",
+		"time",
+		"date,format=2006-01-02",
+		"duration",
+		"ptr_int",
+		"ptr_time",
+		"ptr_duration",
+		"null_string",
+		"null_int",
+		"null_time",
+		"home_street",
+		"home_city",
+		"work_street",
+		"work_city",
+		"created_by",
+	}
+}
+
+// PeanutTypes returns the types of Record's tagged fields.
+func (Record) PeanutTypes() []reflect.Type {
+	return []reflect.Type{
+		reflect.TypeOf((*string)(nil)).Elem(),
+		reflect.TypeOf((*bool)(nil)).Elem(),
+		reflect.TypeOf((*float32)(nil)).Elem(),
+		reflect.TypeOf((*float64)(nil)).Elem(),
+		reflect.TypeOf((*int)(nil)).Elem(),
+		reflect.TypeOf((*int8)(nil)).Elem(),
+		reflect.TypeOf((*int16)(nil)).Elem(),
+		reflect.TypeOf((*int32)(nil)).Elem(),
+		reflect.TypeOf((*int64)(nil)).Elem(),
+		reflect.TypeOf((*uint)(nil)).Elem(),
+		reflect.TypeOf((*uint8)(nil)).Elem(),
+		reflect.TypeOf((*uint16)(nil)).Elem(),
+		reflect.TypeOf((*uint32)(nil)).Elem(),
+		reflect.TypeOf((*uint64)(nil)).Elem(),
+		reflect.TypeOf((*Level)(nil)).Elem(),
+		reflect.TypeOf((*time.Time)(nil)).Elem(),
+		reflect.TypeOf((*time.Time)(nil)).Elem(),
+		reflect.TypeOf((*time.Duration)(nil)).Elem(),
+		reflect.TypeOf((**int)(nil)).Elem(),
+		reflect.TypeOf((**time.Time)(nil)).Elem(),
+		reflect.TypeOf((**time.Duration)(nil)).Elem(),
+		reflect.TypeOf((*sql.NullString)(nil)).Elem(),
+		reflect.TypeOf((*sql.NullInt64)(nil)).Elem(),
+		reflect.TypeOf((*sql.NullTime)(nil)).Elem(),
+		reflect.TypeOf((*string)(nil)).Elem(),
+		reflect.TypeOf((*string)(nil)).Elem(),
+		reflect.TypeOf((**string)(nil)).Elem(),
+		reflect.TypeOf((**string)(nil)).Elem(),
+		reflect.TypeOf((*string)(nil)).Elem(),
+	}
+}
+
+// PeanutValues appends the values of Record's tagged fields to dst,
+// using nil for null values, and returns the result.
+func (x Record) PeanutValues(dst []interface{}) []interface{} {
+	dst = append(dst, x.String)
+	dst = append(dst, x.Bool)
+	dst = append(dst, x.Float32)
+	dst = append(dst, x.Float64)
+	dst = append(dst, x.Int)
+	dst = append(dst, x.Int8)
+	dst = append(dst, x.Int16)
+	dst = append(dst, x.Int32)
+	dst = append(dst, x.Int64)
+	dst = append(dst, x.Uint)
+	dst = append(dst, x.Uint8)
+	dst = append(dst, x.Uint16)
+	dst = append(dst, x.Uint32)
+	dst = append(dst, x.Uint64)
+	dst = append(dst, x.Level)
+	dst = append(dst, x.Time)
+	dst = append(dst, x.Date)
+	dst = append(dst, x.Duration)
+	if x.PtrInt != nil {
+		dst = append(dst, *x.PtrInt)
+	} else {
+		dst = append(dst, nil)
+	}
+	if x.PtrTime != nil {
+		dst = append(dst, *x.PtrTime)
+	} else {
+		dst = append(dst, nil)
+	}
+	if x.PtrDur != nil {
+		dst = append(dst, *x.PtrDur)
+	} else {
+		dst = append(dst, nil)
+	}
+	if x.NullStr.Valid {
+		dst = append(dst, x.NullStr.String)
+	} else {
+		dst = append(dst, nil)
+	}
+	if x.NullInt.Valid {
+		dst = append(dst, x.NullInt.Int64)
+	} else {
+		dst = append(dst, nil)
+	}
+	if x.NullTime.Valid {
+		dst = append(dst, x.NullTime.Time)
+	} else {
+		dst = append(dst, nil)
+	}
+	dst = append(dst, x.Home.Street)
+	dst = append(dst, x.Home.City)
+	if x.Work != nil {
+		dst = append(dst, x.Work.Street)
+	} else {
+		dst = append(dst, nil)
+	}
+	if x.Work != nil {
+		dst = append(dst, x.Work.City)
+	} else {
+		dst = append(dst, nil)
+	}
+	dst = append(dst, x.Audit.CreatedBy)
+	return dst
+}
+
+// PeanutStrings appends the text of the values of Record's tagged fields
+// to dst, using null for null values, and returns the result.
+func (x Record) PeanutStrings(dst []string, null string) []string {
+	dst = append(dst, x.String)
+	dst = append(dst, strconv.FormatBool(x.Bool))
+	dst = append(dst, strconv.FormatFloat(float64(x.Float32), 'g', -1, 32))
+	dst = append(dst, strconv.FormatFloat(x.Float64, 'g', -1, 64))
+	dst = append(dst, strconv.FormatInt(int64(x.Int), 10))
+	dst = append(dst, strconv.FormatInt(int64(x.Int8), 10))
+	dst = append(dst, strconv.FormatInt(int64(x.Int16), 10))
+	dst = append(dst, strconv.FormatInt(int64(x.Int32), 10))
+	dst = append(dst, strconv.FormatInt(x.Int64, 10))
+	dst = append(dst, strconv.FormatUint(uint64(x.Uint), 10))
+	dst = append(dst, strconv.FormatUint(uint64(x.Uint8), 10))
+	dst = append(dst, strconv.FormatUint(uint64(x.Uint16), 10))
+	dst = append(dst, strconv.FormatUint(uint64(x.Uint32), 10))
+	dst = append(dst, strconv.FormatUint(x.Uint64, 10))
+	dst = append(dst, strconv.FormatInt(int64(x.Level), 10))
+	dst = append(dst, x.Time.Format("2006-01-02T15:04:05Z07:00"))
+	dst = append(dst, x.Date.Format("2006-01-02"))
+	dst = append(dst, x.Duration.String())
+	if x.PtrInt != nil {
+		dst = append(dst, strconv.FormatInt(int64(*x.PtrInt), 10))
+	} else {
+		dst = append(dst, null)
+	}
+	if x.PtrTime != nil {
+		dst = append(dst, (*x.PtrTime).Format("2006-01-02T15:04:05Z07:00"))
+	} else {
+		dst = append(dst, null)
+	}
+	if x.PtrDur != nil {
+		dst = append(dst, (*x.PtrDur).String())
+	} else {
+		dst = append(dst, null)
+	}
+	if x.NullStr.Valid {
+		dst = append(dst, x.NullStr.String)
+	} else {
+		dst = append(dst, null)
+	}
+	if x.NullInt.Valid {
+		dst = append(dst, strconv.FormatInt(x.NullInt.Int64, 10))
+	} else {
+		dst = append(dst, null)
+	}
+	if x.NullTime.Valid {
+		dst = append(dst, x.NullTime.Time.Format("2006-01-02T15:04:05Z07:00"))
+	} else {
+		dst = append(dst, null)
+	}
+	dst = append(dst, x.Home.Street)
+	dst = append(dst, x.Home.City)
+	if x.Work != nil {
+		dst = append(dst, x.Work.Street)
+	} else {
+		dst = append(dst, null)
+	}
+	if x.Work != nil {
+		dst = append(dst, x.Work.City)
+	} else {
+		dst = append(dst, null)
+	}
+	dst = append(dst, x.Audit.CreatedBy)
+	return dst
+}
+
+// PeanutHeaders returns the column names of Shape's tagged fields.
+func (Shape) PeanutHeaders() []string {
+	return []string{
+		"shape_id",
+		"name",
+		"num_sides",
+	}
+}
+
+// PeanutTags returns the tags of Shape's tagged fields.
+func (Shape) PeanutTags() []string {
+	return []string{
+		"shape_id,pk",
+		"name",
+		"num_sides",
+	}
+}
+
+// PeanutTypes returns the types of Shape's tagged fields.
+func (Shape) PeanutTypes() []reflect.Type {
+	return []reflect.Type{
+		reflect.TypeOf((*string)(nil)).Elem(),
+		reflect.TypeOf((*string)(nil)).Elem(),
+		reflect.TypeOf((*int)(nil)).Elem(),
+	}
+}
+
+// PeanutValues appends the values of Shape's tagged fields to dst,
+// using nil for null values, and returns the result.
+func (x Shape) PeanutValues(dst []interface{}) []interface{} {
+	dst = append(dst, x.ShapeID)
+	dst = append(dst, x.Name)
+	dst = append(dst, x.NumSides)
+	return dst
+}
+
+// PeanutStrings appends the text of the values of Shape's tagged fields
+// to dst, using null for null values, and returns the result.
+func (x Shape) PeanutStrings(dst []string, null string) []string {
+	dst = append(dst, x.ShapeID)
+	dst = append(dst, x.Name)
+	dst = append(dst, strconv.FormatInt(int64(x.NumSides), 10))
+	return dst
+}
@@ -0,0 +1,200 @@
+package peanut
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// jsonEncoder encodes records as JSON objects, with their
+// fields in declared order, as written by the JSON-based writers.
+type jsonEncoder struct {
+	plan    *typePlan
+	keys    [][]byte      // keys holds the encoded key of each field, including its colon.
+	buf     []byte        // buf holds the encoded record, and is reused.
+	scratch bytes.Buffer  // scratch is used when falling back to encoding/json.
+	enc     *json.Encoder // enc encodes to scratch.
+}
+
+func newJSONEncoder(p *typePlan) *jsonEncoder {
+	e := &jsonEncoder{
+		plan: p,
+		keys: make([][]byte, len(p.fields)),
+	}
+	e.enc = json.NewEncoder(&e.scratch)
+	e.enc.SetEscapeHTML(false)
+	for i, f := range p.fields {
+		key := appendJSONString(nil, f.header)
+		e.keys[i] = append(key, ':')
+	}
+	return e
+}
+
+// encode returns the encoding of a record with the given values,
+// without a trailing newline. The returned slice is only valid
+// until the next call to encode.
+func (e *jsonEncoder) encode(values []interface{}) ([]byte, error) {
+	b := append(e.buf[:0], '{')
+	for i, val := range values {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, e.keys[i]...)
+		var err error
+		b, err = e.appendValue(b, val, e.plan.fields[i].layout)
+		if err != nil {
+			e.buf = b
+			return nil, e.plan.errorf(e.plan.fields[i], err)
+		}
+	}
+	b = append(b, '}')
+	e.buf = b
+	return b, nil
+}
+
+// appendValue appends the encoding of val to b, encoding values
+// as encoding/json would, except for times and durations, which
+// are written as text.
+func (e *jsonEncoder) appendValue(b []byte, val interface{}, layout string) ([]byte, error) {
+	switch v := val.(type) {
+	case nil:
+		return append(b, "null"...), nil
+	case string:
+		return appendJSONString(b, v), nil
+	case bool:
+		return strconv.AppendBool(b, v), nil
+	case int64:
+		return strconv.AppendInt(b, v, 10), nil
+	case int:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case float64:
+		return e.appendFloat(b, val, v, 64)
+	case time.Time, time.Duration:
+		return appendJSONString(b, formatValue(v, layout)), nil
+	case json.RawMessage:
+		// As with encoding/json, raw values are compacted.
+		e.scratch.Reset()
+		err := json.Compact(&e.scratch, v)
+		if err != nil {
+			return b, err
+		}
+		return append(b, e.scratch.Bytes()...), nil
+	}
+
+	// Values of other types, including named types, are
+	// encoded according to their kind, where possible.
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.String:
+		return appendJSONString(b, rv.String()), nil
+	case reflect.Bool:
+		return strconv.AppendBool(b, rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(b, rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.AppendUint(b, rv.Uint(), 10), nil
+	case reflect.Float32:
+		return e.appendFloat(b, val, rv.Float(), 32)
+	case reflect.Float64:
+		return e.appendFloat(b, val, rv.Float(), 64)
+	}
+	return e.appendFallback(b, val)
+}
+
+// appendFloat appends the encoding of f, a float of the given bit size,
+// formatted as encoding/json formats floats.
+func (e *jsonEncoder) appendFloat(b []byte, val interface{}, f float64, bits int) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		// Let encoding/json report the error.
+		return e.appendFallback(b, val)
+	}
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) ||
+			bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b = strconv.AppendFloat(b, f, format, -1, bits)
+	if format == 'e' {
+		// Clean up e-09 to e-9.
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b, nil
+}
+
+// appendFallback appends the encoding of val, using encoding/json.
+func (e *jsonEncoder) appendFallback(b []byte, val interface{}) ([]byte, error) {
+	e.scratch.Reset()
+	err := e.enc.Encode(val)
+	if err != nil {
+		return b, err
+	}
+	return append(b, bytes.TrimSuffix(e.scratch.Bytes(), []byte("\n"))...), nil
+}
+
+// appendJSONString appends s to b as a JSON string,
+// escaped as by encoding/json without HTML escaping.
+func appendJSONString(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			// Invalid UTF-8 is replaced, as by encoding/json.
+			b = append(b, s[start:i]...)
+			b = append(b, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			// Line and paragraph separators are escaped,
+			// for the benefit of JavaScript.
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hex[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
@@ -0,0 +1,289 @@
+package peanut
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+)
+
+var _ Writer = &JSONWriter{}
+
+// JSONWriter writes records to JSON files, writing each
+// record type to an individual JSON file automatically.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".json"
+//
+// If Compression is set, files are compressed accordingly,
+// and named with its Extension, as in ".json.gz".
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Each file holds a single JSON document: an array containing
+// an object for each record, encoded as by JSONLWriter. If Keyed
+// is set, the array is instead the value of a single key in an
+// object, keyed by the name of the record type.
+//
+// If Indent is non-empty, the document is indented using it,
+// such as two spaces or a tab, otherwise it is written compactly.
+//
+// Records are streamed to disk as they are written,
+// and are not held in memory.
+//
+// The caller must call Close on successful completion
+// of all writing, to ensure buffers are flushed and
+// files are properly written to disk.
+//
+// In the event of an error or cancellation, the
+// caller must call Cancel before quiting, to ensure
+// closure and cleanup of any partially written files.
+type JSONWriter struct {
+	*base
+	NameFunc      NameFunc    // NameFunc names the output files, TypeName is used if nil.
+	Compression   Compression // Compression compresses the output files, which are uncompressed by default.
+	Keyed         bool        // Keyed wraps each array in an object, keyed by type name.
+	Indent        string      // Indent is used to indent output, which is compact if empty.
+	prefix        string
+	suffix        string
+	builderByType map[reflect.Type]*jsonBuilder
+}
+
+// NewJSONWriter returns a new JSONWriter, using prefix
+// and suffix when building its output filenames.
+//
+// See JSONWriter (above) for output filename details.
+func NewJSONWriter(prefix, suffix string) *JSONWriter {
+	w := JSONWriter{
+		base:          &base{format: formatJSON},
+		prefix:        prefix,
+		suffix:        suffix,
+		builderByType: make(map[reflect.Type]*jsonBuilder),
+	}
+	return &w
+}
+
+type jsonBuilder struct {
+	filename string
+	file     *os.File
+	zw       io.WriteCloser // zw compresses output written to file.
+	bw       *bufio.Writer
+	enc      *jsonEncoder
+	buf      bytes.Buffer  // buf holds each indented record.
+	indent   string        // indent is used to indent each level of the document.
+	newline  string        // newline precedes each record, and the closing bracket.
+	closing  string        // closing ends the document.
+	rows     int           // rows is the number of records written.
+	values   []interface{} // values is reused for each record written.
+}
+
+func (w *JSONWriter) register(x interface{}) (reflect.Type, error) {
+	// Register with base writer.
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t, nil
+	}
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, err
+	}
+	if len(w.base.tagsByType[t]) == 0 {
+		return t, nil
+	}
+
+	// log.Printf("Setting up JSON writer for %s", t.Name())
+
+	name := w.prefix + w.nameByType[t] + w.suffix + ".json" + w.Compression.Extension()
+	file, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		return nil, err
+	}
+	zw, err := w.Compression.newWriter(file)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	c := &jsonBuilder{
+		filename: name,
+		file:     file,
+		zw:       zw,
+		bw:       bufio.NewWriter(zw),
+		enc:      newJSONEncoder(w.planByType[t]),
+		indent:   w.Indent,
+	}
+
+	// Build the opening and closing of the document,
+	// and the indentation of the records within it.
+	var opening string
+	depth := 1
+	if w.Keyed {
+		key, _ := json.Marshal(w.nameByType[t])
+		opening = "{" + string(key) + ":"
+		c.closing = "}"
+		if w.Indent != "" {
+			opening = "{\n" + w.Indent + string(key) + ": "
+			c.closing = "\n}"
+		}
+		depth = 2
+	}
+	opening += "["
+	c.closing = "]" + c.closing + "\n"
+	if w.Indent != "" {
+		c.newline = "\n" + strings.Repeat(w.Indent, depth)
+	}
+
+	_, err = c.bw.WriteString(opening)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	w.builderByType[t] = c
+	return t, nil
+}
+
+// Write is called to persist records.
+// Each record is written as an individual object
+// in the array of the corresponding output file,
+// according to the type of the given record.
+func (w *JSONWriter) Write(x interface{}) error {
+	if w.closed {
+		return ErrClosedWriter
+	}
+	t, err := w.register(x)
+	if err != nil {
+		return err
+	}
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *JSONWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	var err error
+	// log.Printf("WriteRecord for %s", t.Name())
+	c := w.builderByType[t]
+	c.values, err = p.values(x, c.values[:0])
+	if err != nil {
+		return err
+	}
+	b, err := c.enc.encode(c.values)
+	if err != nil {
+		return err
+	}
+	if c.indent != "" {
+		c.buf.Reset()
+		err = json.Indent(&c.buf, b, c.newline[1:], c.indent)
+		if err != nil {
+			return err
+		}
+		b = c.buf.Bytes()
+	}
+	if c.rows > 0 {
+		c.bw.WriteByte(',')
+	}
+	c.rows++
+	c.bw.WriteString(c.newline)
+	_, err = c.bw.Write(b)
+	return err
+}
+
+// Close flushes all buffers and writers,
+// and closes the output files.
+//
+// Calling Close after a previous call to
+// Cancel is safe, and always results in a no-op.
+func (w *JSONWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, c := range w.builderByType {
+		var cerr error
+		var err error
+
+		// End the document.
+		if c.newline != "" {
+			c.bw.WriteString(c.newline[:len(c.newline)-len(c.indent)])
+		}
+		c.bw.WriteString(c.closing)
+		err = c.bw.Flush()
+		if err != nil {
+			cerr = err
+		}
+
+		err = c.zw.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		// Chmod the file world-readable (ioutil.TempFile creates files with
+		// mode 0600) before renaming.
+		err = c.file.Chmod(0644)
+		if err != nil {
+			cerr = err
+		}
+
+		// fsync(2) after fchmod(2) orders writes as per
+		// https://lwn.net/Articles/270891/.
+		c.file.Sync()
+
+		err = c.file.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		if cerr != nil {
+			rerr = cerr
+			continue
+		}
+
+		err = os.Rename(c.file.Name(), c.filename)
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// Cancel should be called in the event of an error occurring,
+// to properly close and delete any partially written files.
+func (w *JSONWriter) Cancel() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, c := range w.builderByType {
+		var err error
+
+		// Release any resources held by the compressor.
+		c.zw.Close()
+
+		err = c.file.Close()
+		if err != nil {
+			rerr = err
+		}
+
+		err = os.Remove(c.file.Name())
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
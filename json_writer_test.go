@@ -0,0 +1,202 @@
+package peanut_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+var _ = Describe("JSONWriter", func() {
+
+	newFn := func(suffix string) peanut.Writer {
+		w := peanut.NewJSONWriter("./test/output-", suffix)
+		return w
+	}
+
+	expectedOutput1 := `[{"foo_string":"test 1","foo_int":1},` +
+		`{"foo_string":"test 2","foo_int":2},` +
+		`{"foo_string":"test 3","foo_int":3}]` + "\n"
+
+	expectedOutput2 := `[{"bar_int":1,"bar_string":"test 1"},` +
+		`{"bar_int":2,"bar_string":"test 2"},` +
+		`{"bar_int":3,"bar_string":"test 3"}]` + "\n"
+
+	expectedOutput3 := `[{"baz_string":"test 1","baz_bool":true,"baz_float32":1.234,"baz_float64":9.876,"baz_int":-12345,"baz_int8":-8,"baz_int16":-16,"baz_int32":-32,"baz_int64":-64,"baz_uint":12345,"baz_uint8":8,"baz_uint16":16,"baz_uint32":32,"baz_uint64":64}]` + "\n"
+
+	AfterEach(func() {
+		os.Remove("./test/output-Foo-sequential.json")
+		os.Remove("./test/output-Bar-sequential.json")
+		os.Remove("./test/output-Baz-sequential.json")
+		os.Remove("./test/output-Qux-sequential.json")
+		os.Remove("./test/output-Foo-interleave.json")
+		os.Remove("./test/output-Bar-interleave.json")
+		os.Remove("./test/output-Baz-interleave.json")
+		os.Remove("./test/output-Qux-interleave.json")
+		os.Remove("./test/output-Foo-indent.json")
+		os.Remove("./test/output-Foo-keyed.json")
+		os.Remove("./test/output-Foo-keyed-indent.json")
+	})
+
+	It("should write the correct data when sequential structs are written", func() {
+		w := newFn("-sequential")
+
+		testWritesAndCloseSequential(w)
+
+		output1, err := ioutil.ReadFile("./test/output-Foo-sequential.json")
+		Expect(err).To(BeNil())
+		Expect(string(output1)).To(Equal(expectedOutput1))
+
+		output2, err := ioutil.ReadFile("./test/output-Bar-sequential.json")
+		Expect(err).To(BeNil())
+		Expect(string(output2)).To(Equal(expectedOutput2))
+
+		output3, err := ioutil.ReadFile("./test/output-Baz-sequential.json")
+		Expect(err).To(BeNil())
+		Expect(string(output3)).To(Equal(expectedOutput3))
+
+		Expect("./test/output-Qux-sequential.json").ToNot(BeAnExistingFile())
+	})
+
+	It("should write the correct data when interleaved structs are written", func() {
+		w := newFn("-interleave")
+
+		testWritesAndCloseInterleaved(w)
+
+		output1, err := ioutil.ReadFile("./test/output-Foo-interleave.json")
+		Expect(err).To(BeNil())
+		Expect(string(output1)).To(Equal(expectedOutput1))
+
+		output2, err := ioutil.ReadFile("./test/output-Bar-interleave.json")
+		Expect(err).To(BeNil())
+		Expect(string(output2)).To(Equal(expectedOutput2))
+
+		output3, err := ioutil.ReadFile("./test/output-Baz-interleave.json")
+		Expect(err).To(BeNil())
+		Expect(string(output3)).To(Equal(expectedOutput3))
+
+		Expect("./test/output-Qux-interleave.json").ToNot(BeAnExistingFile())
+	})
+
+	It("should write indented output when Indent is set", func() {
+		w := peanut.NewJSONWriter("./test/output-", "-indent")
+		w.Indent = "  "
+		for _, x := range testOutputFoo[:2] {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		Expect(w.Close()).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Foo-indent.json")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal(`[
+  {
+    "foo_string": "test 1",
+    "foo_int": 1
+  },
+  {
+    "foo_string": "test 2",
+    "foo_int": 2
+  }
+]
+`))
+	})
+
+	It("should wrap the array in an object keyed by type name when Keyed is set", func() {
+		w := peanut.NewJSONWriter("./test/output-", "-keyed")
+		w.Keyed = true
+		for _, x := range testOutputFoo {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		Expect(w.Close()).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Foo-keyed.json")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal(`{"Foo":` + expectedOutput1[:len(expectedOutput1)-1] + "}\n"))
+	})
+
+	It("should write well-formed indented output when Keyed and Indent are set", func() {
+		w := peanut.NewJSONWriter("./test/output-", "-keyed-indent")
+		w.Keyed = true
+		w.Indent = "\t"
+		for _, x := range testOutputFoo[:1] {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		Expect(w.Close()).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Foo-keyed-indent.json")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("{\n" +
+			"\t\"Foo\": [\n" +
+			"\t\t{\n" +
+			"\t\t\t\"foo_string\": \"test 1\",\n" +
+			"\t\t\t\"foo_int\": 1\n" +
+			"\t\t}\n" +
+			"\t]\n" +
+			"}\n"))
+		var doc map[string][]map[string]interface{}
+		Expect(json.Unmarshal(output, &doc)).To(BeNil())
+		Expect(doc["Foo"]).To(HaveLen(1))
+	})
+
+	It("should not write anything when structs are written and cancel is called", func() {
+		w := newFn("-cancel")
+
+		testWritesAndCancel(w)
+
+		Expect("./test/output-Foo-cancel.json").ToNot(BeAnExistingFile())
+		Expect("./test/output-Bar-cancel.json").ToNot(BeAnExistingFile())
+	})
+
+	It("should return an error when Write is called after Close", func() {
+		w := newFn("-close-write")
+
+		testWriteAfterClose(w)
+
+		Expect("./test/output-Foo-close-write.json").ToNot(BeAnExistingFile())
+	})
+
+	It("should write times, durations and nulls as JSONLWriter does", func() {
+		w := newFn("-times")
+
+		testWritesTimesAndClose(w)
+		defer os.Remove("./test/output-Times-times.json")
+
+		output, err := ioutil.ReadFile("./test/output-Times-times.json")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal(`[{"id":"t1","time":"2021-04-19T13:45:30Z","date":"2021-04-19","duration":"1h30m0s"}]` + "\n"))
+
+		w = newFn("-nullable")
+
+		testWritesNullableAndClose(w)
+		defer os.Remove("./test/output-Nullable-nullable.json")
+
+		output, err = ioutil.ReadFile("./test/output-Nullable-nullable.json")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal(`[{"id":"n1","int_ptr":1,"string_ptr":"test 1","null_string":"test 1","null_int64":1,"null_time":"2021-04-19T13:45:30Z","null_float":1.5},` +
+			`{"id":"n2","int_ptr":null,"string_ptr":null,"null_string":null,"null_int64":null,"null_time":null,"null_float":null}]` + "\n"))
+	})
+
+	It("should write custom types using the interfaces they implement", func() {
+		w := newFn("-custom")
+
+		testWritesCustomAndClose(w)
+		defer os.Remove("./test/output-Custom-custom.json")
+
+		output, err := ioutil.ReadFile("./test/output-Custom-custom.json")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal(`[{"id":"c1","amount":12.34,"level":"warning","ip":"192.168.0.1","point":{"x":1,"y":2},"code":"CODE-a"}]` + "\n"))
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			w := peanut.NewJSONWriter("./no-such-location/output-bogus-", "")
+
+			testWriteBadType(w)
+		})
+	})
+})
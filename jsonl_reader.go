@@ -0,0 +1,206 @@
+package peanut
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+)
+
+var _ Reader = &JSONLReader{}
+
+// JSONLReader reads records from JSON Lines files, such as
+// those written by JSONLWriter, reading each record type
+// from an individual JSON Lines file.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".jsonl"
+//
+// Each line must hold a JSON object, whose keys are matched
+// to the names in the struct's field tags. Blank lines are
+// skipped. Unless Lenient is set, missing and unexpected keys
+// result in an error.
+//
+// Nullable fields (pointers and sql.Null* types) are
+// read as null when their value is JSON null. A JSON null
+// for any other field results in an error, wrapping
+// ErrNullValue.
+//
+// The caller must call Close when finished reading.
+type JSONLReader struct {
+	*base
+	NameFunc     NameFunc // NameFunc names the input files, TypeName is used if nil.
+	Lenient      bool     // Lenient permits missing and unexpected keys.
+	prefix       string
+	suffix       string
+	sourceByType map[reflect.Type]*jsonlSource
+}
+
+// NewJSONLReader returns a new JSONLReader, using prefix
+// and suffix when building its input filenames.
+//
+// See JSONLReader (above) for input filename details.
+func NewJSONLReader(prefix, suffix string) *JSONLReader {
+	r := JSONLReader{
+		base:         &base{format: formatJSONL},
+		prefix:       prefix,
+		suffix:       suffix,
+		sourceByType: make(map[reflect.Type]*jsonlSource),
+	}
+	return &r
+}
+
+type jsonlSource struct {
+	filename string
+	file     *os.File
+	bufr     *bufio.Reader
+	m        map[string]json.RawMessage // m is reused for each record read.
+	row      int                        // row is the number of the last line read.
+}
+
+func (r *JSONLReader) source(x interface{}) (*jsonlSource, error) {
+	// Register with base.
+	t, _, err := r.base.registerRead(x, r.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := r.sourceByType[t]; ok {
+		return s, nil
+	}
+
+	name := r.prefix + r.nameByType[t] + r.suffix + ".jsonl"
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	s := &jsonlSource{
+		filename: name,
+		file:     file,
+		bufr:     bufio.NewReader(file),
+		m:        make(map[string]json.RawMessage),
+	}
+	r.sourceByType[t] = s
+	return s, nil
+}
+
+// nextLine returns the next non-blank line, or io.EOF.
+func (s *jsonlSource) nextLine() ([]byte, error) {
+	for {
+		line, err := s.bufr.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return nil, err
+		}
+		s.row++
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Read reads the next record from the input file
+// corresponding to the type of the given record,
+// which must be a pointer to a struct, returning
+// io.EOF when no more records remain.
+func (r *JSONLReader) Read(x interface{}) error {
+	if r.closed {
+		return ErrClosedReader
+	}
+	v, err := readTarget(x)
+	if err != nil {
+		return err
+	}
+	s, err := r.source(x)
+	if err != nil {
+		return err
+	}
+	line, err := s.nextLine()
+	if err == io.EOF {
+		return err
+	}
+	if err != nil {
+		return &ReadError{File: s.filename, Row: s.row, Err: err}
+	}
+	for k := range s.m {
+		delete(s.m, k)
+	}
+	err = json.Unmarshal(line, &s.m)
+	if err != nil {
+		return &ReadError{File: s.filename, Row: s.row, Err: err}
+	}
+
+	p := r.planByType[v.Type()]
+	v.Set(reflect.Zero(v.Type()))
+	found := 0
+	for _, f := range p.fields {
+		raw, ok := s.m[f.header]
+		if !ok {
+			if !r.Lenient {
+				return &ReadError{File: s.filename, Row: s.row, Column: f.header, Err: ErrMissingColumn}
+			}
+			continue
+		}
+		found++
+		null := string(raw) == "null"
+		if null && !f.nullable {
+			return &ReadError{File: s.filename, Row: s.row, Column: f.header, Err: ErrNullValue}
+		}
+		err := f.set(v, raw, null)
+		if err != nil {
+			return &ReadError{File: s.filename, Row: s.row, Column: f.header, Err: err}
+		}
+	}
+	if !r.Lenient && found < len(s.m) {
+		return &ReadError{File: s.filename, Row: s.row, Column: unexpectedKey(p, s.m), Err: ErrUnexpectedColumn}
+	}
+	return nil
+}
+
+// unexpectedKey returns the first key, in sorted order,
+// of m that does not correspond to a field of plan p.
+func unexpectedKey(p *typePlan, m map[string]json.RawMessage) string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		known := false
+		for _, f := range p.fields {
+			if f.header == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return k
+		}
+	}
+	return ""
+}
+
+// Close closes all input files.
+//
+// Calling Close more than once is safe,
+// and subsequent calls are a no-op.
+func (r *JSONLReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	var rerr error
+	for _, s := range r.sourceByType {
+		err := s.file.Close()
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
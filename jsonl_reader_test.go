@@ -0,0 +1,93 @@
+package peanut_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+var _ = Describe("JSONLReader", func() {
+
+	AfterEach(func() {
+		os.Remove("./test/output-Foo-read.jsonl")
+		os.Remove("./test/output-Bar-read.jsonl")
+		os.Remove("./test/output-Baz-read.jsonl")
+		os.Remove("./test/output-Qux-read.jsonl")
+		os.Remove("./test/output-Times-read.jsonl")
+		os.Remove("./test/output-Nullable-read.jsonl")
+		os.Remove("./test/output-Nested-read.jsonl")
+		os.Remove("./test/input-Foo.jsonl")
+	})
+
+	It("should read back the records written by JSONLWriter", func() {
+		testWritesAndCloseSequential(peanut.NewJSONLWriter("./test/output-", "-read"))
+
+		testReadsAndClose(peanut.NewJSONLReader("./test/output-", "-read"))
+	})
+
+	It("should read back times, nulls and nested structs", func() {
+		testWritesTimesAndClose(peanut.NewJSONLWriter("./test/output-", "-read"))
+		testWritesNullableAndClose(peanut.NewJSONLWriter("./test/output-", "-read"))
+		testWritesNestedAndClose(peanut.NewJSONLWriter("./test/output-", "-read"))
+
+		testReadsTimesNullableAndNested(peanut.NewJSONLReader("./test/output-", "-read"))
+	})
+
+	It("should return an error for missing and unexpected keys, unless lenient", func() {
+		err := ioutil.WriteFile("./test/input-Foo.jsonl", []byte(`{"foo_string":"test 1","extra":true}`+"\n\n"+`{"foo_string":"test 2","foo_int":2}`+"\n"), 0644)
+		Expect(err).To(BeNil())
+
+		r := peanut.NewJSONLReader("./test/input-", "")
+		err = r.Read(&Foo{})
+		Expect(errors.Is(err, peanut.ErrMissingColumn)).To(BeTrue())
+		Expect(err.Error()).To(MatchRegexp("row 1, column foo_int"))
+		Expect(r.Close()).To(BeNil())
+
+		r = peanut.NewJSONLReader("./test/input-", "")
+		r.Lenient = true
+		defer r.Close()
+		foo, err := readAll[Foo](r)
+		Expect(err).To(BeNil())
+		Expect(foo).To(Equal([]*Foo{{StringField: "test 1"}, {StringField: "test 2", IntField: 2}}))
+	})
+
+	It("should report the file, row and column of conversion errors", func() {
+		err := ioutil.WriteFile("./test/input-Foo.jsonl", []byte(`{"foo_string":"test 1","foo_int":1}`+"\n"+`{"foo_string":"test 2","foo_int":"two"}`+"\n"), 0644)
+		Expect(err).To(BeNil())
+
+		r := peanut.NewJSONLReader("./test/input-", "")
+		defer r.Close()
+		_, err = readAll[Foo](r)
+		var rerr *peanut.ReadError
+		Expect(errors.As(err, &rerr)).To(BeTrue())
+		Expect(rerr.File).To(Equal("./test/input-Foo.jsonl"))
+		Expect(rerr.Row).To(Equal(2))
+		Expect(rerr.Column).To(Equal("foo_int"))
+	})
+
+	It("should return an error for null values of non-nullable fields", func() {
+		err := ioutil.WriteFile("./test/input-Foo.jsonl", []byte(`{"foo_string":"test 1","foo_int":1}`+"\n"+`{"foo_string":"test 2","foo_int":null}`+"\n"), 0644)
+		Expect(err).To(BeNil())
+
+		r := peanut.NewJSONLReader("./test/input-", "")
+		defer r.Close()
+		_, err = readAll[Foo](r)
+		Expect(errors.Is(err, peanut.ErrNullValue)).To(BeTrue())
+		var rerr *peanut.ReadError
+		Expect(errors.As(err, &rerr)).To(BeTrue())
+		Expect(rerr.Row).To(Equal(2))
+		Expect(rerr.Column).To(Equal("foo_int"))
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			testReadBadType(peanut.NewJSONLReader("./test/output-", "-read"))
+		})
+	})
+})
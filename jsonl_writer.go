@@ -7,7 +7,7 @@ package peanut
 
 import (
 	"bufio"
-	"encoding/json"
+	"io"
 	"io/ioutil"
 	"os"
 	"reflect"
@@ -20,7 +20,17 @@ var _ Writer = &JSONLWriter{}
 //
 // Filenames for each corresponding record type are derived
 // accordingly:
-//  prefix + type.Name() + suffix + ".jsonl"
+//  prefix + NameFunc(type) + suffix + ".jsonl"
+//
+// If Compression is set, files are compressed accordingly,
+// and named with its Extension, as in ".jsonl.gz".
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Each record is written as a JSON object on its own line,
+// with keys named using the names extracted from the struct's
+// field tags, in the order that they appear within the struct.
 //
 // The caller must call Close on successful completion
 // of all writing, to ensure buffers are flushed and
@@ -31,6 +41,8 @@ var _ Writer = &JSONLWriter{}
 // closure and cleanup of any partially written files.
 type JSONLWriter struct {
 	*base
+	NameFunc      NameFunc    // NameFunc names the output files, TypeName is used if nil.
+	Compression   Compression // Compression compresses the output files, which are uncompressed by default.
 	prefix        string
 	suffix        string
 	builderByType map[reflect.Type]*jsonlBuilder
@@ -42,7 +54,7 @@ type JSONLWriter struct {
 // See JSONLWriter (above) for output filename details.
 func NewJSONLWriter(prefix, suffix string) *JSONLWriter {
 	w := JSONLWriter{
-		base:          &base{},
+		base:          &base{format: formatJSONL},
 		prefix:        prefix,
 		suffix:        suffix,
 		builderByType: make(map[reflect.Type]*jsonlBuilder),
@@ -53,13 +65,18 @@ func NewJSONLWriter(prefix, suffix string) *JSONLWriter {
 type jsonlBuilder struct {
 	filename string
 	file     *os.File
+	zw       io.WriteCloser // zw compresses output written to file.
 	bw       *bufio.Writer
-	enc      *json.Encoder
+	enc      *jsonEncoder
+	values   []interface{} // values is reused for each record written.
 }
 
 func (w *JSONLWriter) register(x interface{}) (reflect.Type, error) {
 	// Register with base writer.
-	t, ok := w.base.register(x)
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
 		return t, nil
 	}
@@ -72,16 +89,21 @@ func (w *JSONLWriter) register(x interface{}) (reflect.Type, error) {
 
 	// log.Printf("Setting up jsonl.Writer for %s", t.Name())
 
-	name := w.prefix + t.Name() + w.suffix + ".jsonl"
+	name := w.prefix + w.nameByType[t] + w.suffix + ".jsonl" + w.Compression.Extension()
 	// file, err := os.Create(name)
 	file, err := ioutil.TempFile("", "atomic-")
 	if err != nil {
 		return nil, err
 	}
-	bw := bufio.NewWriter(file)
-	enc := json.NewEncoder(bw)
-	enc.SetEscapeHTML(false)
-	w.builderByType[t] = &jsonlBuilder{filename: name, file: file, bw: bw, enc: enc}
+	zw, err := w.Compression.newWriter(file)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	bw := bufio.NewWriter(zw)
+	enc := newJSONEncoder(w.planByType[t])
+	w.builderByType[t] = &jsonlBuilder{filename: name, file: file, zw: zw, bw: bw, enc: enc}
 	return t, nil
 }
 
@@ -97,12 +119,28 @@ func (w *JSONLWriter) Write(x interface{}) error {
 	if err != nil {
 		return err
 	}
-	if len(w.base.tagsByType[t]) == 0 {
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *JSONLWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
 		return nil
 	}
+	var err error
 	// log.Printf("WriteRecord for %s", t.Name())
-	enc := w.builderByType[t].enc
-	return enc.Encode(mapValues(x))
+	c := w.builderByType[t]
+	c.values, err = p.values(x, c.values[:0])
+	if err != nil {
+		return err
+	}
+	b, err := c.enc.encode(c.values)
+	if err != nil {
+		return err
+	}
+	c.bw.Write(b)
+	return c.bw.WriteByte('\n')
 }
 
 // Close flushes all buffers and writers,
@@ -125,6 +163,11 @@ func (w *JSONLWriter) Close() error {
 			cerr = err
 		}
 
+		err = c.zw.Close()
+		if err != nil {
+			cerr = err
+		}
+
 		// Chmod the file world-readable (ioutil.TempFile creates files with
 		// mode 0600) before renaming.
 		err = c.file.Chmod(0644)
@@ -175,6 +218,9 @@ func (w *JSONLWriter) Cancel() error {
 	for _, c := range w.builderByType {
 		var err error
 
+		// Release any resources held by the compressor.
+		c.zw.Close()
+
 		err = c.file.Close()
 		if err != nil {
 			rerr = err
@@ -3,6 +3,8 @@ package peanut_test
 import (
 	"io/ioutil"
 	"os"
+	"reflect"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -17,15 +19,15 @@ var _ = Describe("JSONLWriter", func() {
 		return w
 	}
 
-	expectedOutput1 := `{"foo_int":1,"foo_string":"test 1"}` + "\n" +
-		`{"foo_int":2,"foo_string":"test 2"}` + "\n" +
-		`{"foo_int":3,"foo_string":"test 3"}` + "\n"
+	expectedOutput1 := `{"foo_string":"test 1","foo_int":1}` + "\n" +
+		`{"foo_string":"test 2","foo_int":2}` + "\n" +
+		`{"foo_string":"test 3","foo_int":3}` + "\n"
 
 	expectedOutput2 := `{"bar_int":1,"bar_string":"test 1"}` + "\n" +
 		`{"bar_int":2,"bar_string":"test 2"}` + "\n" +
 		`{"bar_int":3,"bar_string":"test 3"}` + "\n"
 
-	expectedOutput3 := `{"baz_bool":true,"baz_float32":1.234,"baz_float64":9.876,"baz_int":-12345,"baz_int16":-16,"baz_int32":-32,"baz_int64":-64,"baz_int8":-8,"baz_string":"test 1","baz_uint":12345,"baz_uint16":16,"baz_uint32":32,"baz_uint64":64,"baz_uint8":8}` + "\n"
+	expectedOutput3 := `{"baz_string":"test 1","baz_bool":true,"baz_float32":1.234,"baz_float64":9.876,"baz_int":-12345,"baz_int8":-8,"baz_int16":-16,"baz_int32":-32,"baz_int64":-64,"baz_uint":12345,"baz_uint8":8,"baz_uint16":16,"baz_uint32":32,"baz_uint64":64}` + "\n"
 
 	AfterEach(func() {
 		os.Remove("./test/output-Foo-sequential.jsonl")
@@ -89,6 +91,21 @@ var _ = Describe("JSONLWriter", func() {
 		Expect("./test/output-Bar-cancel.jsonl").ToNot(BeAnExistingFile())
 	})
 
+	It("should compress the output files when configured to", func() {
+		w := peanut.NewJSONLWriter("./test/output-", "-compressed")
+		w.Compression = peanut.Gzip
+
+		testWritesAndCloseSequential(w)
+
+		defer os.Remove("./test/output-Foo-compressed.jsonl.gz")
+		defer os.Remove("./test/output-Bar-compressed.jsonl.gz")
+		defer os.Remove("./test/output-Baz-compressed.jsonl.gz")
+
+		Expect(readCompressedFile("./test/output-Foo-compressed.jsonl.gz", peanut.Gzip)).To(Equal(expectedOutput1))
+		Expect(readCompressedFile("./test/output-Bar-compressed.jsonl.gz", peanut.Gzip)).To(Equal(expectedOutput2))
+		Expect("./test/output-Qux-compressed.jsonl.gz").ToNot(BeAnExistingFile())
+	})
+
 	It("should return an error when Write is called after Close", func() {
 		w := newFn("-close-write")
 
@@ -107,6 +124,81 @@ var _ = Describe("JSONLWriter", func() {
 		Expect(err).ToNot(BeNil())
 	})
 
+	It("should write time fields using RFC 3339 or the tagged format", func() {
+		w := newFn("-times")
+
+		testWritesTimesAndClose(w)
+		defer os.Remove("./test/output-Times-times.jsonl")
+
+		output, err := ioutil.ReadFile("./test/output-Times-times.jsonl")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal(`{"id":"t1","time":"2021-04-19T13:45:30Z","date":"2021-04-19","duration":"1h30m0s"}` + "\n"))
+	})
+
+	It("should write null values as JSON null", func() {
+		w := newFn("-nullable")
+
+		testWritesNullableAndClose(w)
+		defer os.Remove("./test/output-Nullable-nullable.jsonl")
+
+		output, err := ioutil.ReadFile("./test/output-Nullable-nullable.jsonl")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal(`{"id":"n1","int_ptr":1,"string_ptr":"test 1","null_string":"test 1","null_int64":1,"null_time":"2021-04-19T13:45:30Z","null_float":1.5}` + "\n" +
+			`{"id":"n2","int_ptr":null,"string_ptr":null,"null_string":null,"null_int64":null,"null_time":null,"null_float":null}` + "\n"))
+	})
+
+	It("should write custom types using the interfaces they implement", func() {
+		w := newFn("-custom")
+
+		testWritesCustomAndClose(w)
+		defer os.Remove("./test/output-Custom-custom.jsonl")
+
+		output, err := ioutil.ReadFile("./test/output-Custom-custom.jsonl")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal(`{"id":"c1","amount":12.34,"level":"warning","ip":"192.168.0.1","point":{"x":1,"y":2},"code":"CODE-a"}` + "\n"))
+	})
+
+	It("should use the name given by a type implementing Namer", func() {
+		w := newFn("-namer")
+
+		err := w.Write(SelfNamed{ID: "s1"})
+		Expect(err).To(BeNil())
+		err = w.Close()
+		Expect(err).To(BeNil())
+		defer os.Remove("./test/output-self_named_v2-namer.jsonl")
+
+		output, err := ioutil.ReadFile("./test/output-self_named_v2-namer.jsonl")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal(`{"id":"s1"}` + "\n"))
+	})
+
+	It("should return an error when a type implementing Namer gives an empty name", func() {
+		w := newFn("-namer")
+
+		err := w.Write(Unnamed{ID: "u1"})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("empty name"))
+		err = w.Close()
+		Expect(err).To(BeNil())
+
+		Expect("./test/output--namer.jsonl").ToNot(BeAnExistingFile())
+	})
+
+	It("should use the name given by a custom NameFunc", func() {
+		w := peanut.NewJSONLWriter("./test/output-", "-namefunc")
+		w.NameFunc = func(t reflect.Type) string {
+			return strings.ToLower(peanut.TypeName(t)) + "s"
+		}
+
+		err := w.Write(testOutputFoo[0])
+		Expect(err).To(BeNil())
+		err = w.Close()
+		Expect(err).To(BeNil())
+		defer os.Remove("./test/output-foos-namefunc.jsonl")
+
+		Expect("./test/output-foos-namefunc.jsonl").To(BeAnExistingFile())
+	})
+
 	Context("when given a struct with an unsupported field type", func() {
 
 		It("should return an error with an informative message", func() {
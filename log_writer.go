@@ -15,20 +15,27 @@ var _ Writer = &LogWriter{}
 //
 // If Logger is nil at runtime, a new log.Logger
 // will be created when needed, writing to os.Stderr.
+//
+// Records are logged with the names given by NameFunc,
+// which defaults to TypeName.
 type LogWriter struct {
 	*base
-	Logger  *log.Logger
-	Verbose bool
+	Logger   *log.Logger
+	Verbose  bool
+	NameFunc NameFunc
 }
 
 func (w *LogWriter) register(x interface{}) (reflect.Type, error) {
 	if w.base == nil {
-		w.base = &base{}
+		w.base = &base{format: formatLog}
 		if w.Logger == nil {
 			w.Logger = log.New(os.Stderr, "", log.Ldate|log.Ltime)
 		}
 	}
-	t, ok := w.base.register(x)
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
 		return t, nil
 	}
@@ -45,11 +52,14 @@ func (w *LogWriter) Write(x interface{}) error {
 		return err
 	}
 	// Build log message.
-	n := t.Name()
+	n := w.nameByType[t]
 	m := fmt.Sprintf("<%s>", n)
 	// Concatenate field names and values.
 	h := w.headersByType[t]
-	v := stringValues(x)
+	v, err := w.planByType[t].strings(x, "", nil)
+	if err != nil {
+		return err
+	}
 	for i := range v {
 		m += fmt.Sprintf(" %s:", h[i])
 		if len(v[i]) > 0 {
@@ -0,0 +1,263 @@
+package peanut
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+)
+
+var _ Writer = &MarkdownWriter{}
+
+// MarkdownWriter writes records to Markdown files, as GitHub
+// Flavored Markdown tables, writing each record type to an
+// individual Markdown file automatically.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".md"
+//
+// If Compression is set, files are compressed accordingly,
+// and named with its Extension, as in ".md.gz".
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Each file holds a single table, optionally preceded by a
+// heading containing the file's name, if Heading is set.
+// The header row of the table contains the names extracted
+// from the struct's field tags, and records' fields are
+// written in the order that they appear within the struct.
+//
+// Values are written as text, as by CSVWriter. Pipes
+// and backslashes are escaped, and line breaks are
+// written as <br> tags.
+//
+// Null values, from nil pointers and invalid sql.Null* values,
+// are written using NullValue, which is empty by default.
+//
+// The caller must call Close on successful completion
+// of all writing, to ensure buffers are flushed and
+// files are properly written to disk.
+//
+// In the event of an error or cancellation, the
+// caller must call Cancel before quiting, to ensure
+// closure and cleanup of any partially written files.
+type MarkdownWriter struct {
+	*base
+	NullValue     string      // NullValue is the text written for null values.
+	NameFunc      NameFunc    // NameFunc names the output files, TypeName is used if nil.
+	Compression   Compression // Compression compresses the output files, which are uncompressed by default.
+	Heading       bool        // Heading precedes each table with a heading.
+	prefix        string
+	suffix        string
+	builderByType map[reflect.Type]*markdownBuilder
+}
+
+// NewMarkdownWriter returns a new MarkdownWriter, using prefix
+// and suffix when building its output filenames.
+//
+// See MarkdownWriter (above) for output filename details.
+func NewMarkdownWriter(prefix, suffix string) *MarkdownWriter {
+	w := MarkdownWriter{
+		base:          &base{format: formatMarkdown},
+		prefix:        prefix,
+		suffix:        suffix,
+		builderByType: make(map[reflect.Type]*markdownBuilder),
+	}
+	return &w
+}
+
+type markdownBuilder struct {
+	filename string
+	file     *os.File
+	zw       io.WriteCloser // zw compresses output written to file.
+	bw       *bufio.Writer
+	row      []string // row is reused for each record written.
+}
+
+// markdownEscaper escapes text for use within table cells.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`|`, `\|`,
+	"\r\n", "<br>",
+	"\n", "<br>",
+	"\r", "<br>",
+)
+
+// writeRow writes cells as a row of a table.
+func (b *markdownBuilder) writeRow(cells []string) error {
+	b.bw.WriteByte('|')
+	for _, s := range cells {
+		b.bw.WriteByte(' ')
+		markdownEscaper.WriteString(b.bw, s)
+		b.bw.WriteString(" |")
+	}
+	return b.bw.WriteByte('\n')
+}
+
+func (w *MarkdownWriter) register(x interface{}) (reflect.Type, error) {
+	// Register with base writer.
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t, nil
+	}
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, err
+	}
+	if len(w.base.tagsByType[t]) == 0 {
+		return t, nil
+	}
+
+	// log.Printf("Setting up Markdown writer for %s", t.Name())
+
+	name := w.prefix + w.nameByType[t] + w.suffix + ".md" + w.Compression.Extension()
+	file, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		return nil, err
+	}
+	zw, err := w.Compression.newWriter(file)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	b := &markdownBuilder{filename: name, file: file, zw: zw, bw: bufio.NewWriter(zw)}
+	w.builderByType[t] = b
+
+	if w.Heading {
+		b.bw.WriteString("## ")
+		markdownEscaper.WriteString(b.bw, w.nameByType[t])
+		b.bw.WriteString("\n\n")
+	}
+	headers := w.headersByType[t]
+	b.writeRow(headers)
+	b.bw.WriteByte('|')
+	for range headers {
+		b.bw.WriteString(" --- |")
+	}
+	err = b.bw.WriteByte('\n')
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Write is called to persist records.
+// Each record is written to an individual row
+// in the corresponding output file, according to the
+// type of the given record.
+func (w *MarkdownWriter) Write(x interface{}) error {
+	if w.closed {
+		return ErrClosedWriter
+	}
+	t, err := w.register(x)
+	if err != nil {
+		return err
+	}
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *MarkdownWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	var err error
+	// log.Printf("WriteRecord for %s", t.Name())
+	b := w.builderByType[t]
+	b.row, err = p.strings(x, w.NullValue, b.row[:0])
+	if err != nil {
+		return err
+	}
+	return b.writeRow(b.row)
+}
+
+// Close flushes all buffers and writers,
+// and closes the output files.
+//
+// Calling Close after a previous call to
+// Cancel is safe, and always results in a no-op.
+func (w *MarkdownWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, b := range w.builderByType {
+		var cerr error
+		var err error
+
+		err = b.bw.Flush()
+		if err != nil {
+			cerr = err
+		}
+
+		err = b.zw.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		// Chmod the file world-readable (ioutil.TempFile creates files with
+		// mode 0600) before renaming.
+		err = b.file.Chmod(0644)
+		if err != nil {
+			cerr = err
+		}
+
+		// fsync(2) after fchmod(2) orders writes as per
+		// https://lwn.net/Articles/270891/.
+		b.file.Sync()
+
+		err = b.file.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		if cerr != nil {
+			rerr = cerr
+			continue
+		}
+
+		err = os.Rename(b.file.Name(), b.filename)
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// Cancel should be called in the event of an error occurring,
+// to properly close and delete any partially written files.
+func (w *MarkdownWriter) Cancel() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, b := range w.builderByType {
+		var err error
+
+		// Release any resources held by the compressor.
+		b.zw.Close()
+
+		err = b.file.Close()
+		if err != nil {
+			rerr = err
+		}
+
+		err = os.Remove(b.file.Name())
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
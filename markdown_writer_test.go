@@ -0,0 +1,158 @@
+package peanut_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+var _ = Describe("MarkdownWriter", func() {
+
+	newFn := func(suffix string) peanut.Writer {
+		w := peanut.NewMarkdownWriter("./test/output-", suffix)
+		return w
+	}
+
+	expectedOutput1 := "| foo_string | foo_int |\n" +
+		"| --- | --- |\n" +
+		"| test 1 | 1 |\n" +
+		"| test 2 | 2 |\n" +
+		"| test 3 | 3 |\n"
+
+	expectedOutput2 := "| bar_int | bar_string |\n" +
+		"| --- | --- |\n" +
+		"| 1 | test 1 |\n" +
+		"| 2 | test 2 |\n" +
+		"| 3 | test 3 |\n"
+
+	expectedOutput3 := "| baz_string | baz_bool | baz_float32 | baz_float64 | baz_int | baz_int8 | baz_int16 | baz_int32 | baz_int64 | baz_uint | baz_uint8 | baz_uint16 | baz_uint32 | baz_uint64 |\n" +
+		"| --- | --- | --- | --- | --- | --- | --- | --- | --- | --- | --- | --- | --- | --- |\n" +
+		"| test 1 | true | 1.234 | 9.876 | -12345 | -8 | -16 | -32 | -64 | 12345 | 8 | 16 | 32 | 64 |\n"
+
+	AfterEach(func() {
+		os.Remove("./test/output-Foo-sequential.md")
+		os.Remove("./test/output-Bar-sequential.md")
+		os.Remove("./test/output-Baz-sequential.md")
+		os.Remove("./test/output-Qux-sequential.md")
+		os.Remove("./test/output-Foo-interleave.md")
+		os.Remove("./test/output-Bar-interleave.md")
+		os.Remove("./test/output-Baz-interleave.md")
+		os.Remove("./test/output-Qux-interleave.md")
+		os.Remove("./test/output-Foo-escape.md")
+		os.Remove("./test/output-Foo-heading.md")
+		os.Remove("./test/output-Nullable-nullable.md")
+	})
+
+	It("should write the correct data when sequential structs are written", func() {
+		w := newFn("-sequential")
+
+		testWritesAndCloseSequential(w)
+
+		output1, err := ioutil.ReadFile("./test/output-Foo-sequential.md")
+		Expect(err).To(BeNil())
+		Expect(string(output1)).To(Equal(expectedOutput1))
+
+		output2, err := ioutil.ReadFile("./test/output-Bar-sequential.md")
+		Expect(err).To(BeNil())
+		Expect(string(output2)).To(Equal(expectedOutput2))
+
+		output3, err := ioutil.ReadFile("./test/output-Baz-sequential.md")
+		Expect(err).To(BeNil())
+		Expect(string(output3)).To(Equal(expectedOutput3))
+
+		Expect("./test/output-Qux-sequential.md").ToNot(BeAnExistingFile())
+	})
+
+	It("should write the correct data when interleaved structs are written", func() {
+		w := newFn("-interleave")
+
+		testWritesAndCloseInterleaved(w)
+
+		output1, err := ioutil.ReadFile("./test/output-Foo-interleave.md")
+		Expect(err).To(BeNil())
+		Expect(string(output1)).To(Equal(expectedOutput1))
+
+		output2, err := ioutil.ReadFile("./test/output-Bar-interleave.md")
+		Expect(err).To(BeNil())
+		Expect(string(output2)).To(Equal(expectedOutput2))
+
+		output3, err := ioutil.ReadFile("./test/output-Baz-interleave.md")
+		Expect(err).To(BeNil())
+		Expect(string(output3)).To(Equal(expectedOutput3))
+
+		Expect("./test/output-Qux-interleave.md").ToNot(BeAnExistingFile())
+	})
+
+	It("should escape pipes and backslashes, and write line breaks as tags", func() {
+		w := newFn("-escape")
+
+		err := w.Write(&Foo{StringField: "a|b \\ c\nd", IntField: 1})
+		Expect(err).To(BeNil())
+		err = w.Close()
+		Expect(err).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Foo-escape.md")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("| foo_string | foo_int |\n" +
+			"| --- | --- |\n" +
+			"| a\\|b \\\\ c<br>d | 1 |\n"))
+	})
+
+	It("should write a heading when Heading is set", func() {
+		w := peanut.NewMarkdownWriter("./test/output-", "-heading")
+		w.Heading = true
+
+		err := w.Write(testOutputFoo[0])
+		Expect(err).To(BeNil())
+		err = w.Close()
+		Expect(err).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Foo-heading.md")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("## Foo\n\n" +
+			"| foo_string | foo_int |\n" +
+			"| --- | --- |\n" +
+			"| test 1 | 1 |\n"))
+	})
+
+	It("should write null values using NullValue", func() {
+		w := peanut.NewMarkdownWriter("./test/output-", "-nullable")
+		w.NullValue = "-"
+
+		testWritesNullableAndClose(w)
+
+		output, err := ioutil.ReadFile("./test/output-Nullable-nullable.md")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(HaveSuffix("| n2 | - | - | - | - | - | - |\n"))
+	})
+
+	It("should not write anything when structs are written and cancel is called", func() {
+		w := newFn("-cancel")
+
+		testWritesAndCancel(w)
+
+		Expect("./test/output-Foo-cancel.md").ToNot(BeAnExistingFile())
+		Expect("./test/output-Bar-cancel.md").ToNot(BeAnExistingFile())
+	})
+
+	It("should return an error when Write is called after Close", func() {
+		w := newFn("-close-write")
+
+		testWriteAfterClose(w)
+
+		Expect("./test/output-Foo-close-write.md").ToNot(BeAnExistingFile())
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			w := peanut.NewMarkdownWriter("./no-such-location/output-bogus-", "")
+
+			testWriteBadType(w)
+		})
+	})
+})
@@ -0,0 +1,230 @@
+package peanut
+
+import (
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Marshaler is the interface implemented by types that can marshal
+// themselves into values that peanut writers are able to write.
+//
+// PeanutType returns the type of the values returned by MarshalPeanut,
+// which must be one of the supported datatypes (see Limitations, in
+// the package documentation). It is used to determine column types,
+// and is called on the zero value of the implementing type.
+//
+// MarshalPeanut returns the value to be written by a writer of the
+// given format, which is one of "csv", "tsv", "json", "jsonl", "excel",
+// "sqlite", "parquet", "avro", "arrow", "xml", "markdown", "html",
+// "fixedwidth", "sql", "pgcopy", "msgpack", "cbor", "protobuf", "yaml",
+// "toml", "log" or "mock". The returned value must be either nil, or of the type
+// returned by PeanutType.
+type Marshaler interface {
+	PeanutType() reflect.Type
+	MarshalPeanut(format string) (interface{}, error)
+}
+
+// Names of formats, as passed to Marshaler.MarshalPeanut.
+const (
+	formatCSV        = "csv"
+	formatTSV        = "tsv"
+	formatJSONL      = "jsonl"
+	formatExcel      = "excel"
+	formatSQLite     = "sqlite"
+	formatLog        = "log"
+	formatMock       = "mock"
+	formatParquet    = "parquet"
+	formatAvro       = "avro"
+	formatArrow      = "arrow"
+	formatJSON       = "json"
+	formatXML        = "xml"
+	formatMarkdown   = "markdown"
+	formatHTML       = "html"
+	formatFixedWidth = "fixedwidth"
+	formatSQLDump    = "sql"
+	formatPGCopy     = "pgcopy"
+	formatMsgpack    = "msgpack"
+	formatCBOR       = "cbor"
+	formatProtobuf   = "protobuf"
+	formatYAML       = "yaml"
+	formatTOML       = "toml"
+)
+
+var (
+	marshalerType     = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	valuerType        = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	stringType        = reflect.TypeOf("")
+	int64Type         = reflect.TypeOf(int64(0))
+	float64Type       = reflect.TypeOf(float64(0))
+	boolType          = reflect.TypeOf(false)
+)
+
+// Interfaces honoured when marshaling values, in order of preference.
+// Marshaler is always preferred, and writers for formats not listed
+// here use defaultMarshalers.
+var (
+	defaultMarshalers = []reflect.Type{textMarshalerType, stringerType, valuerType, jsonMarshalerType}
+	formatMarshalers  = map[string][]reflect.Type{
+		formatJSON:    {jsonMarshalerType, textMarshalerType, stringerType, valuerType},
+		formatJSONL:   {jsonMarshalerType, textMarshalerType, stringerType, valuerType},
+		formatSQLite:  {valuerType, textMarshalerType, stringerType, jsonMarshalerType},
+		formatSQLDump: {valuerType, textMarshalerType, stringerType, jsonMarshalerType},
+		formatPGCopy:  {valuerType, textMarshalerType, stringerType, jsonMarshalerType},
+	}
+)
+
+// marshalerFor returns the interface that will be used to marshal values
+// of type t for the given format, or nil if values of type t are written as-is.
+//
+// Types with built-in support (time.Time, time.Duration
+// and the sql.Null* types) are always written as-is.
+func marshalerFor(t reflect.Type, format string) reflect.Type {
+	if t == timeType || t == durationType {
+		return nil
+	}
+	if _, ok := nullTypes[t]; ok {
+		return nil
+	}
+	if implements(t, marshalerType) {
+		return marshalerType
+	}
+	list, ok := formatMarshalers[format]
+	if !ok {
+		list = defaultMarshalers
+	}
+	for _, it := range list {
+		if implements(t, it) {
+			return it
+		}
+	}
+	return nil
+}
+
+// implements reports whether values of type t,
+// or pointers to them, implement the interface it.
+func implements(t, it reflect.Type) bool {
+	return t.Implements(it) || reflect.PtrTo(t).Implements(it)
+}
+
+// columnType returns the type of the values written for fields of type t,
+// by a writer of the given format, after any marshaling has taken place.
+// Nullable types are resolved to the type of the values they hold.
+func columnType(t reflect.Type, format string) reflect.Type {
+	t, _ = nullableElem(t)
+	switch marshalerFor(t, format) {
+	case nil:
+		return t
+	case marshalerType:
+		return reflect.New(t).Interface().(Marshaler).PeanutType()
+	case valuerType:
+		return valuerColumnType(t, format)
+	}
+	return stringType
+}
+
+// valuerColumnType returns the type of the values written for
+// fields of type t, a driver.Valuer, by a writer of the given format.
+//
+// The type is that of the value returned for the zero value of t.
+// If that value is nil, or cannot be determined, the type is chosen
+// by the kind of t, as the type database/sql would convert it to.
+func valuerColumnType(t reflect.Type, format string) (ct reflect.Type) {
+	ct = driverType(t)
+	defer func() {
+		// Valuers are not required to handle their zero value.
+		recover()
+	}()
+	dv, err := marshalValue(reflect.Zero(t), valuerType, format)
+	if err != nil || dv == nil {
+		return ct
+	}
+	dt := reflect.TypeOf(dv)
+	switch {
+	case dt == timeType:
+		return dt
+	case dt.Kind() == reflect.Slice && dt.Elem().Kind() == reflect.Uint8:
+		return stringType
+	case supportedKind[dt.Kind()]:
+		return dt
+	}
+	return stringType
+}
+
+// driverType returns the type that database/sql
+// converts values of kind t.Kind() to.
+func driverType(t reflect.Type) reflect.Type {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64Type
+	case reflect.Float32, reflect.Float64:
+		return float64Type
+	case reflect.Bool:
+		return boolType
+	}
+	return stringType
+}
+
+// matchesColumnType reports whether the value x, as returned by
+// marshalValue, can be written to a column of type ct.
+func matchesColumnType(x interface{}, ct reflect.Type) bool {
+	xt := reflect.TypeOf(x)
+	switch {
+	case ct == timeType || xt == timeType:
+		return xt == ct
+	case xt.Kind() == reflect.Slice && xt.Elem().Kind() == reflect.Uint8:
+		// Raw JSON, and []byte values passed to SQLite, are written as text.
+		return ct.Kind() == reflect.String
+	}
+	return xt.Kind() == ct.Kind()
+}
+
+// marshalValue returns the value to be written for v by a writer
+// of the given format, using the interface it, as returned by marshalerFor.
+func marshalValue(v reflect.Value, it reflect.Type, format string) (interface{}, error) {
+	// Use pointer receivers where necessary.
+	x := v.Interface()
+	if !v.Type().Implements(it) {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		x = p.Interface()
+	}
+
+	switch it {
+	case marshalerType:
+		return x.(Marshaler).MarshalPeanut(format)
+	case jsonMarshalerType:
+		b, err := x.(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		if format == formatJSON || format == formatJSONL {
+			return json.RawMessage(b), nil
+		}
+		return string(b), nil
+	case textMarshalerType:
+		b, err := x.(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case stringerType:
+		return x.(fmt.Stringer).String(), nil
+	case valuerType:
+		dv, err := x.(driver.Valuer).Value()
+		if err != nil {
+			return nil, err
+		}
+		if b, ok := dv.([]byte); ok && format != formatSQLite {
+			return string(b), nil
+		}
+		return dv, nil
+	}
+	panic("unreachable")
+}
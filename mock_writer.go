@@ -8,8 +8,12 @@ var _ Writer = &MockWriter{}
 
 // MockWriter captures written data in memory, to provide easy mocking
 // when testing code that uses peanut.
+//
+// Headers and Data are keyed by the names given by NameFunc,
+// which defaults to TypeName.
 type MockWriter struct {
 	*base
+	NameFunc           NameFunc
 	Headers            map[string][]string
 	Data               map[string][]map[string]string
 	DisableDataCapture map[string]bool
@@ -21,19 +25,22 @@ type MockWriter struct {
 func (w *MockWriter) register(x interface{}) (reflect.Type, error) {
 	// Lazy init.
 	if w.base == nil {
-		w.base = &base{}
+		w.base = &base{format: formatMock}
 		w.Headers = make(map[string][]string)
 		w.Data = make(map[string][]map[string]string)
 	}
 	// Register with base writer.
-	t, ok := w.base.register(x)
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
 		return t, nil
 	}
 	if err := allFieldsSupportedKinds(x); err != nil {
 		return nil, err
 	}
-	w.Headers[t.Name()] = w.headersByType[t]
+	w.Headers[w.nameByType[t]] = w.headersByType[t]
 	return t, nil
 }
 
@@ -45,17 +52,38 @@ func (w *MockWriter) Write(x interface{}) error {
 		return err
 	}
 
-	n := t.Name()
+	n := w.nameByType[t]
 	if w.DisableDataCapture != nil && w.DisableDataCapture[n] {
 		return nil
 	}
 	// Capture the row of data.
 	data := w.Data[n]
-	data = append(data, stringValuesAsMap(x))
+	m, err := w.stringValuesAsMap(t, x)
+	if err != nil {
+		return err
+	}
+	data = append(data, m)
 	w.Data[n] = data
 	return nil
 }
 
+func (w *MockWriter) stringValuesAsMap(t reflect.Type, x interface{}) (map[string]string, error) {
+	p := w.planByType[t]
+	if len(p.fields) == 0 {
+		return nil, nil
+	}
+	v, err := p.strings(x, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(v))
+	for i, f := range p.fields {
+		// Put value into map.
+		out[f.header] = v[i]
+	}
+	return out, nil
+}
+
 // Close should be called after successfully writing records.
 func (w *MockWriter) Close() error {
 	w.CalledClose++
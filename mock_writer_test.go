@@ -108,6 +108,59 @@ var _ = Describe("MockWriter", func() {
 		Expect(w.CalledCancel).To(Equal(1))
 	})
 
+	It("should capture flattened headers and data for nested structs", func() {
+		w := &peanut.MockWriter{}
+
+		testWritesNestedAndClose(w)
+
+		Expect(w.Headers["Nested"]).To(Equal([]string{"id", "home_street", "home_city", "work_street", "work_city", "created_by", "updated_by"}))
+		Expect(w.Data["Nested"][1]).To(Equal(map[string]string{
+			"id":          "n2",
+			"home_street": "2 Home St",
+			"home_city":   "Hometown",
+			"work_street": "",
+			"work_city":   "",
+			"created_by":  "carol",
+			"updated_by":  "dave",
+		}))
+	})
+
+	It("should return an error when flattened field names collide", func() {
+		w := &peanut.MockWriter{}
+
+		err := w.Write(&BadDuplicate{})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(SatisfyAll(
+			MatchRegexp("created_by"),
+			MatchRegexp("BadDuplicate"),
+		))
+	})
+
+	It("should return an error when a custom type fails to marshal", func() {
+		w := &peanut.MockWriter{}
+
+		err := w.Write(&Custom{Amount: -1})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(SatisfyAll(
+			MatchRegexp("Custom.Amount"),
+			MatchRegexp("negative amount"),
+		))
+	})
+
+	It("should key headers and data by the names given by NameFunc", func() {
+		w := &peanut.MockWriter{NameFunc: peanut.QualifiedTypeName}
+
+		err := w.Write(&Renamed{ID: "r1"})
+		Expect(err).To(BeNil())
+		err = w.Write(testOutputFoo[0])
+		Expect(err).To(BeNil())
+
+		Expect(w.Headers).To(Equal(map[string][]string{
+			"renamed_records": {"id"},
+			"peanut_test.Foo": {"foo_string", "foo_int"},
+		}))
+	})
+
 	Context("when given a struct with an unsupported field type", func() {
 
 		It("should return an error with an informative message", func() {
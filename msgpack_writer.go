@@ -0,0 +1,238 @@
+package peanut
+
+// MsgpackWriter
+// - See https://msgpack.org
+// - See https://github.com/msgpack/msgpack/blob/master/spec.md
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var _ Writer = &MsgpackWriter{}
+
+// MsgpackWriter writes records to MessagePack files, writing
+// each record type to an individual file automatically.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".msgpack"
+//
+// If Compression is set, files are compressed accordingly,
+// and named with its Extension, as in ".msgpack.gz".
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Each file holds a stream of MessagePack values. The first is
+// a header map, describing the records that follow it, with keys:
+//  name:     the name of the record type
+//  columns:  an array of column names
+//  types:    an array of column type names, such as "string",
+//            "int32", "float64", "bool", "timestamp" or "duration"
+//  nullable: an array of booleans, true for nullable columns
+// Each record is then written as an array of values, in column
+// order, using native MessagePack types. Integers are written
+// in their most compact form. Times are written using the
+// MessagePack timestamp extension type, unless they have a
+// format option, durations are written as integer nanoseconds,
+// and null values are written as nil.
+//
+// The caller must call Close on successful completion
+// of all writing, to ensure buffers are flushed and
+// files are properly written to disk.
+//
+// In the event of an error or cancellation, the
+// caller must call Cancel before quiting, to ensure
+// closure and cleanup of any partially written files.
+type MsgpackWriter struct {
+	*base
+	NameFunc      NameFunc    // NameFunc names the output files, TypeName is used if nil.
+	Compression   Compression // Compression compresses the output files, which are uncompressed by default.
+	prefix        string
+	suffix        string
+	builderByType map[reflect.Type]*msgpackBuilder
+}
+
+// NewMsgpackWriter returns a new MsgpackWriter, using prefix
+// and suffix when building its output filenames.
+//
+// See MsgpackWriter (above) for output filename details.
+func NewMsgpackWriter(prefix, suffix string) *MsgpackWriter {
+	w := MsgpackWriter{
+		base:          &base{format: formatMsgpack},
+		prefix:        prefix,
+		suffix:        suffix,
+		builderByType: make(map[reflect.Type]*msgpackBuilder),
+	}
+	return &w
+}
+
+type msgpackBuilder struct {
+	filename string
+	file     *os.File
+	zw       io.WriteCloser // zw compresses output written to file.
+	bw       *bufio.Writer
+	enc      *msgpack.Encoder
+	values   []interface{} // values is reused for each record written.
+}
+
+func (w *MsgpackWriter) register(x interface{}) (reflect.Type, error) {
+	// Register with base writer.
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t, nil
+	}
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, err
+	}
+	if len(w.base.tagsByType[t]) == 0 {
+		return t, nil
+	}
+
+	// log.Printf("Setting up msgpack.Encoder for %s", t.Name())
+
+	name := w.prefix + w.nameByType[t] + w.suffix + ".msgpack" + w.Compression.Extension()
+	file, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		return nil, err
+	}
+	zw, err := w.Compression.newWriter(file)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	bw := bufio.NewWriter(zw)
+	enc := msgpack.NewEncoder(bw)
+	enc.UseCompactInts(true)
+	c := &msgpackBuilder{filename: name, file: file, zw: zw, bw: bw, enc: enc}
+	w.builderByType[t] = c
+
+	return t, enc.Encode(newBinaryHeader(w.nameByType[t], w.planByType[t], w.format))
+}
+
+// Write is called to persist records.
+// Each record is written as an individual array
+// in the corresponding output file, according to the
+// type of the given record.
+func (w *MsgpackWriter) Write(x interface{}) error {
+	if w.closed {
+		return ErrClosedWriter
+	}
+	t, err := w.register(x)
+	if err != nil {
+		return err
+	}
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *MsgpackWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	var err error
+	// log.Printf("WriteRecord for %s", t.Name())
+	c := w.builderByType[t]
+	c.values, err = p.values(x, c.values[:0])
+	if err != nil {
+		return err
+	}
+	for i, val := range c.values {
+		c.values[i] = binaryValue(val, p.fields[i])
+	}
+	return c.enc.Encode(c.values)
+}
+
+// Close flushes all buffers and writers,
+// and closes the output files.
+//
+// Calling Close after a previous call to
+// Cancel is safe, and always results in a no-op.
+func (w *MsgpackWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, c := range w.builderByType {
+		var cerr error
+		var err error
+
+		err = c.bw.Flush()
+		if err != nil {
+			cerr = err
+		}
+
+		err = c.zw.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		// Chmod the file world-readable (ioutil.TempFile creates files with
+		// mode 0600) before renaming.
+		err = c.file.Chmod(0644)
+		if err != nil {
+			cerr = err
+		}
+
+		// fsync(2) after fchmod(2) orders writes as per
+		// https://lwn.net/Articles/270891/.
+		c.file.Sync()
+
+		err = c.file.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		if cerr != nil {
+			rerr = cerr
+			continue
+		}
+
+		err = os.Rename(c.file.Name(), c.filename)
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// Cancel should be called in the event of an error occurring,
+// to properly close and delete any partially written files.
+func (w *MsgpackWriter) Cancel() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, c := range w.builderByType {
+		var err error
+
+		// Release any resources held by the compressor.
+		c.zw.Close()
+
+		err = c.file.Close()
+		if err != nil {
+			rerr = err
+		}
+
+		err = os.Remove(c.file.Name())
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
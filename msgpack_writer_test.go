@@ -0,0 +1,131 @@
+package peanut_test
+
+import (
+	"io"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/jimsmart/peanut"
+)
+
+var _ = Describe("MsgpackWriter", func() {
+
+	AfterEach(func() {
+		os.Remove("./test/output-Foo-sequential.msgpack")
+		os.Remove("./test/output-Bar-sequential.msgpack")
+		os.Remove("./test/output-Baz-sequential.msgpack")
+		os.Remove("./test/output-Times-times.msgpack")
+		os.Remove("./test/output-Nullable-nullable.msgpack")
+	})
+
+	It("should write a header and native values when sequential structs are written", func() {
+		w := peanut.NewMsgpackWriter("./test/output-", "-sequential")
+
+		testWritesAndCloseSequential(w)
+
+		header, records, err := readMsgpack("./test/output-Foo-sequential.msgpack")
+		Expect(err).To(BeNil())
+		Expect(header).To(Equal(map[string]interface{}{
+			"name":     "Foo",
+			"columns":  []interface{}{"foo_string", "foo_int"},
+			"types":    []interface{}{"string", "int64"},
+			"nullable": []interface{}{false, false},
+		}))
+		Expect(records).To(Equal([][]interface{}{
+			{"test 1", int8(1)},
+			{"test 2", int8(2)},
+			{"test 3", int8(3)},
+		}))
+
+		header, records, err = readMsgpack("./test/output-Baz-sequential.msgpack")
+		Expect(err).To(BeNil())
+		Expect(header["types"]).To(Equal([]interface{}{
+			"string", "bool", "float64", "float64", "int64", "int8", "int16", "int32",
+			"int64", "uint64", "uint8", "uint16", "uint32", "uint64",
+		}))
+		Expect(records).To(Equal([][]interface{}{
+			{"test 1", true, 1.234, 9.876, int16(-12345), int8(-8), int8(-16), int8(-32), int8(-64),
+				uint16(12345), int8(8), int8(16), int8(32), int8(64)},
+		}))
+
+		Expect("./test/output-Qux-sequential.msgpack").ToNot(BeAnExistingFile())
+	})
+
+	It("should write times, durations and nulls", func() {
+		w := peanut.NewMsgpackWriter("./test/output-", "-times")
+
+		testWritesTimesAndClose(w)
+
+		header, records, err := readMsgpack("./test/output-Times-times.msgpack")
+		Expect(err).To(BeNil())
+		Expect(header["types"]).To(Equal([]interface{}{"string", "timestamp", "string", "duration"}))
+		Expect(records).To(HaveLen(1))
+		Expect(records[0][1].(time.Time).Equal(testOutputTimes[0].Time)).To(BeTrue())
+		Expect(records[0][2]).To(Equal("2021-04-19"))
+		Expect(records[0][3]).To(Equal(uint64(90 * time.Minute)))
+
+		w = peanut.NewMsgpackWriter("./test/output-", "-nullable")
+
+		testWritesNullableAndClose(w)
+
+		header, records, err = readMsgpack("./test/output-Nullable-nullable.msgpack")
+		Expect(err).To(BeNil())
+		Expect(header["nullable"]).To(Equal([]interface{}{false, true, true, true, true, true, true}))
+		Expect(records[1]).To(Equal([]interface{}{"n2", nil, nil, nil, nil, nil, nil}))
+	})
+
+	It("should not write anything when structs are written and cancel is called", func() {
+		w := peanut.NewMsgpackWriter("./test/output-", "-cancel")
+
+		testWritesAndCancel(w)
+
+		Expect("./test/output-Foo-cancel.msgpack").ToNot(BeAnExistingFile())
+		Expect("./test/output-Bar-cancel.msgpack").ToNot(BeAnExistingFile())
+	})
+
+	It("should return an error when Write is called after Close", func() {
+		w := peanut.NewMsgpackWriter("./test/output-", "-close-write")
+
+		testWriteAfterClose(w)
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			w := peanut.NewMsgpackWriter("./no-such-location/output-bogus-", "")
+
+			testWriteBadType(w)
+		})
+	})
+})
+
+// readMsgpack reads the header and records of a file written by MsgpackWriter.
+func readMsgpack(filename string) (map[string]interface{}, [][]interface{}, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	dec := msgpack.NewDecoder(f)
+	var header map[string]interface{}
+	if err := dec.Decode(&header); err != nil {
+		return nil, nil, err
+	}
+	var records [][]interface{}
+	for {
+		var r []interface{}
+		err := dec.Decode(&r)
+		if err == io.EOF {
+			return header, records, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, r)
+	}
+}
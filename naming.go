@@ -0,0 +1,84 @@
+package peanut
+
+import (
+	"path"
+	"reflect"
+)
+
+// NameFunc returns the name used for the output file,
+// sheet or table of records of type t.
+//
+// Writers use TypeName unless configured otherwise.
+type NameFunc func(t reflect.Type) string
+
+// Namer is the interface implemented by record types
+// that choose the name used for their output files,
+// sheets and tables.
+//
+// PeanutName is called on the zero value of the type,
+// and should return the same, non-empty, name for all values.
+type Namer interface {
+	PeanutName() string
+}
+
+var namerType = reflect.TypeOf((*Namer)(nil)).Elem()
+
+// TypeName returns the name of the struct type t, as used by
+// writers when naming their output files and tables.
+//
+// If the type implements Namer, the name it returns is used.
+// Otherwise the name can be set explicitly using a struct-level
+// tag, given on a blank field of type struct{}:
+//  type Shape struct {
+//  	_       struct{} `peanut:"shapes"`
+//  	ShapeID string   `peanut:"shape_id"`
+//  }
+// Otherwise the name of the type itself is used.
+func TypeName(t reflect.Type) string {
+	if n, ok := explicitTypeName(t); ok {
+		return n
+	}
+	return t.Name()
+}
+
+// QualifiedTypeName is like TypeName, but qualifies the name
+// of the type itself (when not named by Namer or a struct-level
+// tag) with the name of its package, e.g. "models.Shape", making
+// it a suitable NameFunc when writing records of types having the
+// same name but declared in different packages.
+func QualifiedTypeName(t reflect.Type) string {
+	if n, ok := explicitTypeName(t); ok {
+		return n
+	}
+	if t.PkgPath() == "" {
+		return t.Name()
+	}
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
+
+// explicitTypeName returns the name given by type t,
+// either by implementing Namer or by a struct-level tag.
+func explicitTypeName(t reflect.Type) (string, bool) {
+	if implements(t, namerType) {
+		return reflect.New(t).Interface().(Namer).PeanutName(), true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Name != "_" || f.Type.Kind() != reflect.Struct || f.Type.NumField() != 0 {
+			continue
+		}
+		if n := firstTagValue(f.Tag.Get(tagName)); n != "" {
+			return n, true
+		}
+	}
+	return "", false
+}
+
+// fullTypeName returns the name of type t qualified
+// by its full package path, for use in error messages.
+func fullTypeName(t reflect.Type) string {
+	if t.PkgPath() == "" {
+		return t.Name()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
@@ -0,0 +1,275 @@
+package peanut
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+var _ Writer = &ParquetWriter{}
+
+// DefaultParquetRowGroupSize is the number of rows written
+// to each row group when ParquetWriter.RowGroupSize is zero.
+const DefaultParquetRowGroupSize = 64 * 1024
+
+// parquetCodecs maps codec names to Parquet compression codecs.
+var parquetCodecs = map[string]compress.Compression{
+	"":             compress.Codecs.Snappy,
+	"snappy":       compress.Codecs.Snappy,
+	"gzip":         compress.Codecs.Gzip,
+	"zstd":         compress.Codecs.Zstd,
+	"brotli":       compress.Codecs.Brotli,
+	"uncompressed": compress.Codecs.Uncompressed,
+}
+
+// ParquetWriter writes records to Apache Parquet files, writing
+// each record type to an individual Parquet file automatically.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".parquet"
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Columns are named using the names extracted from the struct's
+// field tags, in the order that they appear within the struct,
+// and are typed according to the fields' types: strings as UTF-8
+// byte arrays, integers as signed or unsigned integers of the
+// same width, floats as floats or doubles, bools as booleans,
+// times as UTC timestamps with microsecond precision (or as text,
+// if their tag has a format option), and durations as
+// integer nanoseconds. Only nullable fields (pointers and sql.Null*
+// types) have optional columns.
+//
+// Records are buffered in memory, and written as row groups of
+// RowGroupSize rows, which defaults to DefaultParquetRowGroupSize.
+//
+// Pages are compressed using the named Codec, which is
+// one of "snappy" (the default), "gzip", "zstd", "brotli"
+// or "uncompressed".
+//
+// The caller must call Close on successful completion
+// of all writing, to ensure buffers are flushed and
+// files are properly written to disk.
+//
+// In the event of an error or cancellation, the
+// caller must call Cancel before quiting, to ensure
+// closure and cleanup of any partially written files.
+type ParquetWriter struct {
+	*base
+	NameFunc      NameFunc // NameFunc names the output files, TypeName is used if nil.
+	RowGroupSize  int      // RowGroupSize is the number of rows in each row group.
+	Codec         string   // Codec names the compression codec used.
+	prefix        string
+	suffix        string
+	builderByType map[reflect.Type]*parquetBuilder
+}
+
+// NewParquetWriter returns a new ParquetWriter, using prefix
+// and suffix when building its output filenames.
+//
+// See ParquetWriter (above) for output filename details.
+func NewParquetWriter(prefix, suffix string) *ParquetWriter {
+	w := ParquetWriter{
+		base:          &base{format: formatParquet},
+		prefix:        prefix,
+		suffix:        suffix,
+		builderByType: make(map[reflect.Type]*parquetBuilder),
+	}
+	return &w
+}
+
+type parquetBuilder struct {
+	filename string
+	file     *os.File
+	pw       *pqarrow.FileWriter
+	ab       *arrowBuilder
+	size     int // size is the number of rows in each row group.
+}
+
+// nopCloseWriter hides the Close method of a file from writers
+// that close their output, so that it can be synced before closing.
+type nopCloseWriter struct {
+	io.Writer
+}
+
+func (w *ParquetWriter) register(x interface{}) (reflect.Type, error) {
+	// Register with base writer.
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t, nil
+	}
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, err
+	}
+	if len(w.base.tagsByType[t]) == 0 {
+		return t, nil
+	}
+
+	codec, ok := parquetCodecs[w.Codec]
+	if !ok {
+		return nil, fmt.Errorf("peanut: unsupported Parquet codec: %q", w.Codec)
+	}
+	size := w.RowGroupSize
+	if size <= 0 {
+		size = DefaultParquetRowGroupSize
+	}
+
+	// log.Printf("Setting up Parquet writer for %s", t.Name())
+
+	name := w.prefix + w.nameByType[t] + w.suffix + ".parquet"
+	file, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		return nil, err
+	}
+	ab := newArrowBuilder(w.planByType[t], formatParquet)
+	props := parquet.NewWriterProperties(
+		parquet.WithCompression(codec),
+		parquet.WithMaxRowGroupLength(int64(size)),
+	)
+	pw, err := pqarrow.NewFileWriter(ab.Schema(), nopCloseWriter{file}, props, pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema()))
+	if err != nil {
+		ab.Release()
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	w.builderByType[t] = &parquetBuilder{filename: name, file: file, pw: pw, ab: ab, size: size}
+	return t, nil
+}
+
+// Write is called to persist records.
+// Each record is written to an individual row
+// in the corresponding output file, according to the
+// type of the given record.
+func (w *ParquetWriter) Write(x interface{}) error {
+	if w.closed {
+		return ErrClosedWriter
+	}
+	t, err := w.register(x)
+	if err != nil {
+		return err
+	}
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *ParquetWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	var err error
+	// log.Printf("WriteRecord for %s", t.Name())
+	b := w.builderByType[t]
+	err = b.ab.Append(x)
+	if err != nil {
+		return err
+	}
+	if b.ab.rows < b.size {
+		return nil
+	}
+	return b.flush()
+}
+
+// flush writes any buffered rows as a new row group.
+func (b *parquetBuilder) flush() error {
+	if b.ab.rows == 0 {
+		return nil
+	}
+	rec := b.ab.NewRecord()
+	defer rec.Release()
+	return b.pw.Write(rec)
+}
+
+// Close flushes all buffers and writers,
+// and closes the output files.
+//
+// Calling Close after a previous call to
+// Cancel is safe, and always results in a no-op.
+func (w *ParquetWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	var rerr error
+	for _, b := range w.builderByType {
+		var cerr error
+		var err error
+
+		err = b.flush()
+		if err != nil {
+			cerr = err
+		}
+		b.ab.Release()
+
+		// Write the file footer.
+		err = b.pw.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		// Chmod the file world-readable (ioutil.TempFile creates files with
+		// mode 0600) before renaming.
+		err = b.file.Chmod(0644)
+		if err != nil {
+			cerr = err
+		}
+
+		// fsync(2) after fchmod(2) orders writes as per
+		// https://lwn.net/Articles/270891/.
+		b.file.Sync()
+
+		err = b.file.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		if cerr != nil {
+			rerr = cerr
+			continue
+		}
+
+		err = os.Rename(b.file.Name(), b.filename)
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// Cancel should be called in the event of an error occurring,
+// to properly close and delete any partially written files.
+func (w *ParquetWriter) Cancel() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	var rerr error
+	for _, b := range w.builderByType {
+		var err error
+
+		b.ab.Release()
+
+		err = b.file.Close()
+		if err != nil {
+			rerr = err
+		}
+
+		err = os.Remove(b.file.Name())
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
@@ -0,0 +1,244 @@
+package peanut_test
+
+import (
+	"context"
+	"os"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+var _ = Describe("ParquetWriter", func() {
+
+	newFn := func(suffix string) peanut.Writer {
+		w := peanut.NewParquetWriter("./test/output-", suffix)
+		return w
+	}
+
+	AfterEach(func() {
+		os.Remove("./test/output-Foo-sequential.parquet")
+		os.Remove("./test/output-Bar-sequential.parquet")
+		os.Remove("./test/output-Baz-sequential.parquet")
+		os.Remove("./test/output-Qux-sequential.parquet")
+		os.Remove("./test/output-Foo-interleave.parquet")
+		os.Remove("./test/output-Bar-interleave.parquet")
+		os.Remove("./test/output-Baz-interleave.parquet")
+		os.Remove("./test/output-Qux-interleave.parquet")
+		os.Remove("./test/output-Times-times.parquet")
+		os.Remove("./test/output-Nullable-nullable.parquet")
+		os.Remove("./test/output-Foo-groups.parquet")
+		os.Remove("./test/output-Account-valuers.parquet")
+		os.Remove("./test/output-Times-early.parquet")
+	})
+
+	expectedFoo := [][]string{
+		{"foo_string", "foo_int"},
+		{"test 1", "1"},
+		{"test 2", "2"},
+		{"test 3", "3"},
+	}
+
+	expectedBaz := [][]string{
+		{"baz_string", "baz_bool", "baz_float32", "baz_float64", "baz_int", "baz_int8", "baz_int16", "baz_int32", "baz_int64", "baz_uint", "baz_uint8", "baz_uint16", "baz_uint32", "baz_uint64"},
+		{"test 1", "true", "1.234", "9.876", "-12345", "-8", "-16", "-32", "-64", "12345", "8", "16", "32", "64"},
+	}
+
+	It("should write the correct data when sequential structs are written", func() {
+		w := newFn("-sequential")
+
+		testWritesAndCloseSequential(w)
+
+		output, schema, err := readParquet("./test/output-Foo-sequential.parquet")
+		Expect(err).To(BeNil())
+		Expect(output).To(Equal(expectedFoo))
+		Expect(schema.Field(1).Type).To(Equal(arrow.PrimitiveTypes.Int64))
+		Expect(schema.Field(1).Nullable).To(BeFalse())
+
+		output, schema, err = readParquet("./test/output-Baz-sequential.parquet")
+		Expect(err).To(BeNil())
+		Expect(output).To(Equal(expectedBaz))
+		var types []arrow.DataType
+		for _, f := range schema.Fields() {
+			types = append(types, f.Type)
+		}
+		Expect(types).To(Equal([]arrow.DataType{
+			arrow.BinaryTypes.String,
+			arrow.FixedWidthTypes.Boolean,
+			arrow.PrimitiveTypes.Float64,
+			arrow.PrimitiveTypes.Float64,
+			arrow.PrimitiveTypes.Int64,
+			arrow.PrimitiveTypes.Int8,
+			arrow.PrimitiveTypes.Int16,
+			arrow.PrimitiveTypes.Int32,
+			arrow.PrimitiveTypes.Int64,
+			arrow.PrimitiveTypes.Uint64,
+			arrow.PrimitiveTypes.Uint8,
+			arrow.PrimitiveTypes.Uint16,
+			arrow.PrimitiveTypes.Uint32,
+			arrow.PrimitiveTypes.Uint64,
+		}))
+
+		_, err = os.Stat("./test/output-Qux-sequential.parquet")
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("should write the correct data when interleaved structs are written", func() {
+		w := newFn("-interleave")
+
+		testWritesAndCloseInterleaved(w)
+
+		output, _, err := readParquet("./test/output-Foo-interleave.parquet")
+		Expect(err).To(BeNil())
+		Expect(output).To(Equal(expectedFoo))
+
+		output, _, err = readParquet("./test/output-Baz-interleave.parquet")
+		Expect(err).To(BeNil())
+		Expect(output).To(Equal(expectedBaz))
+	})
+
+	It("should not leave any files when cancelled", func() {
+		w := newFn("-cancel")
+
+		testWritesAndCancel(w)
+
+		_, err := os.Stat("./test/output-Foo-cancel.parquet")
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("should return an error when Write is called after Close", func() {
+		testWriteAfterClose(newFn("-closed"))
+	})
+
+	It("should write times as timestamps, and durations", func() {
+		w := newFn("-times")
+
+		testWritesTimesAndClose(w)
+
+		output, schema, err := readParquet("./test/output-Times-times.parquet")
+		Expect(err).To(BeNil())
+		Expect(arrow.TypeEqual(schema.Field(1).Type, arrow.FixedWidthTypes.Timestamp_us)).To(BeTrue())
+		Expect(schema.Field(2).Type).To(Equal(arrow.BinaryTypes.String))
+		Expect(schema.Field(3).Type).To(Equal(arrow.PrimitiveTypes.Int64))
+		Expect(output[1]).To(Equal([]string{"t1", "2021-04-19T13:45:30Z", "2021-04-19", "5400000000000"}))
+	})
+
+	It("should write zero times, and times before 1678", func() {
+		w := newFn("-early")
+		for _, x := range testOutputEarlyTimes {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		Expect(w.Close()).To(BeNil())
+
+		output, _, err := readParquet("./test/output-Times-early.parquet")
+		Expect(err).To(BeNil())
+		Expect(output[1:]).To(Equal([][]string{
+			{"t0", "0001-01-01T00:00:00Z", "0001-01-01", "0"},
+			{"t1", "1500-06-01T12:30:00.123456Z", "1500-06-01", "0"},
+		}))
+	})
+
+	It("should declare columns of driver.Valuer types by the kind of their values", func() {
+		w := newFn("-valuers")
+
+		testWritesValuersAndClose(w)
+
+		output, schema, err := readParquet("./test/output-Account-valuers.parquet")
+		Expect(err).To(BeNil())
+		Expect(schema.Field(1).Type).To(Equal(arrow.BinaryTypes.String))
+		Expect(schema.Field(2).Type).To(Equal(arrow.PrimitiveTypes.Int64))
+		Expect(output[1:]).To(Equal([][]string{{"a1", "active", "2"}}))
+	})
+
+	It("should write null values to optional columns", func() {
+		w := newFn("-nullable")
+
+		testWritesNullableAndClose(w)
+
+		output, schema, err := readParquet("./test/output-Nullable-nullable.parquet")
+		Expect(err).To(BeNil())
+		Expect(schema.Field(0).Nullable).To(BeFalse())
+		for _, f := range schema.Fields()[1:] {
+			Expect(f.Nullable).To(BeTrue())
+		}
+		Expect(output[2]).To(Equal([]string{"n2", "(null)", "(null)", "(null)", "(null)", "(null)", "(null)"}))
+	})
+
+	It("should write row groups of the configured size, using the configured codec", func() {
+		w := peanut.NewParquetWriter("./test/output-", "-groups")
+		w.RowGroupSize = 2
+		w.Codec = "zstd"
+		for _, x := range testOutputFoo {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		Expect(w.Close()).To(BeNil())
+
+		rdr, err := file.OpenParquetFile("./test/output-Foo-groups.parquet", false)
+		Expect(err).To(BeNil())
+		defer rdr.Close()
+		Expect(rdr.NumRowGroups()).To(Equal(2))
+		Expect(rdr.NumRows()).To(Equal(int64(3)))
+		cc, err := rdr.MetaData().RowGroup(0).ColumnChunk(0)
+		Expect(err).To(BeNil())
+		Expect(cc.Compression()).To(Equal(compress.Codecs.Zstd))
+	})
+
+	It("should return an error for an unsupported codec", func() {
+		w := peanut.NewParquetWriter("./test/output-", "-codec")
+		w.Codec = "lzo"
+		err := w.Write(testOutputFoo[0])
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(MatchRegexp("lzo"))
+		Expect(w.Cancel()).To(BeNil())
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			w := peanut.NewParquetWriter("./no-such-location/output-bogus-", "")
+
+			testWriteBadType(w)
+		})
+	})
+})
+
+// readParquet returns the rows of the given Parquet file as text,
+// headers first, and its Arrow schema.
+func readParquet(filename string) ([][]string, *arrow.Schema, error) {
+	rdr, err := file.OpenParquetFile(filename, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rdr.Close()
+	fr, err := pqarrow.NewFileReader(rdr, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		return nil, nil, err
+	}
+	tbl, err := fr.ReadTable(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tbl.Release()
+
+	var out [][]string
+	var headers []string
+	for _, f := range tbl.Schema().Fields() {
+		headers = append(headers, f.Name)
+	}
+	out = append(out, headers)
+	for i := 0; i < int(tbl.NumRows()); i++ {
+		var row []string
+		for c := 0; c < int(tbl.NumCols()); c++ {
+			// Each test file has a single chunk per column, unless row groups are used.
+			row = append(row, tbl.Column(c).Data().Chunk(0).ValueStr(i))
+		}
+		out = append(out, row)
+	}
+	return out, tbl.Schema(), nil
+}
@@ -1,10 +1,12 @@
 package peanut
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 	"unicode"
 	"unicode/utf8"
 )
@@ -24,16 +26,50 @@ var ErrClosedWriter = errors.New("peanut: write on closed writer")
 const tagName = "peanut"
 
 func reflectStructFields(x interface{}, fn func(name string, t reflect.Type, tag string)) {
-
-	// TODO(js) This should work with Ptr and non-Ptr.
-
 	// TypeOf returns the reflection Type that represents the dynamic type of variable.
 	// If variable is a nil interface value, TypeOf returns nil.
 	t := baseType(x)
 
-	// Get the type and kind of our user variable.
-	// fmt.Println("Type:", t.Name())
-	// fmt.Println("Kind:", t.Kind())
+	for _, f := range structFields(t) {
+		fn(f.name, f.typ, f.tag)
+	}
+}
+
+// structField is a tagged field of a struct, possibly promoted
+// from an embedded struct, or inlined from a nested struct.
+type structField struct {
+	name  string       // name is the field name, qualified by the names of any enclosing fields.
+	index []int        // index is the index sequence for the field, as used by FieldByIndex.
+	typ   reflect.Type // typ is the field type, made nullable if the field is reached via a pointer.
+	decl  reflect.Type // decl is the field type, as declared.
+	tag   string       // tag is the field tag, with any inline prefix applied to its name.
+}
+
+// structFields returns the tagged fields of the struct type t,
+// in the order that they appear within the struct.
+//
+// Fields of untagged embedded structs are promoted,
+// in the same manner as encoding/json. Fields of nested
+// structs tagged with the inline option are flattened,
+// with their names prefixed by the nested struct's tag
+// name and an underscore, or by the value of a prefix
+// option, if given:
+//  type Person struct {
+//  	Name string  `peanut:"name"`
+//  	Home Address `peanut:"home,inline"`           // home_street, home_city
+//  	Work Address `peanut:",inline,prefix=office_"` // office_street, office_city
+//  }
+func structFields(t reflect.Type) []structField {
+	return appendStructFields(nil, t, nil, "", "", false, make(map[reflect.Type]bool))
+}
+
+func appendStructFields(out []structField, t reflect.Type, index []int, qual, prefix string, nullable bool, seen map[reflect.Type]bool) []structField {
+	// Guard against recursive embedding.
+	if seen[t] {
+		return out
+	}
+	seen[t] = true
+	defer delete(seen, t)
 
 	// Iterate over all available fields and read the tag value.
 	for i := 0; i < t.NumField(); i++ {
@@ -41,20 +77,96 @@ func reflectStructFields(x interface{}, fn func(name string, t reflect.Type, tag
 		field := t.Field(i)
 		tag := field.Tag.Get(tagName)
 
+		idx := make([]int, len(index)+1)
+		copy(idx, index)
+		idx[len(index)] = i
+
+		// Is this a struct (or a pointer to a struct) to be flattened?
+		st := field.Type
+		ptr := st.Kind() == reflect.Ptr
+		if ptr {
+			st = st.Elem()
+		}
+		flatten := st.Kind() == reflect.Struct && !supportedType(st)
+
+		// Promote fields of untagged embedded structs.
+		if flatten && field.Anonymous && tag == "" {
+			out = appendStructFields(out, st, idx, qual+field.Name+".", prefix, nullable || ptr, seen)
+			continue
+		}
+
 		// Only process fields with appropriate tags.
-		if tag != "" {
-			name := field.Name
-			// Filter out unexported fields.
-			r, _ := utf8.DecodeRuneInString(name)
-			if !unicode.IsUpper(r) {
-				continue
+		if tag == "" {
+			continue
+		}
+
+		// Filter out unexported fields.
+		r, _ := utf8.DecodeRuneInString(field.Name)
+		if !unicode.IsUpper(r) {
+			continue
+		}
+
+		// Flatten nested structs tagged as inline.
+		if flatten && hasTagOption(tag, "inline") {
+			p, ok := tagOptionValue(tag, "prefix")
+			if !ok {
+				p = firstTagValue(tag)
+				if p != "" {
+					p += "_"
+				}
 			}
+			out = appendStructFields(out, st, idx, qual+field.Name+".", prefix+p, nullable || ptr, seen)
+			continue
+		}
 
-			fn(name, field.Type, tag)
+		ft := field.Type
+		if nullable {
+			// Fields reached via a pointer are null when the pointer is nil.
+			if _, ok := nullableElem(ft); !ok {
+				ft = reflect.PtrTo(ft)
+			}
 		}
+		out = append(out, structField{
+			name:  qual + field.Name,
+			index: idx,
+			typ:   ft,
+			decl:  field.Type,
+			tag:   prefix + tag,
+		})
+	}
+	return out
+}
 
-		// fmt.Printf("%d. %v (%v), tag: '%v'\n", i+1, field.Name, field.Type.Name(), tag)
+// fieldByIndex returns the nested field of v corresponding to index,
+// in the manner of reflect.Value.FieldByIndex, but returns false
+// instead of panicking if a nil pointer is encountered along the way.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
 	}
+	return v, true
+}
+
+// fieldByIndexAlloc returns the nested field of v corresponding to index,
+// in the manner of reflect.Value.FieldByIndex, allocating any nil
+// pointers encountered along the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
 }
 
 func baseType(x interface{}) reflect.Type {
@@ -83,90 +195,157 @@ var supportedKind = map[reflect.Kind]bool{
 	reflect.Uint:    true,
 }
 
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// nullTypes maps the nullable types of package database/sql
+// to the types of the values they hold.
+var nullTypes = map[reflect.Type]reflect.Type{
+	reflect.TypeOf(sql.NullString{}):  reflect.TypeOf(""),
+	reflect.TypeOf(sql.NullBool{}):    reflect.TypeOf(false),
+	reflect.TypeOf(sql.NullFloat64{}): reflect.TypeOf(float64(0)),
+	reflect.TypeOf(sql.NullInt64{}):   reflect.TypeOf(int64(0)),
+	reflect.TypeOf(sql.NullInt32{}):   reflect.TypeOf(int32(0)),
+	reflect.TypeOf(sql.NullInt16{}):   reflect.TypeOf(int16(0)),
+	reflect.TypeOf(sql.NullByte{}):    reflect.TypeOf(uint8(0)),
+	reflect.TypeOf(sql.NullTime{}):    timeType,
+}
+
+// nullableElem returns the type of the values held by
+// the nullable type t, and true, if t is a pointer type
+// or one of the sql.Null* types. Otherwise it returns t and false.
+func nullableElem(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem(), true
+	}
+	if e, ok := nullTypes[t]; ok {
+		return e, true
+	}
+	return t, false
+}
+
+// supportedType reports whether values of type t can be written.
+// In addition to the supported kinds, time.Time is supported
+// (time.Duration is supported by virtue of its kind), as are
+// pointers to supported types, the sql.Null* types, and types
+// implementing Marshaler or any of the other interfaces
+// honoured by marshalValue.
+func supportedType(t reflect.Type) bool {
+	t, _ = nullableElem(t)
+	if t == timeType {
+		return true
+	}
+	if t.Kind() != reflect.Ptr && marshalerFor(t, "") != nil {
+		return true
+	}
+	return supportedKind[t.Kind()]
+}
+
 func allFieldsSupportedKinds(x interface{}) error {
 	var err error
+	seen := make(map[string]bool)
 	reflectStructFields(x, func(name string, t reflect.Type, tag string) {
-		if !supportedKind[t.Kind()] && err == nil {
-			sn := baseType(x).Name()
+		if err != nil {
+			return
+		}
+		sn := baseType(x).Name()
+		if !supportedType(t) {
 			err = fmt.Errorf("peanut: unsupported type: %s in %s.%s", t.Kind().String(), sn, name)
+			return
 		}
+		h := firstTagValue(tag)
+		if seen[h] {
+			err = fmt.Errorf("peanut: duplicate field name: %s in %s.%s", h, sn, name)
+			return
+		}
+		seen[h] = true
 	})
 	return err
 }
 
-func reflectStructValues(x interface{}, fn func(name string, t reflect.Type, v interface{}, tag string)) {
-
-	// TODO(js) This should work with Ptr and non-Ptr.
+// defaultTimeFormat is the layout used when writing time.Time values
+// as text, unless a field's tag specifies otherwise.
+const defaultTimeFormat = time.RFC3339
 
-	t := reflect.ValueOf(x)
-	// TODO This was taken from elsewhere, and modified - but can t.Kind even be a ptr here?
-	if t.Kind() == reflect.Ptr {
-		t = t.Elem()
+// formatValue returns the text representation of v,
+// using layout to format time.Time values.
+// Null values are represented by an empty string.
+func formatValue(v interface{}, layout string) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(layout)
+	case string:
+		return v
 	}
+	return fmt.Sprintf("%v", v)
+}
 
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Type().Field(i)
-		tag := field.Tag.Get(tagName)
-
-		// Only process fields with appropriate tags.
-		if tag != "" {
-
-			name := field.Name
-			// Filter out unexported fields.
-			r, _ := utf8.DecodeRuneInString(name)
-			if !unicode.IsUpper(r) {
-				continue
-			}
-
-			val := t.Field(i).Interface()
-
-			fn(name, field.Type, val, tag)
+// builtinValue returns v converted to the built-in type of its kind,
+// as values may be of named types, so that writers can handle values
+// with a type switch. Signed and unsigned integers are converted to
+// int64 and uint64, and byte slices to []byte. Values of type
+// time.Time and time.Duration, and nil, are returned unchanged.
+func builtinValue(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, time.Time, time.Duration, string, bool, int64, uint64, float32, float64, []byte:
+		return v
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint()
+	case reflect.Float32:
+		return float32(rv.Float())
+	case reflect.Float64:
+		return rv.Float()
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv.Bytes()
 		}
-
-		// fmt.Printf("Field Name: %s,\t Field Value: %v,\t Tag Value: %s\n", field.Name, val, tag)
 	}
+	return v
 }
 
-func stringValues(x interface{}) []string {
-	var out []string
-	reflectStructValues(x, func(name string, t reflect.Type, v interface{}, tag string) {
-		// Append stringified value to list.
-		out = append(out, fmt.Sprintf("%v", v))
-	})
-	return out
-}
-
-func stringValuesAsMap(x interface{}) map[string]string {
-	out := make(map[string]string)
-	reflectStructValues(x, func(name string, t reflect.Type, v interface{}, tag string) {
-		tag = firstTagValue(tag)
-		// Put value into map.
-		out[tag] = fmt.Sprintf("%v", v)
-	})
-	if len(out) == 0 {
-		return nil
+// timeFormat returns the layout to use for a time.Time field with the given tag.
+func timeFormat(tag string) string {
+	if f, ok := tagOptionValue(tag, "format"); ok {
+		return f
 	}
-	return out
-}
-
-func mapValues(x interface{}) map[string]interface{} {
-	out := make(map[string]interface{})
-	reflectStructValues(x, func(name string, t reflect.Type, v interface{}, tag string) {
-		tag = firstTagValue(tag)
-		// Put value into map.
-		out[tag] = v
-	})
-	return out
+	return defaultTimeFormat
 }
 
 func firstTagValue(s string) string {
 	return strings.Split(s, ",")[0]
 }
 
-func secondTagValue(s string) string {
-	x := strings.Split(s, ",")
-	if len(x) > 1 {
-		return x[1]
+// hasTagOption reports whether the tag s contains
+// the given option, following the name.
+func hasTagOption(s, opt string) bool {
+	for _, o := range strings.Split(s, ",")[1:] {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// tagOptionValue returns the value of a key=value option
+// in the tag s, and whether the option was present.
+func tagOptionValue(s, key string) (string, bool) {
+	for _, o := range strings.Split(s, ",")[1:] {
+		if strings.HasPrefix(o, key+"=") {
+			return o[len(key)+1:], true
+		}
 	}
-	return ""
+	return "", false
 }
@@ -1,6 +1,21 @@
 package peanut
 
-import "testing"
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+
+	"github.com/jimsmart/peanut/internal/gentest"
+)
 
 func TestSupportedKinds(t *testing.T) {
 	for k := range supportedKind {
@@ -8,5 +23,341 @@ func TestSupportedKinds(t *testing.T) {
 		if _, ok := kindToDBType[k]; !ok {
 			t.Fail()
 		}
+		// Arrow-based writers' lookup table should too.
+		if _, ok := kindToArrowType[k]; !ok {
+			t.Fail()
+		}
+		// As should AvroWriter's.
+		if _, ok := kindToAvroType[k]; !ok {
+			t.Fail()
+		}
+		// And SQLDumpWriter's, for each dialect.
+		if _, ok := kindToPostgreSQLType[k]; !ok {
+			t.Fail()
+		}
+		if _, ok := kindToMySQLType[k]; !ok {
+			t.Fail()
+		}
+		// As should ProtobufWriter's.
+		if _, ok := kindToProtoType[k]; !ok {
+			t.Fail()
+		}
+	}
+}
+
+// valuerKind implements driver.Valuer, returning text for an int.
+type valuerKind int
+
+func (valuerKind) Value() (driver.Value, error) { return "text", nil }
+
+// valuerNil implements driver.Valuer, returning nil for its zero value.
+type valuerNil int8
+
+func (v valuerNil) Value() (driver.Value, error) {
+	if v == 0 {
+		return nil, nil
+	}
+	return int64(v), nil
+}
+
+// valuerPanic implements driver.Valuer, panicking for its zero value.
+type valuerPanic struct{ p *float64 }
+
+func (v valuerPanic) Value() (driver.Value, error) { return *v.p, nil }
+
+func TestValuerColumnType(t *testing.T) {
+	tests := []struct {
+		x    interface{}
+		want reflect.Type
+	}{
+		{valuerKind(0), stringType},
+		{valuerNil(0), int64Type},
+		{valuerPanic{}, stringType},
+		{sql.NullInt64{}, int64Type},
+	}
+	for _, format := range []string{formatArrow, formatParquet, formatAvro, formatSQLite, formatSQLDump} {
+		for _, tt := range tests {
+			if got := columnType(reflect.TypeOf(tt.x), format); got != tt.want {
+				t.Errorf("columnType(%T, %q) = %v, want %v", tt.x, format, got, tt.want)
+			}
+		}
+	}
+}
+
+type (
+	namedString string
+	namedInt8   int8
+	namedUint   uint
+	namedFloat  float32
+)
+
+func TestBuiltinValue(t *testing.T) {
+	tests := []struct {
+		x, want interface{}
+	}{
+		{nil, nil},
+		{namedString("a"), "a"},
+		{true, true},
+		{namedInt8(-8), int64(-8)},
+		{namedUint(8), uint64(8)},
+		{namedFloat(1.5), float32(1.5)},
+		{1.5, 1.5},
+		{json.RawMessage("{}"), []byte("{}")},
+		{time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		if got := builtinValue(tt.x); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("builtinValue(%T) = %T %v, want %T %v", tt.x, got, got, tt.want, tt.want)
+		}
+	}
+}
+
+func TestAppendArrowValue(t *testing.T) {
+	tests := []struct {
+		dt  arrow.DataType
+		val interface{}
+		ok  bool
+	}{
+		{arrow.PrimitiveTypes.Int64, int64(1), true},
+		{arrow.PrimitiveTypes.Int64, 1, true},
+		{arrow.PrimitiveTypes.Int64, "text", false},
+		{arrow.PrimitiveTypes.Int8, int64(1), false},
+		{arrow.PrimitiveTypes.Uint64, 1, false},
+		{arrow.PrimitiveTypes.Float64, true, false},
+		{arrow.FixedWidthTypes.Boolean, 1.5, false},
+		{arrow.BinaryTypes.String, 1, false},
+		{arrow.FixedWidthTypes.Timestamp_us, int64(1), false},
+		{arrow.PrimitiveTypes.Int64, time.Time{}, false},
+		{arrow.PrimitiveTypes.Int64, time.Second, true},
+		{arrow.FixedWidthTypes.Duration_ns, time.Second, true},
+	}
+	for _, tt := range tests {
+		ab := array.NewBuilder(memory.DefaultAllocator, tt.dt)
+		if err := appendArrowValue(ab, tt.val); (err == nil) != tt.ok {
+			t.Errorf("appendArrowValue(%s, %T) = %v", tt.dt, tt.val, err)
+		}
+		ab.Release()
+	}
+}
+
+type planTest struct {
+	Name  string `peanut:"name,format=2006"`
+	Count *int   `peanut:"count"`
+}
+
+func TestPlanFor(t *testing.T) {
+	rt := reflect.TypeOf(planTest{})
+	p := planFor(rt, formatCSV)
+	if p != planFor(rt, formatCSV) {
+		t.Error("expected plan to be cached")
+	}
+	if p == planFor(rt, formatJSONL) {
+		t.Error("expected separate plans for separate formats")
+	}
+	if len(p.fields) != 2 || p.fields[0].header != "name" || !p.fields[0].formatted || p.fields[0].layout != "2006" {
+		t.Errorf("unexpected plan fields: %+v", p.fields)
+	}
+	s, err := p.strings(&planTest{Name: "x"}, "NULL", nil)
+	if err != nil || len(s) != 2 || s[0] != "x" || s[1] != "NULL" {
+		t.Errorf("unexpected strings: %v %v", s, err)
+	}
+}
+
+func TestGeneratedEncoder(t *testing.T) {
+	tm := time.Date(2021, 4, 19, 17, 30, 15, 0, time.UTC)
+	n := 42
+	d := 90 * time.Second
+	records := []gentest.Record{
+		{},
+		{
+			String: "a", Bool: true, Float32: 1.234, Float64: -9.876,
+			Int: -1, Int8: -8, Int16: -16, Int32: -32, Int64: -64,
+			Uint: 1, Uint8: 8, Uint16: 16, Uint32: 32, Uint64: 64,
+			Level: 3, Time: tm, Date: tm, Duration: d,
+			PtrInt: &n, PtrTime: &tm, PtrDur: &d,
+			NullStr:  sql.NullString{String: "s", Valid: true},
+			NullInt:  sql.NullInt64{Int64: 7, Valid: true},
+			NullTime: sql.NullTime{Time: tm, Valid: true},
+			Home:     gentest.Address{Street: "1 Home St", City: "Hometown"},
+			Work:     &gentest.Address{Street: "2 Work St", City: "Worktown"},
+			Audit:    gentest.Audit{CreatedBy: "alice"},
+		},
+	}
+
+	for _, format := range []string{formatCSV, formatJSONL, formatSQLite} {
+		p := planFor(reflect.TypeOf(gentest.Record{}), format)
+		if p.err != nil || !p.encoder {
+			t.Fatalf("expected generated encoder to be used: %v", p.err)
+		}
+		// Compare against the reflective plan.
+		rp := *p
+		rp.encoder = false
+
+		for _, x := range records {
+			s, _ := p.strings(x, "NULL", nil)
+			rs, err := rp.strings(x, "NULL", nil)
+			if err != nil || !reflect.DeepEqual(s, rs) {
+				t.Errorf("%s: generated strings %q, reflective strings %q (%v)", format, s, rs, err)
+			}
+			v, _ := p.values(&x, nil)
+			rv, err := rp.values(&x, nil)
+			if err != nil || !reflect.DeepEqual(v, rv) {
+				t.Errorf("%s: generated values %v, reflective values %v (%v)", format, v, rv, err)
+			}
+		}
+	}
+}
+
+type staleTest struct {
+	Name string `peanut:"name"`
+	Age  int    `peanut:"age"`
+}
+
+func (staleTest) PeanutHeaders() []string                        { return []string{"name"} }
+func (staleTest) PeanutTags() []string                           { return []string{"name"} }
+func (staleTest) PeanutTypes() []reflect.Type                    { return []reflect.Type{reflect.TypeOf("")} }
+func (x staleTest) PeanutValues(dst []interface{}) []interface{} { return append(dst, x.Name) }
+func (x staleTest) PeanutStrings(dst []string, null string) []string {
+	return append(dst, x.Name)
+}
+
+func TestStaleEncoder(t *testing.T) {
+	p := planFor(reflect.TypeOf(staleTest{}), formatCSV)
+	if p.err == nil || p.encoder {
+		t.Error("expected an error for out of date Encoder methods")
+	}
+	w := &MockWriter{}
+	if err := w.Write(&staleTest{}); err == nil {
+		t.Error("expected an error writing a type with out of date Encoder methods")
+	}
+}
+
+func TestStaleEncoderTypedWriter(t *testing.T) {
+	if _, err := NewTypedWriter[staleTest](&MockWriter{}); err == nil {
+		t.Error("expected an error creating a TypedWriter for a type with out of date Encoder methods")
+	}
+}
+
+func TestRecordWriters(t *testing.T) {
+	writers := []Writer{
+		NewCSVWriter("", ""), NewTSVWriter("", ""), NewJSONLWriter("", ""),
+		NewJSONWriter("", ""), NewExcelWriter("", ""), NewSQLiteWriter(""),
+		NewParquetWriter("", ""), NewAvroWriter("", ""), NewArrowWriter("", ""),
+		NewXMLWriter("", ""), NewMarkdownWriter("", ""), NewHTMLWriter("", ""),
+		NewFixedWidthWriter("", ""), NewSQLDumpWriter("", "", DialectSQLite),
+		NewPGCopyWriter("", ""), NewMsgpackWriter("", ""), NewCBORWriter("", ""),
+		NewProtobufWriter("", ""), NewYAMLWriter("", ""), NewTOMLWriter("", ""),
+	}
+	for _, w := range writers {
+		// Writers should support TypedWriter writing records directly.
+		rw, ok := w.(recordWriter)
+		if !ok {
+			t.Errorf("%T does not implement recordWriter", w)
+			continue
+		}
+		if rw.outputFormat() == "" {
+			t.Errorf("%T has no output format", w)
+		}
+	}
+}
+
+// staleFormatTest has Encoder methods generated before the format
+// of its Date field was changed from 2006-01-02.
+type staleFormatTest struct {
+	Date time.Time `peanut:"date,format=02/01/2006"`
+}
+
+func (staleFormatTest) PeanutHeaders() []string     { return []string{"date"} }
+func (staleFormatTest) PeanutTags() []string        { return []string{"date,format=2006-01-02"} }
+func (staleFormatTest) PeanutTypes() []reflect.Type { return []reflect.Type{timeType} }
+func (x staleFormatTest) PeanutValues(dst []interface{}) []interface{} {
+	return append(dst, x.Date)
+}
+func (x staleFormatTest) PeanutStrings(dst []string, null string) []string {
+	return append(dst, x.Date.Format("2006-01-02"))
+}
+
+func TestStaleEncoderFormat(t *testing.T) {
+	p := planFor(reflect.TypeOf(staleFormatTest{}), formatCSV)
+	if p.err == nil || p.encoder {
+		t.Error("expected an error for Encoder methods generated with a different format")
+	}
+	w := &MockWriter{}
+	if err := w.Write(&staleFormatTest{}); err == nil {
+		t.Error("expected an error writing a type with out of date Encoder methods")
+	}
+}
+
+type jsonTest struct {
+	Text    string  `peanut:"text"`
+	Float   float64 `peanut:"float"`
+	Float32 float32 `peanut:"float32"`
+	Uint    uint64  `peanut:"uint"`
+}
+
+func TestJSONEncoder(t *testing.T) {
+	p := planFor(reflect.TypeOf(jsonTest{}), formatJSONL)
+	e := newJSONEncoder(p)
+	tests := []jsonTest{
+		{Text: "plain", Float: 1.5, Float32: 1.1, Uint: 1},
+		{Text: "<a href=\"x\">&amp;</a>", Float: 1e21, Float32: 1e-7, Uint: 1 << 63},
+		{Text: "tab\tnew\nline\r\\ \x00\x1f\b\f", Float: 1e-7, Float32: 3.4e38},
+		{Text: "separators \u2028 \u2029 \u2603", Float: -0.000001, Float32: 123456789},
+	}
+	for _, x := range tests {
+		values, err := p.values(&x, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := e.encode(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Output should match encoding/json, with fields in order.
+		var want bytes.Buffer
+		enc := json.NewEncoder(&want)
+		enc.SetEscapeHTML(false)
+		want.WriteByte('{')
+		for i, f := range p.fields {
+			if i > 0 {
+				want.WriteByte(',')
+			}
+			enc.Encode(f.header)
+			want.Truncate(want.Len() - 1)
+			want.WriteByte(':')
+			enc.Encode(values[i])
+			want.Truncate(want.Len() - 1)
+		}
+		want.WriteByte('}')
+		if string(b) != want.String() {
+			t.Errorf("got %s, want %s", b, want.String())
+		}
+	}
+
+	// Invalid UTF-8 is replaced (encoding/json's escaping
+	// of the replacement character varies between versions).
+	values, err := p.values(&jsonTest{Text: "bad \xff utf8"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := e.encode(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got jsonTest
+	if err := json.Unmarshal(b, &struct {
+		Text *string `json:"text"`
+	}{&got.Text}); err != nil || got.Text != "bad \ufffd utf8" {
+		t.Errorf("unexpected encoding of invalid UTF-8: %s %v", b, err)
+	}
+
+	values, err = p.values(&jsonTest{Float: math.Inf(1)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = e.encode(values)
+	if err == nil {
+		t.Error("expected error encoding infinity")
 	}
 }
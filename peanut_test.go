@@ -1,8 +1,23 @@
 package peanut_test
 
 import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"time"
+
 	"github.com/jimsmart/peanut"
+	"github.com/klauspost/compress/zstd"
 	. "github.com/onsi/gomega"
+	"github.com/ulikunitz/xz"
 )
 
 type Foo struct {
@@ -39,6 +54,126 @@ type Qux struct {
 	IntField    int
 }
 
+type Times struct {
+	ID       string        `peanut:"id,pk"`
+	Time     time.Time     `peanut:"time"`
+	Date     time.Time     `peanut:"date,format=2006-01-02"`
+	Duration time.Duration `peanut:"duration"`
+}
+
+type Nullable struct {
+	ID         string          `peanut:"id,pk"`
+	IntPtr     *int64          `peanut:"int_ptr"`
+	StringPtr  *string         `peanut:"string_ptr"`
+	NullString sql.NullString  `peanut:"null_string"`
+	NullInt64  sql.NullInt64   `peanut:"null_int64"`
+	NullTime   sql.NullTime    `peanut:"null_time"`
+	NullFloat  sql.NullFloat64 `peanut:"null_float"`
+}
+
+type Audit struct {
+	CreatedBy string `peanut:"created_by"`
+	UpdatedBy string `peanut:"updated_by"`
+}
+
+type Address struct {
+	Street string `peanut:"street"`
+	City   string `peanut:"city"`
+}
+
+type Nested struct {
+	ID   string   `peanut:"id,pk"`
+	Home Address  `peanut:"home,inline"`
+	Work *Address `peanut:",inline,prefix=work_"`
+	Audit
+}
+
+// Money implements peanut.Marshaler.
+type Money int64
+
+func (Money) PeanutType() reflect.Type { return reflect.TypeOf(float64(0)) }
+
+func (m Money) MarshalPeanut(format string) (interface{}, error) {
+	if m < 0 {
+		return nil, errors.New("negative amount")
+	}
+	return float64(m) / 100, nil
+}
+
+// Level implements fmt.Stringer.
+type Level int
+
+func (l Level) String() string { return [...]string{"info", "warning", "error"}[l] }
+
+// Point implements json.Marshaler, with a pointer receiver.
+type Point struct{ X, Y int }
+
+func (p *Point) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"x":%d,"y":%d}`, p.X, p.Y)), nil
+}
+
+// Code implements driver.Valuer.
+type Code struct{ s string }
+
+func (c Code) Value() (driver.Value, error) { return "CODE-" + c.s, nil }
+
+// Status implements driver.Valuer, returning text for an int.
+type Status int
+
+func (s Status) Value() (driver.Value, error) { return [...]string{"inactive", "active"}[s], nil }
+
+// Rank implements driver.Valuer, returning values of differing kinds.
+type Rank int
+
+func (r Rank) Value() (driver.Value, error) {
+	if r < 0 {
+		return "unranked", nil
+	}
+	return int64(r), nil
+}
+
+type Account struct {
+	ID     string `peanut:"id,pk"`
+	Status Status `peanut:"status"`
+	Rank   Rank   `peanut:"rank"`
+}
+
+type Custom struct {
+	ID     string `peanut:"id,pk"`
+	Amount Money  `peanut:"amount"`
+	Level  Level  `peanut:"level"`
+	IP     net.IP `peanut:"ip"`
+	Point  Point  `peanut:"point"`
+	Code   Code   `peanut:"code"`
+}
+
+// Header has the same name as tar.Header.
+type Header struct {
+	Name string `peanut:"name"`
+}
+
+type Renamed struct {
+	_  struct{} `peanut:"renamed_records"`
+	ID string   `peanut:"id"`
+}
+
+type SelfNamed struct {
+	ID string `peanut:"id"`
+}
+
+func (*SelfNamed) PeanutName() string { return "self_named_v2" }
+
+type Unnamed struct {
+	ID string `peanut:"id"`
+}
+
+func (Unnamed) PeanutName() string { return "" }
+
+type BadDuplicate struct {
+	Name string `peanut:"created_by"`
+	Audit
+}
+
 type BadUnsupported struct {
 	BytesField []byte `peanut:"bytes_field"`
 }
@@ -74,6 +209,70 @@ var testOutputBaz = []Baz{
 	},
 }
 
+var testOutputTimes = []*Times{
+	{
+		ID:       "t1",
+		Time:     time.Date(2021, 4, 19, 13, 45, 30, 0, time.UTC),
+		Date:     time.Date(2021, 4, 19, 0, 0, 0, 0, time.UTC),
+		Duration: 90 * time.Minute,
+	},
+}
+
+// testOutputEarlyTimes holds times before 1678, which are
+// out of range of int64 nanoseconds since the Unix epoch.
+var testOutputEarlyTimes = []*Times{
+	{ID: "t0"},
+	{
+		ID:   "t1",
+		Time: time.Date(1500, 6, 1, 12, 30, 0, 123456000, time.UTC),
+		Date: time.Date(1500, 6, 1, 0, 0, 0, 0, time.UTC),
+	},
+}
+
+func int64Ptr(i int64) *int64 { return &i }
+
+func stringPtr(s string) *string { return &s }
+
+var testOutputNullable = []*Nullable{
+	{
+		ID:         "n1",
+		IntPtr:     int64Ptr(1),
+		StringPtr:  stringPtr("test 1"),
+		NullString: sql.NullString{String: "test 1", Valid: true},
+		NullInt64:  sql.NullInt64{Int64: 1, Valid: true},
+		NullTime:   sql.NullTime{Time: time.Date(2021, 4, 19, 13, 45, 30, 0, time.UTC), Valid: true},
+		NullFloat:  sql.NullFloat64{Float64: 1.5, Valid: true},
+	},
+	{
+		ID: "n2",
+	},
+}
+
+var testOutputNested = []*Nested{
+	{
+		ID:    "n1",
+		Home:  Address{Street: "1 Home St", City: "Hometown"},
+		Work:  &Address{Street: "1 Work St", City: "Worktown"},
+		Audit: Audit{CreatedBy: "alice", UpdatedBy: "bob"},
+	},
+	{
+		ID:    "n2",
+		Home:  Address{Street: "2 Home St", City: "Hometown"},
+		Audit: Audit{CreatedBy: "carol", UpdatedBy: "dave"},
+	},
+}
+
+var testOutputCustom = []*Custom{
+	{
+		ID:     "c1",
+		Amount: 1234,
+		Level:  1,
+		IP:     net.IPv4(192, 168, 0, 1),
+		Point:  Point{X: 1, Y: 2},
+		Code:   Code{"a"},
+	},
+}
+
 var testOutputQux = []*Qux{
 	{IntField: 1, StringField: "test 1"},
 	{IntField: 2, StringField: "test 2"},
@@ -138,6 +337,46 @@ func testWritesAndCancel(w peanut.Writer) {
 	Expect(err).To(BeNil())
 }
 
+func testWritesTimesAndClose(w peanut.Writer) {
+	var err error
+	for i := range testOutputTimes {
+		err = w.Write(testOutputTimes[i])
+		Expect(err).To(BeNil())
+	}
+	err = w.Close()
+	Expect(err).To(BeNil())
+}
+
+func testWritesNullableAndClose(w peanut.Writer) {
+	var err error
+	for i := range testOutputNullable {
+		err = w.Write(testOutputNullable[i])
+		Expect(err).To(BeNil())
+	}
+	err = w.Close()
+	Expect(err).To(BeNil())
+}
+
+func testWritesNestedAndClose(w peanut.Writer) {
+	var err error
+	for i := range testOutputNested {
+		err = w.Write(testOutputNested[i])
+		Expect(err).To(BeNil())
+	}
+	err = w.Close()
+	Expect(err).To(BeNil())
+}
+
+func testWritesCustomAndClose(w peanut.Writer) {
+	var err error
+	for i := range testOutputCustom {
+		err = w.Write(testOutputCustom[i])
+		Expect(err).To(BeNil())
+	}
+	err = w.Close()
+	Expect(err).To(BeNil())
+}
+
 func testWriteBadType(w peanut.Writer) {
 
 	defer func() {
@@ -158,6 +397,20 @@ func testWriteBadType(w peanut.Writer) {
 	))
 }
 
+func testWritesValuersAndClose(w peanut.Writer) {
+	var err error
+	err = w.Write(&Account{ID: "a1", Status: 1, Rank: 2})
+	Expect(err).To(BeNil())
+
+	// Values of a kind not matching the column are an error.
+	err = w.Write(&Account{ID: "a2", Rank: -1})
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(ContainSubstring("Account.Rank"))
+
+	err = w.Close()
+	Expect(err).To(BeNil())
+}
+
 func testWriteAfterClose(w peanut.Writer) {
 	var err error
 	err = w.Close()
@@ -165,3 +418,121 @@ func testWriteAfterClose(w peanut.Writer) {
 	err = w.Write(testOutputFoo[0])
 	Expect(err).To(Equal(peanut.ErrClosedWriter))
 }
+
+// readAll reads all records of type T from r.
+func readAll[T any](r peanut.Reader) ([]*T, error) {
+	var out []*T
+	for {
+		x := new(T)
+		err := r.Read(x)
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+		out = append(out, x)
+	}
+}
+
+// testReadsAndClose reads back the records
+// written by testWritesAndCloseSequential.
+func testReadsAndClose(r peanut.Reader) {
+	foo, err := readAll[Foo](r)
+	Expect(err).To(BeNil())
+	Expect(foo).To(Equal(testOutputFoo))
+
+	bar, err := readAll[Bar](r)
+	Expect(err).To(BeNil())
+	Expect(bar).To(Equal(testOutputBar))
+
+	baz, err := readAll[Baz](r)
+	Expect(err).To(BeNil())
+	Expect(baz).To(HaveLen(len(testOutputBaz)))
+	for i := range baz {
+		Expect(*baz[i]).To(Equal(testOutputBaz[i]))
+	}
+
+	// Reading past the end should continue to return io.EOF.
+	err = r.Read(&Foo{})
+	Expect(err).To(Equal(io.EOF))
+
+	err = r.Close()
+	Expect(err).To(BeNil())
+	err = r.Read(&Foo{})
+	Expect(err).To(Equal(peanut.ErrClosedReader))
+
+	// Calling Close again should be a no-op.
+	err = r.Close()
+	Expect(err).To(BeNil())
+}
+
+// testReadsTimesNullableAndNested reads back the records written by
+// testWritesTimesAndClose, testWritesNullableAndClose
+// and testWritesNestedAndClose.
+func testReadsTimesNullableAndNested(r peanut.Reader) {
+	times, err := readAll[Times](r)
+	Expect(err).To(BeNil())
+	Expect(times).To(Equal(testOutputTimes))
+
+	nullable, err := readAll[Nullable](r)
+	Expect(err).To(BeNil())
+	Expect(nullable).To(Equal(testOutputNullable))
+
+	nested, err := readAll[Nested](r)
+	Expect(err).To(BeNil())
+	Expect(nested).To(Equal(testOutputNested))
+
+	err = r.Close()
+	Expect(err).To(BeNil())
+}
+
+func testReadBadType(r peanut.Reader) {
+	defer func() {
+		err := r.Close()
+		Expect(err).To(BeNil())
+	}()
+
+	err := r.Read(&BadUnsupported{})
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(SatisfyAll(
+		MatchRegexp(`slice`),
+		MatchRegexp("BytesField"),
+		MatchRegexp("BadUnsupported"),
+	))
+
+	// Types that marshal themselves can only be read if they unmarshal themselves.
+	err = r.Read(&Custom{})
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(MatchRegexp("Custom.Amount"))
+
+	err = r.Read(Foo{})
+	Expect(err).ToNot(BeNil())
+}
+
+// readCompressedFile returns the decompressed content of the named file,
+// which was compressed using c.
+func readCompressedFile(name string, c peanut.Compression) string {
+	b, err := ioutil.ReadFile(name)
+	Expect(err).To(BeNil())
+	var r io.Reader = bytes.NewReader(b)
+	switch c {
+	case peanut.Gzip:
+		r, err = gzip.NewReader(r)
+	case peanut.Zstd:
+		var zr *zstd.Decoder
+		zr, err = zstd.NewReader(r)
+		if err == nil {
+			defer zr.Close()
+			r = zr
+		}
+	case peanut.Bzip2:
+		r = bzip2.NewReader(r)
+	case peanut.XZ:
+		r, err = xz.NewReader(r)
+	}
+	Expect(err).To(BeNil())
+	b, err = ioutil.ReadAll(r)
+	Expect(err).To(BeNil())
+	return string(b)
+}
@@ -0,0 +1,369 @@
+package peanut
+
+// PGCopyWriter
+// - See https://www.postgresql.org/docs/current/sql-copy.html#id-1.9.3.55.9.2
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var _ Writer = &PGCopyWriter{}
+
+// PGCopyWriter writes records to files in the text format
+// of PostgreSQL's COPY command, writing each record type to
+// an individual file automatically, together with a file
+// of DDL to create a table for it.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".copy"
+//  prefix + NameFunc(type) + suffix + ".sql"
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Each record is written to a line of tab-delimited values,
+// with backslash escaping and null values written as \N.
+// Times are written with their zone offset, and durations
+// are written as nanoseconds.
+//
+// Each DDL file holds a CREATE TABLE statement, as written
+// by SQLDumpWriter for DialectPostgreSQL, followed by a
+// comment giving the psql command to load the data, such as:
+//  \copy "Shape" ("shape_id", "name", "num_sides") FROM 'my-Shape-data.copy'
+//
+// If Compression is set, the data files are compressed, and their
+// filenames are given its Extension, as in ".copy.gz". The DDL file
+// is not compressed, and its comment instead loads the data using
+// a program to decompress it, such as gzip -dc.
+//
+// The caller must call Close on successful completion
+// of all writing, to ensure buffers are flushed and
+// files are properly written to disk.
+//
+// In the event of an error or cancellation, the
+// caller must call Cancel before quiting, to ensure
+// closure and cleanup of any partially written files.
+type PGCopyWriter struct {
+	*base
+	NameFunc      NameFunc    // NameFunc names the tables and output files, TypeName is used if nil.
+	Compression   Compression // Compression compresses the output files, which are uncompressed by default.
+	prefix        string
+	suffix        string
+	builderByType map[reflect.Type]*pgcopyBuilder
+}
+
+// NewPGCopyWriter returns a new PGCopyWriter, using prefix
+// and suffix when building its output filenames.
+//
+// See PGCopyWriter (above) for output filename details.
+func NewPGCopyWriter(prefix, suffix string) *PGCopyWriter {
+	w := PGCopyWriter{
+		base:          &base{format: formatPGCopy},
+		prefix:        prefix,
+		suffix:        suffix,
+		builderByType: make(map[reflect.Type]*pgcopyBuilder),
+	}
+	return &w
+}
+
+type pgcopyBuilder struct {
+	filename    string
+	file        *os.File
+	zw          io.WriteCloser // zw compresses output written to file.
+	bw          *bufio.Writer
+	ddlFilename string
+	ddlFile     *os.File
+	line        []byte        // line is reused for each record written.
+	values      []interface{} // values is reused for each record written.
+}
+
+func (w *PGCopyWriter) register(x interface{}) (reflect.Type, error) {
+	// Register with base writer.
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t, nil
+	}
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, err
+	}
+	if len(w.base.tagsByType[t]) == 0 {
+		return t, nil
+	}
+
+	// log.Printf("Setting up COPY files for %s", t.Name())
+
+	name := w.prefix + w.nameByType[t] + w.suffix
+	file, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		return nil, err
+	}
+	ddlFile, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	zw, err := w.Compression.newWriter(file)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		ddlFile.Close()
+		os.Remove(ddlFile.Name())
+		return nil, err
+	}
+	c := &pgcopyBuilder{
+		filename:    name + ".copy" + w.Compression.Extension(),
+		file:        file,
+		zw:          zw,
+		bw:          bufio.NewWriter(zw),
+		ddlFilename: name + ".sql",
+		ddlFile:     ddlFile,
+	}
+	// Register the builder before writing the DDL,
+	// so that Cancel cleans up after any error.
+	w.builderByType[t] = c
+
+	p := w.planByType[t]
+	d := DialectPostgreSQL
+	cols := make([]string, len(p.fields))
+	for i, f := range p.fields {
+		cols[i] = d.quote(f.header)
+	}
+	ddl := createDDL(d, w.nameByType[t], p) + ";\n"
+	ddl += "-- \\copy " + d.quote(w.nameByType[t]) + " (" + strings.Join(cols, ", ") + ") FROM "
+	if w.Compression == NoCompression {
+		ddl += string(d.appendString(nil, c.filename)) + "\n"
+	} else {
+		// Compressed data is loaded through a decompressing program.
+		cmd := w.Compression.decompressCommand() + " " + shellQuote(c.filename)
+		ddl += "PROGRAM " + string(d.appendString(nil, cmd)) + "\n"
+	}
+	_, err = ddlFile.WriteString(ddl)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Write is called to persist records.
+// Each record is written to an individual line
+// in the corresponding output file, according to the
+// type of the given record.
+func (w *PGCopyWriter) Write(x interface{}) error {
+	if w.closed {
+		return ErrClosedWriter
+	}
+	t, err := w.register(x)
+	if err != nil {
+		return err
+	}
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *PGCopyWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	var err error
+	// log.Printf("WriteRecord for %s", t.Name())
+	c := w.builderByType[t]
+	c.values, err = p.values(x, c.values[:0])
+	if err != nil {
+		return err
+	}
+	c.line = c.line[:0]
+	for i, val := range c.values {
+		if i > 0 {
+			c.line = append(c.line, '\t')
+		}
+		c.line, err = appendCopyValue(c.line, val, p.fields[i])
+		if err != nil {
+			return p.errorf(p.fields[i], err)
+		}
+	}
+	c.line = append(c.line, '\n')
+	_, err = c.bw.Write(c.line)
+	return err
+}
+
+// appendCopyValue appends val to b in the text format of COPY.
+func appendCopyValue(b []byte, val interface{}, f *fieldPlan) ([]byte, error) {
+	switch v := builtinValue(val).(type) {
+	case nil:
+		return append(b, `\N`...), nil
+	case time.Time:
+		if f.formatted {
+			return appendCopyString(b, v.Format(f.layout))
+		}
+		return v.AppendFormat(b, "2006-01-02 15:04:05.999999-07:00"), nil
+	case time.Duration:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case []byte:
+		return appendCopyString(b, string(v))
+	case string:
+		return appendCopyString(b, v)
+	case bool:
+		if v {
+			return append(b, 't'), nil
+		}
+		return append(b, 'f'), nil
+	case int64:
+		return strconv.AppendInt(b, v, 10), nil
+	case uint64:
+		return strconv.AppendUint(b, v, 10), nil
+	case float32:
+		return appendCopyFloat(b, float64(v), 32), nil
+	case float64:
+		return appendCopyFloat(b, v, 64), nil
+	}
+	return appendCopyString(b, formatValue(val, f.layout))
+}
+
+// appendCopyFloat appends x, a float of the given bit size,
+// to b in the text format of COPY.
+func appendCopyFloat(b []byte, x float64, bits int) []byte {
+	switch {
+	case math.IsNaN(x):
+		return append(b, "NaN"...)
+	case math.IsInf(x, 1):
+		return append(b, "Infinity"...)
+	case math.IsInf(x, -1):
+		return append(b, "-Infinity"...)
+	}
+	return strconv.AppendFloat(b, x, 'g', -1, bits)
+}
+
+// appendCopyString appends s to b, escaped for the text format of COPY.
+func appendCopyString(b []byte, s string) ([]byte, error) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\\':
+			b = append(b, '\\', '\\')
+		case '\t':
+			b = append(b, '\\', 't')
+		case '\n':
+			b = append(b, '\\', 'n')
+		case '\r':
+			b = append(b, '\\', 'r')
+		case '\b':
+			b = append(b, '\\', 'b')
+		case '\f':
+			b = append(b, '\\', 'f')
+		case '\v':
+			b = append(b, '\\', 'v')
+		case 0:
+			// PostgreSQL text cannot hold NUL bytes.
+			return b, errors.New("invalid NUL byte in text")
+		default:
+			b = append(b, c)
+		}
+	}
+	return b, nil
+}
+
+// Close flushes all buffers and writers,
+// and closes the output files.
+//
+// Calling Close after a previous call to
+// Cancel is safe, and always results in a no-op.
+func (w *PGCopyWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, c := range w.builderByType {
+		var cerr error
+		var err error
+
+		err = c.bw.Flush()
+		if err != nil {
+			cerr = err
+		}
+
+		err = c.zw.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		for _, file := range []*os.File{c.file, c.ddlFile} {
+			// Chmod the file world-readable (ioutil.TempFile creates files with
+			// mode 0600) before renaming.
+			err = file.Chmod(0644)
+			if err != nil {
+				cerr = err
+			}
+
+			// fsync(2) after fchmod(2) orders writes as per
+			// https://lwn.net/Articles/270891/.
+			file.Sync()
+
+			err = file.Close()
+			if err != nil {
+				cerr = err
+			}
+		}
+
+		if cerr != nil {
+			rerr = cerr
+			continue
+		}
+
+		err = os.Rename(c.file.Name(), c.filename)
+		if err != nil {
+			rerr = err
+		}
+		err = os.Rename(c.ddlFile.Name(), c.ddlFilename)
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// Cancel should be called in the event of an error occurring,
+// to properly close and delete any partially written files.
+func (w *PGCopyWriter) Cancel() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, c := range w.builderByType {
+		// Release any resources held by the compressor.
+		c.zw.Close()
+
+		for _, file := range []*os.File{c.file, c.ddlFile} {
+			var err error
+
+			err = file.Close()
+			if err != nil {
+				rerr = err
+			}
+
+			err = os.Remove(file.Name())
+			if err != nil {
+				rerr = err
+			}
+		}
+	}
+	return rerr
+}
@@ -0,0 +1,156 @@
+package peanut_test
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+type Escaping struct {
+	Text  string  `peanut:"text"`
+	Float float64 `peanut:"float"`
+	Flag  bool    `peanut:"flag"`
+}
+
+var _ = Describe("PGCopyWriter", func() {
+
+	AfterEach(func() {
+		for _, name := range []string{"Foo", "Bar", "Baz", "Times", "Nullable", "Escaping"} {
+			os.Remove("./test/output-" + name + "-copy.copy")
+			os.Remove("./test/output-" + name + "-copy.sql")
+		}
+	})
+
+	It("should write the correct data and DDL when sequential structs are written", func() {
+		w := peanut.NewPGCopyWriter("./test/output-", "-copy")
+
+		testWritesAndCloseSequential(w)
+
+		output, err := ioutil.ReadFile("./test/output-Foo-copy.copy")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("" +
+			"test 1\t1\n" +
+			"test 2\t2\n" +
+			"test 3\t3\n"))
+
+		output, err = ioutil.ReadFile("./test/output-Foo-copy.sql")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("" +
+			"CREATE TABLE \"Foo\" (\n" +
+			"\t\"foo_string\" TEXT NOT NULL,\n" +
+			"\t\"foo_int\" BIGINT NOT NULL,\n" +
+			"PRIMARY KEY (\"foo_string\")\n" +
+			");\n" +
+			"-- \\copy \"Foo\" (\"foo_string\", \"foo_int\") FROM './test/output-Foo-copy.copy'\n"))
+
+		output, err = ioutil.ReadFile("./test/output-Baz-copy.copy")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("test 1\tt\t1.234\t9.876\t-12345\t-8\t-16\t-32\t-64\t12345\t8\t16\t32\t64\n"))
+
+		Expect("./test/output-Qux-copy.copy").ToNot(BeAnExistingFile())
+		Expect("./test/output-Qux-copy.sql").ToNot(BeAnExistingFile())
+	})
+
+	It("should compress the data files and load them using a program", func() {
+		w := peanut.NewPGCopyWriter("./test/output-", "-copy")
+		w.Compression = peanut.Gzip
+
+		testWritesAndCloseSequential(w)
+
+		for _, name := range []string{"Foo", "Bar", "Baz"} {
+			defer os.Remove("./test/output-" + name + "-copy.copy.gz")
+		}
+
+		Expect("./test/output-Foo-copy.copy").ToNot(BeAnExistingFile())
+		Expect(readCompressedFile("./test/output-Foo-copy.copy.gz", peanut.Gzip)).To(Equal("" +
+			"test 1\t1\n" +
+			"test 2\t2\n" +
+			"test 3\t3\n"))
+
+		output, err := ioutil.ReadFile("./test/output-Foo-copy.sql")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(HaveSuffix("" +
+			"-- \\copy \"Foo\" (\"foo_string\", \"foo_int\") FROM PROGRAM 'gzip -dc ./test/output-Foo-copy.copy.gz'\n"))
+	})
+
+	It("should write times, durations and nulls", func() {
+		w := peanut.NewPGCopyWriter("./test/output-", "-copy")
+
+		testWritesTimesAndClose(w)
+
+		output, err := ioutil.ReadFile("./test/output-Times-copy.copy")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("t1\t2021-04-19 13:45:30+00:00\t2021-04-19\t5400000000000\n"))
+
+		output, err = ioutil.ReadFile("./test/output-Times-copy.sql")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(ContainSubstring("\t\"time\" TIMESTAMP WITH TIME ZONE NOT NULL,\n"))
+		Expect(string(output)).To(ContainSubstring("\t\"date\" TEXT NOT NULL,\n"))
+
+		w = peanut.NewPGCopyWriter("./test/output-", "-copy")
+
+		testWritesNullableAndClose(w)
+
+		output, err = ioutil.ReadFile("./test/output-Nullable-copy.copy")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("" +
+			"n1\t1\ttest 1\ttest 1\t1\t2021-04-19 13:45:30+00:00\t1.5\n" +
+			"n2\t\\N\t\\N\t\\N\t\\N\t\\N\t\\N\n"))
+
+		output, err = ioutil.ReadFile("./test/output-Nullable-copy.sql")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(ContainSubstring("\t\"int_ptr\" BIGINT,\n"))
+	})
+
+	It("should escape special characters and write special float values", func() {
+		w := peanut.NewPGCopyWriter("./test/output-", "-copy")
+		Expect(w.Write(&Escaping{Text: "a\tb\nc\rd\\e \\N", Float: math.NaN()})).To(BeNil())
+		Expect(w.Write(&Escaping{Text: "", Float: math.Inf(-1), Flag: true})).To(BeNil())
+		Expect(w.Close()).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Escaping-copy.copy")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("" +
+			"a\\tb\\nc\\rd\\\\e \\\\N\tNaN\tf\n" +
+			"\t-Infinity\tt\n"))
+	})
+
+	It("should return an error when text contains a NUL byte", func() {
+		w := peanut.NewPGCopyWriter("./test/output-", "-nul")
+		err := w.Write(&Escaping{Text: "a\x00b"})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Escaping.Text"))
+		Expect(w.Cancel()).To(BeNil())
+	})
+
+	It("should not write anything when structs are written and cancel is called", func() {
+		w := peanut.NewPGCopyWriter("./test/output-", "-cancel")
+
+		testWritesAndCancel(w)
+
+		Expect("./test/output-Foo-cancel.copy").ToNot(BeAnExistingFile())
+		Expect("./test/output-Foo-cancel.sql").ToNot(BeAnExistingFile())
+		Expect("./test/output-Bar-cancel.copy").ToNot(BeAnExistingFile())
+		Expect("./test/output-Bar-cancel.sql").ToNot(BeAnExistingFile())
+	})
+
+	It("should return an error when Write is called after Close", func() {
+		w := peanut.NewPGCopyWriter("./test/output-", "-close-write")
+
+		testWriteAfterClose(w)
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			w := peanut.NewPGCopyWriter("./no-such-location/output-bogus-", "")
+
+			testWriteBadType(w)
+		})
+	})
+})
@@ -0,0 +1,326 @@
+package peanut
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Encoder is the interface implemented by record types having
+// generated, reflection-free encoding methods, as produced by
+// the peanutgen command (see github.com/jimsmart/peanut/cmd/peanutgen).
+// Writers use these methods in place of reflection, when available.
+//
+// Encoder methods must have value receivers, and must be regenerated
+// whenever the record type changes: writers return an error when
+// registering a type whose headers, tags or types do not match its fields.
+type Encoder interface {
+	// PeanutHeaders returns the column names of the tagged fields.
+	PeanutHeaders() []string
+	// PeanutTags returns the tags of the tagged fields, including
+	// their options, with any inline prefix applied to their names.
+	PeanutTags() []string
+	// PeanutTypes returns the types of the tagged fields,
+	// as pointer types for fields reached via a pointer.
+	PeanutTypes() []reflect.Type
+	// PeanutValues appends the values of the tagged fields to dst,
+	// using nil for null values, and returns the result.
+	PeanutValues(dst []interface{}) []interface{}
+	// PeanutStrings appends the text of the values of the tagged fields
+	// to dst, using null for null values, and returns the result.
+	PeanutStrings(dst []string, null string) []string
+}
+
+var encoderType = reflect.TypeOf((*Encoder)(nil)).Elem()
+
+// typePlan is a compiled plan for encoding the tagged fields of a struct type.
+// Plans are built once per type and format, when first registered by a writer,
+// and reused for every record written, so that no per-record tag parsing
+// or field discovery is needed.
+type typePlan struct {
+	name    string       // name is the name of the struct type, for use in error messages.
+	fields  []*fieldPlan // fields holds a plan for each tagged field, in struct order.
+	encoder bool         // encoder is true if the type implements Encoder.
+	err     error        // err is any problem found with the type's Encoder methods.
+}
+
+// fieldPlan is a compiled plan for encoding a single field.
+type fieldPlan struct {
+	name      string                                       // name is the field name, qualified by any enclosing field names.
+	index     []int                                        // index is the index sequence of the field, as used by FieldByIndex.
+	typ       reflect.Type                                 // typ is the field type.
+	tag       string                                       // tag is the field tag.
+	header    string                                       // header is the field's column name.
+	layout    string                                       // layout is the time layout used for text.
+	formatted bool                                         // formatted is true if the tag has a format option.
+	nullable  bool                                         // nullable is true if the field can hold null values.
+	value     func(v reflect.Value) (interface{}, error)   // value returns the value to be written, or nil if null.
+	text      func(v reflect.Value) (string, bool, error)  // text returns the text to be written, or false if null.
+	decode    func(v reflect.Value, val interface{}) error // decode sets the field from a value read, or is nil if unsupported.
+}
+
+type planKey struct {
+	t      reflect.Type
+	format string
+}
+
+// planCache holds plans shared by all writers, keyed by planKey.
+var planCache sync.Map
+
+// planFor returns the plan for encoding records of
+// struct type t, for writers of the given format,
+// compiling and caching it if needed.
+func planFor(t reflect.Type, format string) *typePlan {
+	k := planKey{t: t, format: format}
+	if p, ok := planCache.Load(k); ok {
+		return p.(*typePlan)
+	}
+	p, _ := planCache.LoadOrStore(k, compilePlan(t, format))
+	return p.(*typePlan)
+}
+
+// compilePlan builds a plan for encoding records of
+// struct type t, for writers of the given format.
+func compilePlan(t reflect.Type, format string) *typePlan {
+	p := &typePlan{name: t.Name()}
+	defer p.checkEncoder(t)
+	for _, f := range structFields(t) {
+		_, formatted := tagOptionValue(f.tag, "format")
+		layout := timeFormat(f.tag)
+		_, nullable := nullableElem(f.typ)
+		p.fields = append(p.fields, &fieldPlan{
+			name:      f.name,
+			index:     f.index,
+			typ:       f.typ,
+			tag:       f.tag,
+			header:    firstTagValue(f.tag),
+			layout:    layout,
+			formatted: formatted,
+			nullable:  nullable,
+			value:     valueEncoder(f.decl, format),
+			text:      textEncoder(f.decl, format, layout),
+			decode:    valueDecoder(f.decl, format, layout),
+		})
+	}
+	return p
+}
+
+// valueEncoder returns a func returning the value to be written
+// for fields of type t, by a writer of the given format.
+func valueEncoder(t reflect.Type, format string) func(v reflect.Value) (interface{}, error) {
+	if t.Kind() == reflect.Ptr {
+		enc := valueEncoder(t.Elem(), format)
+		return func(v reflect.Value) (interface{}, error) {
+			if v.IsNil() {
+				return nil, nil
+			}
+			return enc(v.Elem())
+		}
+	}
+	if _, ok := nullTypes[t]; ok {
+		return func(v reflect.Value) (interface{}, error) {
+			// All of the sql.Null* types hold their value in
+			// their first field, followed by a Valid field.
+			if !v.Field(1).Bool() {
+				return nil, nil
+			}
+			return v.Field(0).Interface(), nil
+		}
+	}
+	if it := marshalerFor(t, format); it != nil {
+		ct := columnType(t, format)
+		return func(v reflect.Value) (interface{}, error) {
+			x, err := marshalValue(v, it, format)
+			if err != nil || x == nil {
+				return x, err
+			}
+			if !matchesColumnType(x, ct) {
+				return nil, fmt.Errorf("marshaled value of type %T does not match column type %s", x, ct)
+			}
+			return x, nil
+		}
+	}
+	return func(v reflect.Value) (interface{}, error) {
+		return v.Interface(), nil
+	}
+}
+
+// textEncoder returns a func returning the text to be written
+// for fields of type t, by a writer of the given format,
+// using layout to format any time.Time values.
+func textEncoder(t reflect.Type, format, layout string) func(v reflect.Value) (string, bool, error) {
+	if t.Kind() == reflect.Ptr {
+		enc := textEncoder(t.Elem(), format, layout)
+		return func(v reflect.Value) (string, bool, error) {
+			if v.IsNil() {
+				return "", false, nil
+			}
+			return enc(v.Elem())
+		}
+	}
+	if e, ok := nullTypes[t]; ok {
+		enc := textEncoder(e, format, layout)
+		return func(v reflect.Value) (string, bool, error) {
+			if !v.Field(1).Bool() {
+				return "", false, nil
+			}
+			return enc(v.Field(0))
+		}
+	}
+	if it := marshalerFor(t, format); it != nil {
+		return func(v reflect.Value) (string, bool, error) {
+			x, err := marshalValue(v, it, format)
+			if err != nil || x == nil {
+				return "", false, err
+			}
+			return formatValue(x, layout), true, nil
+		}
+	}
+
+	switch t {
+	case timeType:
+		return func(v reflect.Value) (string, bool, error) {
+			return v.Interface().(time.Time).Format(layout), true, nil
+		}
+	case durationType:
+		return func(v reflect.Value) (string, bool, error) {
+			return time.Duration(v.Int()).String(), true, nil
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return func(v reflect.Value) (string, bool, error) {
+			return v.String(), true, nil
+		}
+	case reflect.Bool:
+		return func(v reflect.Value) (string, bool, error) {
+			return strconv.FormatBool(v.Bool()), true, nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value) (string, bool, error) {
+			return strconv.FormatInt(v.Int(), 10), true, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value) (string, bool, error) {
+			return strconv.FormatUint(v.Uint(), 10), true, nil
+		}
+	case reflect.Float32:
+		return func(v reflect.Value) (string, bool, error) {
+			return strconv.FormatFloat(v.Float(), 'g', -1, 32), true, nil
+		}
+	case reflect.Float64:
+		return func(v reflect.Value) (string, bool, error) {
+			return strconv.FormatFloat(v.Float(), 'g', -1, 64), true, nil
+		}
+	}
+	return func(v reflect.Value) (string, bool, error) {
+		return formatValue(v.Interface(), layout), true, nil
+	}
+}
+
+// record returns the struct value of the record x,
+// which is either a struct or a pointer to one.
+func record(x interface{}) reflect.Value {
+	v := reflect.ValueOf(x)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	return v
+}
+
+// field returns the value of field f within the record value v,
+// or false if the field is reached via a nil pointer.
+func (f *fieldPlan) field(v reflect.Value) (reflect.Value, bool) {
+	if len(f.index) == 1 {
+		return v.Field(f.index[0]), true
+	}
+	return fieldByIndex(v, f.index)
+}
+
+// set sets field f within the record value v from val, a value
+// read by a reader, or to its zero value if null is true.
+func (f *fieldPlan) set(v reflect.Value, val interface{}, null bool) error {
+	if null {
+		if fv, ok := fieldByIndex(v, f.index); ok {
+			fv.Set(reflect.Zero(fv.Type()))
+		}
+		return nil
+	}
+	return f.decode(fieldByIndexAlloc(v, f.index), val)
+}
+
+// errorf returns an error for a failure to encode field f.
+func (p *typePlan) errorf(f *fieldPlan, err error) error {
+	return fmt.Errorf("peanut: error marshaling %s.%s: %w", p.name, f.name, err)
+}
+
+// checkEncoder determines whether records of type t can be
+// encoded using their Encoder methods, and if so, checks that the
+// headers, tags and types they report match those of the plan.
+// Tags are compared so that changed options, such as a time
+// format, are detected.
+func (p *typePlan) checkEncoder(t reflect.Type) {
+	if !t.Implements(encoderType) {
+		return
+	}
+	e := reflect.Zero(t).Interface().(Encoder)
+	headers, tags, types := e.PeanutHeaders(), e.PeanutTags(), e.PeanutTypes()
+	ok := len(headers) == len(p.fields) && len(tags) == len(p.fields) && len(types) == len(p.fields)
+	for i := 0; ok && i < len(p.fields); i++ {
+		f := p.fields[i]
+		ok = headers[i] == f.header && tags[i] == f.tag && types[i] == f.typ
+	}
+	if !ok {
+		p.err = fmt.Errorf("peanut: generated Encoder methods for %s are out of date", p.name)
+		return
+	}
+	p.encoder = true
+}
+
+// values appends the values of the tagged fields of x to dst,
+// using nil to represent null values, and returns the result.
+func (p *typePlan) values(x interface{}, dst []interface{}) ([]interface{}, error) {
+	if p.encoder {
+		return x.(Encoder).PeanutValues(dst), nil
+	}
+	v := record(x)
+	for _, f := range p.fields {
+		var val interface{}
+		if fv, ok := f.field(v); ok {
+			var err error
+			val, err = f.value(fv)
+			if err != nil {
+				return dst, p.errorf(f, err)
+			}
+		}
+		dst = append(dst, val)
+	}
+	return dst, nil
+}
+
+// strings appends the text of the values of the tagged fields of x to dst,
+// using null to represent null values, and returns the result.
+func (p *typePlan) strings(x interface{}, null string, dst []string) ([]string, error) {
+	if p.encoder {
+		return x.(Encoder).PeanutStrings(dst, null), nil
+	}
+	v := record(x)
+	for _, f := range p.fields {
+		s, ok := null, false
+		if fv, found := f.field(v); found {
+			var err error
+			s, ok, err = f.text(fv)
+			if err != nil {
+				return dst, p.errorf(f, err)
+			}
+			if !ok {
+				s = null
+			}
+		}
+		dst = append(dst, s)
+	}
+	return dst, nil
+}
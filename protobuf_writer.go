@@ -0,0 +1,476 @@
+package peanut
+
+// ProtobufWriter
+// - See https://protobuf.dev/programming-guides/encoding/
+// - See https://protobuf.dev/programming-guides/techniques/#streaming
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var _ Writer = &ProtobufWriter{}
+
+// kindToProtoType maps the supported kinds to protobuf scalar types.
+var kindToProtoType = map[reflect.Kind]string{
+	reflect.String:  "string",
+	reflect.Bool:    "bool",
+	reflect.Float64: "double",
+	reflect.Float32: "float",
+	reflect.Int8:    "int32",
+	reflect.Int16:   "int32",
+	reflect.Int32:   "int32",
+	reflect.Int64:   "int64",
+	reflect.Int:     "int64",
+	reflect.Uint8:   "uint32",
+	reflect.Uint16:  "uint32",
+	reflect.Uint32:  "uint32",
+	reflect.Uint64:  "uint64",
+	reflect.Uint:    "uint64",
+}
+
+// Protobuf well-known types, used for times and durations.
+const (
+	protoTimestamp = "google.protobuf.Timestamp"
+	protoDuration  = "google.protobuf.Duration"
+)
+
+// ProtobufWriter writes records to files of length-delimited
+// Protocol Buffers messages, writing each record type to an
+// individual file automatically, together with a .proto file
+// holding the message definition.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".pb"
+//  prefix + NameFunc(type) + suffix + ".proto"
+//
+// If Compression is set, the message files are compressed
+// accordingly, and named with its Extension, as in ".pb.gz".
+// The .proto files are not compressed.
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Each .proto file defines a single proto3 message, named using
+// NameFunc, having a field for each column, numbered in order
+// from 1. Times are written as google.protobuf.Timestamp, unless
+// they have a format option, and durations are written as
+// google.protobuf.Duration. Nullable fields are declared optional,
+// and null values are omitted. Type and column names must be valid
+// protobuf identifiers.
+//
+// Each record is written as a message, preceded by its length
+// as a varint, as read by Java's parseDelimitedFrom, or Go's
+// protodelim package.
+//
+// The caller must call Close on successful completion
+// of all writing, to ensure buffers are flushed and
+// files are properly written to disk.
+//
+// In the event of an error or cancellation, the
+// caller must call Cancel before quiting, to ensure
+// closure and cleanup of any partially written files.
+type ProtobufWriter struct {
+	*base
+	NameFunc      NameFunc    // NameFunc names the messages and output files, TypeName is used if nil.
+	Compression   Compression // Compression compresses the output files, which are uncompressed by default.
+	Package       string      // Package is declared by each .proto file, if not empty.
+	prefix        string
+	suffix        string
+	builderByType map[reflect.Type]*protobufBuilder
+}
+
+// NewProtobufWriter returns a new ProtobufWriter, using prefix
+// and suffix when building its output filenames.
+//
+// See ProtobufWriter (above) for output filename details.
+func NewProtobufWriter(prefix, suffix string) *ProtobufWriter {
+	w := ProtobufWriter{
+		base:          &base{format: formatProtobuf},
+		prefix:        prefix,
+		suffix:        suffix,
+		builderByType: make(map[reflect.Type]*protobufBuilder),
+	}
+	return &w
+}
+
+type protobufBuilder struct {
+	filename      string
+	file          *os.File
+	zw            io.WriteCloser // zw compresses output written to file.
+	bw            *bufio.Writer
+	protoFilename string
+	protoFile     *os.File
+	optional      []bool        // optional is true for fields declared optional.
+	head          []byte        // head is reused for the length of each record written.
+	msg           []byte        // msg is reused for each record written.
+	values        []interface{} // values is reused for each record written.
+}
+
+func (w *ProtobufWriter) register(x interface{}) (reflect.Type, error) {
+	// Register with base writer.
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t, nil
+	}
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, err
+	}
+	if len(w.base.tagsByType[t]) == 0 {
+		return t, nil
+	}
+
+	p := w.planByType[t]
+	names := []string{w.nameByType[t]}
+	for _, f := range p.fields {
+		names = append(names, f.header)
+	}
+	for _, name := range names {
+		if !isProtoIdent(name) {
+			return nil, fmt.Errorf("peanut: invalid protobuf name %q for %s", name, t.Name())
+		}
+	}
+
+	// log.Printf("Setting up protobuf writer for %s", t.Name())
+
+	name := w.prefix + w.nameByType[t] + w.suffix
+	file, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		return nil, err
+	}
+	protoFile, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	zw, err := w.Compression.newWriter(file)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		protoFile.Close()
+		os.Remove(protoFile.Name())
+		return nil, err
+	}
+	b := &protobufBuilder{
+		filename:      name + ".pb" + w.Compression.Extension(),
+		file:          file,
+		zw:            zw,
+		bw:            bufio.NewWriter(zw),
+		protoFilename: name + ".proto",
+		protoFile:     protoFile,
+		optional:      make([]bool, len(p.fields)),
+	}
+	// Register the builder before writing the schema,
+	// so that Cancel cleans up after any error.
+	w.builderByType[t] = b
+
+	for i, f := range p.fields {
+		b.optional[i] = protoOptional(f)
+	}
+	_, err = protoFile.WriteString(w.protoSchema(w.nameByType[t], p))
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// isProtoIdent reports whether s is a valid protobuf identifier.
+func isProtoIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '_' {
+			continue
+		}
+		if i > 0 && '0' <= r && r <= '9' {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// protoType returns the protobuf type of the values written for field f.
+func protoType(f *fieldPlan) string {
+	t := columnType(f.typ, formatProtobuf)
+	switch {
+	case t == timeType && f.formatted:
+		return "string"
+	case t == timeType:
+		return protoTimestamp
+	case t == durationType:
+		return protoDuration
+	}
+	// We ensure kindToProtoType has necessary entries using a test,
+	// so no need to check for missing entries here.
+	return kindToProtoType[t.Kind()]
+}
+
+// protoOptional reports whether field f is declared optional,
+// having explicit presence. Message types always have presence.
+func protoOptional(f *fieldPlan) bool {
+	typ := protoType(f)
+	return f.nullable && typ != protoTimestamp && typ != protoDuration
+}
+
+// protoSchema returns the .proto file defining
+// the named message, for records of plan p.
+func (w *ProtobufWriter) protoSchema(name string, p *typePlan) string {
+	var lines []string
+	imports := make(map[string]bool)
+	for i, f := range p.fields {
+		typ := protoType(f)
+		switch typ {
+		case protoTimestamp:
+			imports["google/protobuf/timestamp.proto"] = true
+		case protoDuration:
+			imports["google/protobuf/duration.proto"] = true
+		}
+		if protoOptional(f) {
+			typ = "optional " + typ
+		}
+		lines = append(lines, fmt.Sprintf("  %s %s = %d;", typ, f.header, i+1))
+	}
+
+	s := "syntax = \"proto3\";\n"
+	if w.Package != "" {
+		s += "\npackage " + w.Package + ";\n"
+	}
+	if len(imports) > 0 {
+		s += "\n"
+		// Imports in order.
+		for _, imp := range []string{"google/protobuf/duration.proto", "google/protobuf/timestamp.proto"} {
+			if imports[imp] {
+				s += "import \"" + imp + "\";\n"
+			}
+		}
+	}
+	s += "\nmessage " + name + " {\n"
+	s += strings.Join(lines, "\n")
+	s += "\n}\n"
+	return s
+}
+
+// Write is called to persist records.
+// Each record is written as an individual message
+// in the corresponding output file, according to the
+// type of the given record.
+func (w *ProtobufWriter) Write(x interface{}) error {
+	if w.closed {
+		return ErrClosedWriter
+	}
+	t, err := w.register(x)
+	if err != nil {
+		return err
+	}
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *ProtobufWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	var err error
+	// log.Printf("WriteRecord for %s", t.Name())
+	b := w.builderByType[t]
+	b.values, err = p.values(x, b.values[:0])
+	if err != nil {
+		return err
+	}
+	b.msg = b.msg[:0]
+	for i, val := range b.values {
+		b.msg = appendProtoField(b.msg, protowire.Number(i+1), val, p.fields[i], b.optional[i])
+	}
+	b.head = protowire.AppendVarint(b.head[:0], uint64(len(b.msg)))
+	b.bw.Write(b.head)
+	_, err = b.bw.Write(b.msg)
+	return err
+}
+
+// appendProtoField appends val to b as field num of a message.
+// Null values are omitted, as are zero values unless optional is set,
+// as with proto3 serializers.
+func appendProtoField(b []byte, num protowire.Number, val interface{}, f *fieldPlan, optional bool) []byte {
+	switch v := builtinValue(val).(type) {
+	case nil:
+		return b
+	case time.Time:
+		if f.formatted {
+			return appendProtoString(b, num, v.Format(f.layout), optional)
+		}
+		return appendProtoSeconds(b, num, v.Unix(), int32(v.Nanosecond()))
+	case time.Duration:
+		return appendProtoSeconds(b, num, int64(v/time.Second), int32(v%time.Second))
+	case []byte:
+		return appendProtoString(b, num, string(v), optional)
+	case string:
+		return appendProtoString(b, num, v, optional)
+	case bool:
+		if !v && !optional {
+			return b
+		}
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(v))
+	case int64:
+		if v == 0 && !optional {
+			return b
+		}
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(v))
+	case uint64:
+		if v == 0 && !optional {
+			return b
+		}
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, v)
+	case float32:
+		bits := math.Float32bits(v)
+		if bits == 0 && !optional {
+			return b
+		}
+		b = protowire.AppendTag(b, num, protowire.Fixed32Type)
+		return protowire.AppendFixed32(b, bits)
+	case float64:
+		bits := math.Float64bits(v)
+		if bits == 0 && !optional {
+			return b
+		}
+		b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, bits)
+	}
+	return appendProtoString(b, num, formatValue(val, f.layout), optional)
+}
+
+// appendProtoString appends s to b as field num of a message.
+func appendProtoString(b []byte, num protowire.Number, s string, optional bool) []byte {
+	if s == "" && !optional {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendProtoSeconds appends a google.protobuf.Timestamp or
+// google.protobuf.Duration message to b, as field num of a message.
+// Both messages have the same fields.
+func appendProtoSeconds(b []byte, num protowire.Number, seconds int64, nanos int32) []byte {
+	var m []byte
+	if seconds != 0 {
+		m = protowire.AppendTag(m, 1, protowire.VarintType)
+		m = protowire.AppendVarint(m, uint64(seconds))
+	}
+	if nanos != 0 {
+		m = protowire.AppendTag(m, 2, protowire.VarintType)
+		m = protowire.AppendVarint(m, uint64(nanos))
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+// Close flushes all buffers and writers,
+// and closes the output files.
+//
+// Calling Close after a previous call to
+// Cancel is safe, and always results in a no-op.
+func (w *ProtobufWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, b := range w.builderByType {
+		var cerr error
+		var err error
+
+		err = b.bw.Flush()
+		if err != nil {
+			cerr = err
+		}
+
+		err = b.zw.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		for _, file := range []*os.File{b.file, b.protoFile} {
+			// Chmod the file world-readable (ioutil.TempFile creates files with
+			// mode 0600) before renaming.
+			err = file.Chmod(0644)
+			if err != nil {
+				cerr = err
+			}
+
+			// fsync(2) after fchmod(2) orders writes as per
+			// https://lwn.net/Articles/270891/.
+			file.Sync()
+
+			err = file.Close()
+			if err != nil {
+				cerr = err
+			}
+		}
+
+		if cerr != nil {
+			rerr = cerr
+			continue
+		}
+
+		err = os.Rename(b.file.Name(), b.filename)
+		if err != nil {
+			rerr = err
+		}
+		err = os.Rename(b.protoFile.Name(), b.protoFilename)
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// Cancel should be called in the event of an error occurring,
+// to properly close and delete any partially written files.
+func (w *ProtobufWriter) Cancel() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, b := range w.builderByType {
+		// Release any resources held by the compressor.
+		b.zw.Close()
+
+		for _, file := range []*os.File{b.file, b.protoFile} {
+			var err error
+
+			err = file.Close()
+			if err != nil {
+				rerr = err
+			}
+
+			err = os.Remove(file.Name())
+			if err != nil {
+				rerr = err
+			}
+		}
+	}
+	return rerr
+}
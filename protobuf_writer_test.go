@@ -0,0 +1,306 @@
+package peanut_test
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/jimsmart/peanut"
+)
+
+type BadProtoName struct {
+	Name string `peanut:"first-name"`
+}
+
+var _ = Describe("ProtobufWriter", func() {
+
+	AfterEach(func() {
+		for _, name := range []string{"Foo", "Bar", "Baz", "Times", "Nullable"} {
+			os.Remove("./test/output-" + name + "-proto.pb")
+			os.Remove("./test/output-" + name + "-proto.proto")
+		}
+	})
+
+	It("should write a schema and length-delimited messages when sequential structs are written", func() {
+		w := peanut.NewProtobufWriter("./test/output-", "-proto")
+		w.Package = "peanut.test"
+
+		testWritesAndCloseSequential(w)
+
+		schema, err := ioutil.ReadFile("./test/output-Foo-proto.proto")
+		Expect(err).To(BeNil())
+		Expect(string(schema)).To(Equal("" +
+			"syntax = \"proto3\";\n" +
+			"\n" +
+			"package peanut.test;\n" +
+			"\n" +
+			"message Foo {\n" +
+			"  string foo_string = 1;\n" +
+			"  int64 foo_int = 2;\n" +
+			"}\n"))
+
+		md := protoMessageDescriptor("Foo",
+			protoField("foo_string", descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+			protoField("foo_int", descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+		)
+		msgs, err := readProtobuf("./test/output-Foo-proto.pb", md)
+		Expect(err).To(BeNil())
+		Expect(msgs).To(HaveLen(3))
+		for i, m := range msgs {
+			Expect(m.Get(md.Fields().ByName("foo_string")).String()).To(Equal(testOutputFoo[i].StringField))
+			Expect(m.Get(md.Fields().ByName("foo_int")).Int()).To(Equal(int64(testOutputFoo[i].IntField)))
+		}
+
+		schema, err = ioutil.ReadFile("./test/output-Baz-proto.proto")
+		Expect(err).To(BeNil())
+		Expect(string(schema)).To(ContainSubstring("" +
+			"message Baz {\n" +
+			"  string baz_string = 1;\n" +
+			"  bool baz_bool = 2;\n" +
+			"  double baz_float32 = 3;\n" +
+			"  double baz_float64 = 4;\n" +
+			"  int64 baz_int = 5;\n" +
+			"  int32 baz_int8 = 6;\n" +
+			"  int32 baz_int16 = 7;\n" +
+			"  int32 baz_int32 = 8;\n" +
+			"  int64 baz_int64 = 9;\n" +
+			"  uint64 baz_uint = 10;\n" +
+			"  uint32 baz_uint8 = 11;\n" +
+			"  uint32 baz_uint16 = 12;\n" +
+			"  uint32 baz_uint32 = 13;\n" +
+			"  uint64 baz_uint64 = 14;\n" +
+			"}\n"))
+
+		md = protoMessageDescriptor("Baz",
+			protoField("baz_string", descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+			protoField("baz_bool", descriptorpb.FieldDescriptorProto_TYPE_BOOL, ""),
+			protoField("baz_float32", descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, ""),
+			protoField("baz_float64", descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, ""),
+			protoField("baz_int", descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+			protoField("baz_int8", descriptorpb.FieldDescriptorProto_TYPE_INT32, ""),
+			protoField("baz_int16", descriptorpb.FieldDescriptorProto_TYPE_INT32, ""),
+			protoField("baz_int32", descriptorpb.FieldDescriptorProto_TYPE_INT32, ""),
+			protoField("baz_int64", descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+			protoField("baz_uint", descriptorpb.FieldDescriptorProto_TYPE_UINT64, ""),
+			protoField("baz_uint8", descriptorpb.FieldDescriptorProto_TYPE_UINT32, ""),
+			protoField("baz_uint16", descriptorpb.FieldDescriptorProto_TYPE_UINT32, ""),
+			protoField("baz_uint32", descriptorpb.FieldDescriptorProto_TYPE_UINT32, ""),
+			protoField("baz_uint64", descriptorpb.FieldDescriptorProto_TYPE_UINT64, ""),
+		)
+		msgs, err = readProtobuf("./test/output-Baz-proto.pb", md)
+		Expect(err).To(BeNil())
+		Expect(msgs).To(HaveLen(1))
+		var values []interface{}
+		for i := 0; i < md.Fields().Len(); i++ {
+			values = append(values, msgs[0].Get(md.Fields().Get(i)).Interface())
+		}
+		Expect(values).To(Equal([]interface{}{
+			"test 1", true, 1.234, 9.876, int64(-12345), int32(-8), int32(-16), int32(-32), int64(-64),
+			uint64(12345), uint32(8), uint32(16), uint32(32), uint64(64),
+		}))
+
+		Expect("./test/output-Qux-proto.pb").ToNot(BeAnExistingFile())
+		Expect("./test/output-Qux-proto.proto").ToNot(BeAnExistingFile())
+	})
+
+	It("should write times as timestamps and durations as durations", func() {
+		w := peanut.NewProtobufWriter("./test/output-", "-proto")
+
+		testWritesTimesAndClose(w)
+
+		schema, err := ioutil.ReadFile("./test/output-Times-proto.proto")
+		Expect(err).To(BeNil())
+		Expect(string(schema)).To(Equal("" +
+			"syntax = \"proto3\";\n" +
+			"\n" +
+			"import \"google/protobuf/duration.proto\";\n" +
+			"import \"google/protobuf/timestamp.proto\";\n" +
+			"\n" +
+			"message Times {\n" +
+			"  string id = 1;\n" +
+			"  google.protobuf.Timestamp time = 2;\n" +
+			"  string date = 3;\n" +
+			"  google.protobuf.Duration duration = 4;\n" +
+			"}\n"))
+
+		md := protoMessageDescriptor("Times",
+			protoField("id", descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+			protoField("time", descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Timestamp"),
+			protoField("date", descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+			protoField("duration", descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Duration"),
+		)
+		msgs, err := readProtobuf("./test/output-Times-proto.pb", md)
+		Expect(err).To(BeNil())
+		Expect(msgs).To(HaveLen(1))
+
+		m := msgs[0]
+		ts := &timestamppb.Timestamp{}
+		b, err := proto.Marshal(m.Get(md.Fields().ByName("time")).Message().Interface())
+		Expect(err).To(BeNil())
+		Expect(proto.Unmarshal(b, ts)).To(BeNil())
+		Expect(ts.AsTime()).To(Equal(testOutputTimes[0].Time))
+
+		d := &durationpb.Duration{}
+		b, err = proto.Marshal(m.Get(md.Fields().ByName("duration")).Message().Interface())
+		Expect(err).To(BeNil())
+		Expect(proto.Unmarshal(b, d)).To(BeNil())
+		Expect(d.AsDuration()).To(Equal(90 * time.Minute))
+
+		Expect(m.Get(md.Fields().ByName("date")).String()).To(Equal("2021-04-19"))
+	})
+
+	It("should declare nullable fields optional and omit null values", func() {
+		w := peanut.NewProtobufWriter("./test/output-", "-proto")
+
+		testWritesNullableAndClose(w)
+
+		schema, err := ioutil.ReadFile("./test/output-Nullable-proto.proto")
+		Expect(err).To(BeNil())
+		Expect(string(schema)).To(ContainSubstring("" +
+			"message Nullable {\n" +
+			"  string id = 1;\n" +
+			"  optional int64 int_ptr = 2;\n" +
+			"  optional string string_ptr = 3;\n" +
+			"  optional string null_string = 4;\n" +
+			"  optional int64 null_int64 = 5;\n" +
+			"  google.protobuf.Timestamp null_time = 6;\n" +
+			"  optional double null_float = 7;\n" +
+			"}\n"))
+
+		md := protoMessageDescriptor("Nullable",
+			protoField("id", descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+			protoOptionalField("int_ptr", descriptorpb.FieldDescriptorProto_TYPE_INT64),
+			protoOptionalField("string_ptr", descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			protoOptionalField("null_string", descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			protoOptionalField("null_int64", descriptorpb.FieldDescriptorProto_TYPE_INT64),
+			protoField("null_time", descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Timestamp"),
+			protoOptionalField("null_float", descriptorpb.FieldDescriptorProto_TYPE_DOUBLE),
+		)
+		msgs, err := readProtobuf("./test/output-Nullable-proto.pb", md)
+		Expect(err).To(BeNil())
+		Expect(msgs).To(HaveLen(2))
+		for i := 1; i < md.Fields().Len(); i++ {
+			Expect(msgs[0].Has(md.Fields().Get(i))).To(BeTrue())
+			Expect(msgs[1].Has(md.Fields().Get(i))).To(BeFalse())
+		}
+		Expect(msgs[0].Get(md.Fields().ByName("int_ptr")).Int()).To(Equal(int64(1)))
+		Expect(msgs[0].Get(md.Fields().ByName("null_float")).Float()).To(Equal(1.5))
+	})
+
+	It("should return an error when a name is not a valid protobuf identifier", func() {
+		w := peanut.NewProtobufWriter("./test/output-", "-badname")
+		err := w.Write(&BadProtoName{})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("invalid protobuf name \"first-name\""))
+		Expect(w.Cancel()).To(BeNil())
+	})
+
+	It("should not write anything when structs are written and cancel is called", func() {
+		w := peanut.NewProtobufWriter("./test/output-", "-cancel")
+
+		testWritesAndCancel(w)
+
+		Expect("./test/output-Foo-cancel.pb").ToNot(BeAnExistingFile())
+		Expect("./test/output-Foo-cancel.proto").ToNot(BeAnExistingFile())
+		Expect("./test/output-Bar-cancel.pb").ToNot(BeAnExistingFile())
+		Expect("./test/output-Bar-cancel.proto").ToNot(BeAnExistingFile())
+	})
+
+	It("should return an error when Write is called after Close", func() {
+		w := peanut.NewProtobufWriter("./test/output-", "-close-write")
+
+		testWriteAfterClose(w)
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			w := peanut.NewProtobufWriter("./no-such-location/output-bogus-", "")
+
+			testWriteBadType(w)
+		})
+	})
+})
+
+// protoField returns the descriptor of a field, numbered when
+// added to a message by protoMessageDescriptor.
+func protoField(name string, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:  proto.String(name),
+		Type:  typ.Enum(),
+		Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+// protoOptionalField returns the descriptor of a
+// proto3 optional field, having explicit presence.
+func protoOptionalField(name string, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+	f := protoField(name, typ, "")
+	f.Proto3Optional = proto.Bool(true)
+	return f
+}
+
+// protoMessageDescriptor returns the descriptor of a message
+// having the given fields, as defined by a ProtobufWriter schema.
+func protoMessageDescriptor(name string, fields ...*descriptorpb.FieldDescriptorProto) protoreflect.MessageDescriptor {
+	msg := &descriptorpb.DescriptorProto{Name: proto.String(name)}
+	for i, f := range fields {
+		f.Number = proto.Int32(int32(i + 1))
+		if f.GetProto3Optional() {
+			// Each optional field has a synthetic oneof.
+			f.OneofIndex = proto.Int32(int32(len(msg.OneofDecl)))
+			msg.OneofDecl = append(msg.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + f.GetName())})
+		}
+		msg.Field = append(msg.Field, f)
+	}
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String(name + ".proto"),
+		Syntax:      proto.String("proto3"),
+		Dependency:  []string{"google/protobuf/duration.proto", "google/protobuf/timestamp.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{msg},
+	}, protoregistry.GlobalFiles)
+	Expect(err).To(BeNil())
+	return fd.Messages().Get(0)
+}
+
+// readProtobuf reads the length-delimited messages of a file written by ProtobufWriter.
+func readProtobuf(filename string, md protoreflect.MessageDescriptor) ([]protoreflect.Message, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var msgs []protoreflect.Message
+	for {
+		m := dynamicpb.NewMessage(md)
+		err := protodelim.UnmarshalFrom(r, m)
+		if errors.Is(err, io.EOF) {
+			return msgs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+}
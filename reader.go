@@ -0,0 +1,110 @@
+package peanut
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Reader defines a record-based reader.
+//
+// Read reads the next record of the type pointed to by r into r,
+// returning io.EOF when no records of that type remain.
+// Records of different types may be read in any order.
+type Reader interface {
+	Read(r interface{}) error
+	Close() error
+}
+
+// ErrClosedReader is the error used for read operations on a closed reader.
+var ErrClosedReader = errors.New("peanut: read on closed reader")
+
+// Errors used by readers, wrapped in a ReadError, for columns
+// that are missing or unexpected, unless reading leniently.
+var (
+	ErrMissingColumn    = errors.New("missing column")
+	ErrUnexpectedColumn = errors.New("unexpected column")
+)
+
+// ErrNullValue is used by readers, wrapped in a ReadError,
+// for null values read for fields that are not nullable.
+var ErrNullValue = errors.New("null value for non-nullable field")
+
+// ReadError records an error reading a value, and its location.
+type ReadError struct {
+	File   string // File is the name of the file being read.
+	Row    int    // Row is the row number, counting from 1, including any header row.
+	Column string // Column is the column name, if known.
+	Err    error  // Err is the underlying error.
+}
+
+func (e *ReadError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("peanut: %s: row %d: %v", e.File, e.Row, e.Err)
+	}
+	return fmt.Sprintf("peanut: %s: row %d, column %s: %v", e.File, e.Row, e.Column, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// readTarget returns the struct value that the record x points to.
+func readTarget(x interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(x)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("peanut: Read requires a non-nil pointer to a struct, not %T", x)
+	}
+	return v.Elem(), nil
+}
+
+// registerRead registers the type of the record x with the reader's base,
+// having first checked that all of its fields can be read.
+func (r *base) registerRead(x interface{}, nameFn NameFunc) (reflect.Type, bool, error) {
+	t := baseType(x)
+	if _, ok := r.headersByType[t]; ok {
+		return t, false, nil
+	}
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, false, err
+	}
+	p := planFor(t, r.format)
+	for _, f := range p.fields {
+		if f.decode == nil {
+			return nil, false, fmt.Errorf("peanut: unsupported type for reading: %s in %s.%s", f.typ, p.name, f.name)
+		}
+	}
+	return r.register(x, nameFn)
+}
+
+// mapColumns returns the field of plan p for each of the given columns,
+// or nil for columns having no corresponding field. Unless lenient is true,
+// an error is returned for missing and unexpected columns.
+func mapColumns(p *typePlan, columns []string, lenient bool, file string) ([]*fieldPlan, error) {
+	byHeader := make(map[string]*fieldPlan, len(p.fields))
+	for _, f := range p.fields {
+		byHeader[f.header] = f
+	}
+	fields := make([]*fieldPlan, len(columns))
+	found := make(map[string]bool, len(columns))
+	for i, c := range columns {
+		f, ok := byHeader[c]
+		if !ok || found[c] {
+			if !lenient {
+				return nil, &ReadError{File: file, Row: 1, Column: c, Err: ErrUnexpectedColumn}
+			}
+			continue
+		}
+		found[c] = true
+		fields[i] = f
+	}
+	if !lenient {
+		for _, f := range p.fields {
+			if !found[f.header] {
+				return nil, &ReadError{File: file, Row: 1, Column: f.header, Err: ErrMissingColumn}
+			}
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,547 @@
+package peanut
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var _ Writer = &SQLDumpWriter{}
+
+// SQLDialect is a dialect of SQL written by SQLDumpWriter.
+type SQLDialect int
+
+// Dialects of SQL supported by SQLDumpWriter.
+const (
+	DialectSQLite SQLDialect = iota
+	DialectPostgreSQL
+	DialectMySQL
+)
+
+// DefaultSQLDumpBatchSize is the number of rows inserted by
+// each INSERT statement when SQLDumpWriter.BatchSize is zero.
+const DefaultSQLDumpBatchSize = 100
+
+// kindToPostgreSQLType maps the supported kinds to PostgreSQL datatypes.
+var kindToPostgreSQLType = map[reflect.Kind]string{
+	reflect.String:  "TEXT",
+	reflect.Bool:    "BOOLEAN",
+	reflect.Float64: "DOUBLE PRECISION",
+	reflect.Float32: "REAL",
+	reflect.Int8:    "SMALLINT",
+	reflect.Int16:   "SMALLINT",
+	reflect.Int32:   "INTEGER",
+	reflect.Int64:   "BIGINT",
+	reflect.Int:     "BIGINT",
+	reflect.Uint8:   "SMALLINT",
+	reflect.Uint16:  "INTEGER",
+	reflect.Uint32:  "BIGINT",
+	reflect.Uint64:  "NUMERIC(20)",
+	reflect.Uint:    "NUMERIC(20)",
+}
+
+// kindToMySQLType maps the supported kinds to MySQL datatypes.
+var kindToMySQLType = map[reflect.Kind]string{
+	reflect.String:  "TEXT",
+	reflect.Bool:    "BOOLEAN",
+	reflect.Float64: "DOUBLE",
+	reflect.Float32: "FLOAT",
+	reflect.Int8:    "TINYINT",
+	reflect.Int16:   "SMALLINT",
+	reflect.Int32:   "INT",
+	reflect.Int64:   "BIGINT",
+	reflect.Int:     "BIGINT",
+	reflect.Uint8:   "TINYINT UNSIGNED",
+	reflect.Uint16:  "SMALLINT UNSIGNED",
+	reflect.Uint32:  "INT UNSIGNED",
+	reflect.Uint64:  "BIGINT UNSIGNED",
+	reflect.Uint:    "BIGINT UNSIGNED",
+}
+
+// quote returns s quoted as an identifier.
+func (d SQLDialect) quote(s string) string {
+	if d == DialectMySQL {
+		return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// columnType returns the column datatype used for field f.
+func (d SQLDialect) columnType(f *fieldPlan) string {
+	if d == DialectSQLite {
+		return dbType(f.typ)
+	}
+	t := columnType(f.typ, formatSQLDump)
+	switch {
+	case t == timeType && f.formatted:
+		return "TEXT"
+	case t == timeType && d == DialectPostgreSQL:
+		return "TIMESTAMP WITH TIME ZONE"
+	case t == timeType:
+		return "DATETIME(6)"
+	case d == DialectMySQL && t.Kind() == reflect.String && hasTagOption(f.tag, "pk"):
+		// MySQL cannot index TEXT columns without a prefix length.
+		return "VARCHAR(255)"
+	case d == DialectPostgreSQL:
+		// We ensure the lookup tables have necessary entries using a test,
+		// so no need to check for missing entries here.
+		return kindToPostgreSQLType[t.Kind()]
+	}
+	return kindToMySQLType[t.Kind()]
+}
+
+// createDDL returns the CREATE TABLE statement for the named table,
+// holding records of plan p, in dialect d.
+func createDDL(d SQLDialect, name string, p *typePlan) string {
+
+	// Create table using type name - quoted.
+	ddl := "CREATE TABLE " + d.quote(name) + " (\n"
+
+	// List of DDL statements to build the table definition.
+	var ddlLines []string
+	// List of primary keys.
+	var pks []string
+
+	for _, f := range p.fields {
+		// Column name - quoted.
+		col := "\t" + d.quote(f.header) + " "
+
+		// Column datatype.
+		col += d.columnType(f)
+
+		// Column constraints.
+		if !f.nullable {
+			col += " NOT NULL"
+		}
+
+		// Add DDL line to list.
+		ddlLines = append(ddlLines, col)
+
+		// Handle primary key tag.
+		if hasTagOption(f.tag, "pk") {
+			// Add column name to primary key list.
+			pks = append(pks, d.quote(f.header))
+		}
+	}
+
+	// Primary key.
+	if len(pks) > 0 {
+		pk := "PRIMARY KEY ("
+		pk += strings.Join(pks, ", ")
+		pk += ")"
+		ddlLines = append(ddlLines, pk)
+	}
+
+	// Join the lines of DDL together.
+	ddl += strings.Join(ddlLines, ",\n")
+
+	ddl += "\n)"
+	return ddl
+}
+
+// appendLiteral appends val to b as an SQL literal, in dialect d.
+func (d SQLDialect) appendLiteral(b []byte, val interface{}, f *fieldPlan) ([]byte, error) {
+	switch v := builtinValue(val).(type) {
+	case nil:
+		return append(b, "NULL"...), nil
+	case time.Time:
+		switch {
+		case f.formatted:
+			return d.appendString(b, v.Format(f.layout)), nil
+		case d == DialectSQLite:
+			// As written by the SQLite driver.
+			return d.appendString(b, v.Format("2006-01-02 15:04:05.999999999-07:00")), nil
+		case d == DialectPostgreSQL:
+			return d.appendString(b, v.Format("2006-01-02 15:04:05.999999-07:00")), nil
+		}
+		return d.appendString(b, v.UTC().Format("2006-01-02 15:04:05.999999")), nil
+	case time.Duration:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case []byte:
+		return d.appendString(b, string(v)), nil
+	case string:
+		return d.appendString(b, v), nil
+	case bool:
+		if d == DialectSQLite {
+			if v {
+				return append(b, '1'), nil
+			}
+			return append(b, '0'), nil
+		}
+		if v {
+			return append(b, "TRUE"...), nil
+		}
+		return append(b, "FALSE"...), nil
+	case int64:
+		return strconv.AppendInt(b, v, 10), nil
+	case uint64:
+		return strconv.AppendUint(b, v, 10), nil
+	case float32:
+		return appendSQLFloat(b, float64(v), 32)
+	case float64:
+		return appendSQLFloat(b, v, 64)
+	}
+	return b, fmt.Errorf("unsupported type %T", val)
+}
+
+// appendSQLFloat appends x, a float of the given bit size,
+// to b as an SQL numeric literal.
+func appendSQLFloat(b []byte, x float64, bits int) ([]byte, error) {
+	if math.IsInf(x, 0) || math.IsNaN(x) {
+		return b, fmt.Errorf("unsupported value: %v", x)
+	}
+	return strconv.AppendFloat(b, x, 'g', -1, bits), nil
+}
+
+// appendString appends s to b as an SQL string literal, in dialect d.
+func (d SQLDialect) appendString(b []byte, s string) []byte {
+	b = append(b, '\'')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\'':
+			b = append(b, '\'', '\'')
+		case c == '\\' && d == DialectMySQL:
+			// MySQL treats backslashes as escapes by default.
+			b = append(b, '\\', '\\')
+		case c == 0 && d == DialectMySQL:
+			b = append(b, '\\', '0')
+		default:
+			b = append(b, c)
+		}
+	}
+	return append(b, '\'')
+}
+
+// SQLDumpWriter writes records to SQL scripts, as CREATE TABLE
+// statements followed by INSERT statements, writing each record
+// type to an individual table automatically.
+//
+// Scripts are written in the given SQLDialect, which is one of
+// DialectSQLite, DialectPostgreSQL or DialectMySQL.
+//
+// When created with NewSQLDumpWriter, each record type is
+// written to an individual file, with filenames derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".sql"
+//
+// When created with NewSQLDumpFileWriter, all record types
+// are written to a single file.
+//
+// If Compression is set, files are compressed accordingly,
+// and named with its Extension, as in ".sql.gz".
+//
+// Tables are named using NameFunc, which defaults to TypeName,
+// using the type's name, or a name given by a struct-level tag.
+//
+// Tables are created as by SQLiteWriter, including primary
+// keys given by the pk tag option, with column datatypes
+// appropriate to the dialect. Times are written as text, in
+// UTC for MySQL, and durations are written as nanoseconds.
+// Columns for nullable fields (pointers and sql.Null* types)
+// are created without a NOT NULL constraint, and nil or
+// invalid values are written as NULL.
+//
+// Each INSERT statement inserts up to BatchSize rows,
+// which defaults to DefaultSQLDumpBatchSize.
+//
+// The caller must call Close on successful completion
+// of all writing, to ensure buffers are flushed and
+// files are properly written to disk.
+//
+// In the event of an error or cancellation, the
+// caller must call Cancel before quiting, to ensure
+// closure and cleanup of any partially written files.
+type SQLDumpWriter struct {
+	*base
+	NameFunc      NameFunc    // NameFunc names the tables and output files, TypeName is used if nil.
+	Compression   Compression // Compression compresses the output files, which are uncompressed by default.
+	BatchSize     int         // BatchSize is the number of rows inserted by each statement.
+	dialect       SQLDialect
+	prefix        string
+	suffix        string
+	filename      string         // filename is the combined output filename, if any.
+	files         []*sqlDumpFile // files holds the output files, in order of creation.
+	types         []reflect.Type // types holds the types written, in order of registration.
+	builderByType map[reflect.Type]*sqlDumpBuilder
+}
+
+// NewSQLDumpWriter returns a new SQLDumpWriter, writing
+// the given dialect of SQL, and using prefix and suffix
+// when building its output filenames.
+//
+// See SQLDumpWriter (above) for output filename details.
+func NewSQLDumpWriter(prefix, suffix string, dialect SQLDialect) *SQLDumpWriter {
+	w := SQLDumpWriter{
+		base:          &base{format: formatSQLDump},
+		dialect:       dialect,
+		prefix:        prefix,
+		suffix:        suffix,
+		builderByType: make(map[reflect.Type]*sqlDumpBuilder),
+	}
+	return &w
+}
+
+// NewSQLDumpFileWriter returns a new SQLDumpWriter, writing
+// the given dialect of SQL, and using the given filename + ".sql"
+// as its single output file.
+func NewSQLDumpFileWriter(filename string, dialect SQLDialect) *SQLDumpWriter {
+	w := NewSQLDumpWriter("", "", dialect)
+	w.filename = filename + ".sql"
+	return w
+}
+
+type sqlDumpFile struct {
+	filename string
+	file     *os.File
+	zw       io.WriteCloser // zw compresses output written to file.
+	bw       *bufio.Writer
+}
+
+type sqlDumpBuilder struct {
+	out    *sqlDumpFile
+	insert string        // insert begins each INSERT statement.
+	rows   int           // rows is the number of rows buffered.
+	buf    []byte        // buf holds the buffered rows.
+	values []interface{} // values is reused for each record written.
+}
+
+func (w *SQLDumpWriter) register(x interface{}) (reflect.Type, error) {
+	// Register with base writer.
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t, nil
+	}
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, err
+	}
+	if len(w.base.tagsByType[t]) == 0 {
+		return t, nil
+	}
+
+	// log.Printf("Setting up SQL dump for %s", t.Name())
+
+	var out *sqlDumpFile
+	if w.filename != "" && len(w.files) > 0 {
+		out = w.files[0]
+	} else {
+		name := w.filename
+		if name == "" {
+			name = w.prefix + w.nameByType[t] + w.suffix + ".sql"
+		}
+		name += w.Compression.Extension()
+		file, err := ioutil.TempFile("", "atomic-")
+		if err != nil {
+			return nil, err
+		}
+		zw, err := w.Compression.newWriter(file)
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return nil, err
+		}
+		out = &sqlDumpFile{filename: name, file: file, zw: zw, bw: bufio.NewWriter(zw)}
+		w.files = append(w.files, out)
+	}
+
+	p := w.planByType[t]
+	d := w.dialect
+	name := d.quote(w.nameByType[t])
+	cols := make([]string, len(p.fields))
+	for i, f := range p.fields {
+		cols[i] = d.quote(f.header)
+	}
+	b := &sqlDumpBuilder{
+		out:    out,
+		insert: "INSERT INTO " + name + " (" + strings.Join(cols, ", ") + ") VALUES\n",
+	}
+	w.builderByType[t] = b
+	w.types = append(w.types, t)
+
+	if out.bw.Buffered() > 0 {
+		out.bw.WriteByte('\n')
+	}
+	out.bw.WriteString(createDDL(d, w.nameByType[t], p))
+	_, err = out.bw.WriteString(";\n")
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Write is called to persist records.
+// Each record is written to an individual row
+// of an INSERT statement in the corresponding
+// output file, according to the type of the given record.
+func (w *SQLDumpWriter) Write(x interface{}) error {
+	if w.closed {
+		return ErrClosedWriter
+	}
+	t, err := w.register(x)
+	if err != nil {
+		return err
+	}
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *SQLDumpWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	var err error
+	// log.Printf("WriteRecord for %s", t.Name())
+	b := w.builderByType[t]
+	b.values, err = p.values(x, b.values[:0])
+	if err != nil {
+		return err
+	}
+
+	// Build the row before buffering it,
+	// so that no partial row is written.
+	n := len(b.buf)
+	if b.rows > 0 {
+		b.buf = append(b.buf, ",\n"...)
+	}
+	b.buf = append(b.buf, '(')
+	for i, val := range b.values {
+		if i > 0 {
+			b.buf = append(b.buf, ", "...)
+		}
+		b.buf, err = w.dialect.appendLiteral(b.buf, val, p.fields[i])
+		if err != nil {
+			b.buf = b.buf[:n]
+			return p.errorf(p.fields[i], err)
+		}
+	}
+	b.buf = append(b.buf, ')')
+	b.rows++
+
+	size := w.BatchSize
+	if size <= 0 {
+		size = DefaultSQLDumpBatchSize
+	}
+	if b.rows < size {
+		return nil
+	}
+	return b.flush()
+}
+
+// flush writes any buffered rows as an INSERT statement.
+func (b *sqlDumpBuilder) flush() error {
+	if b.rows == 0 {
+		return nil
+	}
+	b.out.bw.WriteString(b.insert)
+	b.out.bw.Write(b.buf)
+	_, err := b.out.bw.WriteString(";\n")
+	b.rows = 0
+	b.buf = b.buf[:0]
+	return err
+}
+
+// Close flushes all buffers and writers,
+// and closes the output files.
+//
+// Calling Close after a previous call to
+// Cancel is safe, and always results in a no-op.
+func (w *SQLDumpWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, t := range w.types {
+		err := w.builderByType[t].flush()
+		if err != nil {
+			rerr = err
+		}
+	}
+	if rerr != nil {
+		w.closed = false
+		w.Cancel()
+		return rerr
+	}
+
+	for _, f := range w.files {
+		var cerr error
+		var err error
+
+		err = f.bw.Flush()
+		if err != nil {
+			cerr = err
+		}
+
+		err = f.zw.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		// Chmod the file world-readable (ioutil.TempFile creates files with
+		// mode 0600) before renaming.
+		err = f.file.Chmod(0644)
+		if err != nil {
+			cerr = err
+		}
+
+		// fsync(2) after fchmod(2) orders writes as per
+		// https://lwn.net/Articles/270891/.
+		f.file.Sync()
+
+		err = f.file.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		if cerr != nil {
+			rerr = cerr
+			continue
+		}
+
+		err = os.Rename(f.file.Name(), f.filename)
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// Cancel should be called in the event of an error occurring,
+// to properly close and delete any partially written files.
+func (w *SQLDumpWriter) Cancel() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, f := range w.files {
+		var err error
+
+		// Release any resources held by the compressor.
+		f.zw.Close()
+
+		err = f.file.Close()
+		if err != nil {
+			rerr = err
+		}
+
+		err = os.Remove(f.file.Name())
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
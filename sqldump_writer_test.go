@@ -0,0 +1,195 @@
+package peanut_test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+type Quoting struct {
+	ID   string  `peanut:"id,pk"`
+	Text string  `peanut:"te\"xt"`
+	Flag bool    `peanut:"flag"`
+	Note *string `peanut:"note"`
+}
+
+var _ = Describe("SQLDumpWriter", func() {
+
+	AfterEach(func() {
+		os.Remove("./test/output-Foo-pg.sql")
+		os.Remove("./test/output-Bar-pg.sql")
+		os.Remove("./test/output-Baz-pg.sql")
+		os.Remove("./test/output-Foo-batch.sql")
+		os.Remove("./test/output-Quoting-mysql.sql")
+		os.Remove("./test/output-Times-mysql.sql")
+		os.Remove("./test/output-dump.sql")
+		os.Remove("./test/output-dump.sqlite")
+		os.Remove("./test/output-reference.sqlite")
+		os.Remove("./test/output-Account-pg.sql")
+	})
+
+	It("should write a file of CREATE TABLE and INSERT statements for each type", func() {
+		w := peanut.NewSQLDumpWriter("./test/output-", "-pg", peanut.DialectPostgreSQL)
+
+		testWritesAndCloseSequential(w)
+
+		output, err := ioutil.ReadFile("./test/output-Foo-pg.sql")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("" +
+			"CREATE TABLE \"Foo\" (\n" +
+			"\t\"foo_string\" TEXT NOT NULL,\n" +
+			"\t\"foo_int\" BIGINT NOT NULL,\n" +
+			"PRIMARY KEY (\"foo_string\")\n" +
+			");\n" +
+			"INSERT INTO \"Foo\" (\"foo_string\", \"foo_int\") VALUES\n" +
+			"('test 1', 1),\n" +
+			"('test 2', 2),\n" +
+			"('test 3', 3);\n"))
+
+		output, err = ioutil.ReadFile("./test/output-Baz-pg.sql")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(ContainSubstring("\t\"baz_uint64\" NUMERIC(20) NOT NULL,\n"))
+		Expect(string(output)).To(HaveSuffix("" +
+			"('test 1', TRUE, 1.234, 9.876, -12345, -8, -16, -32, -64, 12345, 8, 16, 32, 64);\n"))
+
+		Expect("./test/output-Qux-pg.sql").ToNot(BeAnExistingFile())
+	})
+
+	It("should declare columns of driver.Valuer types by the kind of their values", func() {
+		w := peanut.NewSQLDumpWriter("./test/output-", "-pg", peanut.DialectPostgreSQL)
+
+		testWritesValuersAndClose(w)
+
+		output, err := ioutil.ReadFile("./test/output-Account-pg.sql")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(ContainSubstring("\t\"status\" TEXT NOT NULL,\n"))
+		Expect(string(output)).To(ContainSubstring("\t\"rank\" BIGINT NOT NULL,\n"))
+		Expect(string(output)).To(HaveSuffix("('a1', 'active', 2);\n"))
+	})
+
+	It("should write rows in batches of BatchSize", func() {
+		w := peanut.NewSQLDumpWriter("./test/output-", "-batch", peanut.DialectSQLite)
+		w.BatchSize = 2
+		for _, x := range testOutputFoo {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		Expect(w.Close()).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Foo-batch.sql")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(HaveSuffix("" +
+			"INSERT INTO \"Foo\" (\"foo_string\", \"foo_int\") VALUES\n" +
+			"('test 1', 1),\n" +
+			"('test 2', 2);\n" +
+			"INSERT INTO \"Foo\" (\"foo_string\", \"foo_int\") VALUES\n" +
+			"('test 3', 3);\n"))
+	})
+
+	It("should quote identifiers and literals for MySQL", func() {
+		w := peanut.NewSQLDumpWriter("./test/output-", "-mysql", peanut.DialectMySQL)
+		Expect(w.Write(&Quoting{ID: "q1", Text: "it's a \\ \x00 test", Flag: true})).To(BeNil())
+		Expect(w.Close()).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Quoting-mysql.sql")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("" +
+			"CREATE TABLE `Quoting` (\n" +
+			"\t`id` VARCHAR(255) NOT NULL,\n" +
+			"\t`te\"xt` TEXT NOT NULL,\n" +
+			"\t`flag` BOOLEAN NOT NULL,\n" +
+			"\t`note` TEXT,\n" +
+			"PRIMARY KEY (`id`)\n" +
+			");\n" +
+			"INSERT INTO `Quoting` (`id`, `te\"xt`, `flag`, `note`) VALUES\n" +
+			"('q1', 'it''s a \\\\ \\0 test', TRUE, NULL);\n"))
+	})
+
+	It("should write times and durations for MySQL", func() {
+		w := peanut.NewSQLDumpWriter("./test/output-", "-mysql", peanut.DialectMySQL)
+
+		testWritesTimesAndClose(w)
+
+		output, err := ioutil.ReadFile("./test/output-Times-mysql.sql")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("" +
+			"CREATE TABLE `Times` (\n" +
+			"\t`id` VARCHAR(255) NOT NULL,\n" +
+			"\t`time` DATETIME(6) NOT NULL,\n" +
+			"\t`date` TEXT NOT NULL,\n" +
+			"\t`duration` BIGINT NOT NULL,\n" +
+			"PRIMARY KEY (`id`)\n" +
+			");\n" +
+			"INSERT INTO `Times` (`id`, `time`, `date`, `duration`) VALUES\n" +
+			"('t1', '2021-04-19 13:45:30', '2021-04-19', 5400000000000);\n"))
+	})
+
+	It("should write a single SQLite script matching the output of SQLiteWriter", func() {
+		dump := peanut.NewSQLDumpFileWriter("./test/output-dump", peanut.DialectSQLite)
+		ref := peanut.NewSQLiteWriter("./test/output-reference")
+		w := peanut.MultiWriter(dump, ref)
+
+		for _, x := range testOutputFoo {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		for _, x := range testOutputBaz {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		for _, x := range testOutputTimes {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		for _, x := range testOutputNullable {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		for _, x := range testOutputNested {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		for _, x := range testOutputCustom {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		Expect(w.Close()).To(BeNil())
+
+		script, err := ioutil.ReadFile("./test/output-dump.sql")
+		Expect(err).To(BeNil())
+		db, err := sql.Open("sqlite3", "./test/output-dump.sqlite")
+		Expect(err).To(BeNil())
+		_, err = db.Exec(string(script))
+		Expect(err).To(BeNil())
+		Expect(db.Close()).To(BeNil())
+
+		output, err := readSQLite("./test/output-dump.sqlite")
+		Expect(err).To(BeNil())
+		expected, err := readSQLite("./test/output-reference.sqlite")
+		Expect(err).To(BeNil())
+		Expect(output).To(HaveLen(6))
+		Expect(output).To(Equal(expected))
+	})
+
+	It("should not write anything when structs are written and cancel is called", func() {
+		w := peanut.NewSQLDumpWriter("./test/output-", "-cancel", peanut.DialectSQLite)
+
+		testWritesAndCancel(w)
+
+		Expect("./test/output-Foo-cancel.sql").ToNot(BeAnExistingFile())
+		Expect("./test/output-Bar-cancel.sql").ToNot(BeAnExistingFile())
+	})
+
+	It("should return an error when Write is called after Close", func() {
+		w := peanut.NewSQLDumpWriter("./test/output-", "-close-write", peanut.DialectSQLite)
+
+		testWriteAfterClose(w)
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			w := peanut.NewSQLDumpWriter("./no-such-location/output-bogus-", "", peanut.DialectSQLite)
+
+			testWriteBadType(w)
+		})
+	})
+})
@@ -0,0 +1,173 @@
+package peanut
+
+import (
+	"database/sql"
+	"io"
+	"reflect"
+)
+
+var _ Reader = &SQLiteReader{}
+
+// SQLiteReader reads records from an SQLite database, such as
+// one written by SQLiteWriter, reading each record type from
+// an individual table.
+//
+// Tables are named using NameFunc, which defaults to TypeName,
+// using the type's name, or a name given by a struct-level tag.
+//
+// Rows are read in the order in which they were inserted, and
+// columns are matched to the names in the struct's field tags.
+// Unless Lenient is set, missing and unexpected columns result
+// in an error.
+//
+// Nullable fields (pointers and sql.Null* types)
+// are read as null when their values are NULL.
+//
+// The caller must call Close when finished reading.
+type SQLiteReader struct {
+	*base
+	NameFunc     NameFunc // NameFunc names the tables, TypeName is used if nil.
+	Lenient      bool     // Lenient permits missing and unexpected columns.
+	filename     string   // filename is the database filename.
+	db           *sql.DB  // db is the database instance.
+	sourceByType map[reflect.Type]*sqliteSource
+}
+
+// NewSQLiteReader returns a new SQLiteReader,
+// reading from the database at the given filename + ".sqlite".
+func NewSQLiteReader(filename string) *SQLiteReader {
+	r := SQLiteReader{
+		base:         &base{format: formatSQLite},
+		filename:     filename + ".sqlite",
+		sourceByType: make(map[reflect.Type]*sqliteSource),
+	}
+	return &r
+}
+
+type sqliteSource struct {
+	rows   *sql.Rows
+	fields []*fieldPlan  // fields holds the field for each column, or nil if unused.
+	vals   []interface{} // vals is reused for each row read.
+	ptrs   []interface{} // ptrs holds pointers to each of vals, for use with Scan.
+	row    int           // row is the number of the last row read.
+}
+
+func (r *SQLiteReader) source(x interface{}) (*sqliteSource, error) {
+	// Register with base.
+	t, _, err := r.base.registerRead(x, r.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := r.sourceByType[t]; ok {
+		return s, nil
+	}
+
+	// Lazy init of database.
+	if r.db == nil {
+		// Open read-only, so that a missing database is not created.
+		db, err := sql.Open("sqlite3", "file:"+r.filename+"?mode=ro")
+		if err != nil {
+			return nil, err
+		}
+		r.db = db
+	}
+
+	rows, err := r.db.Query("SELECT * FROM \"" + r.nameByType[t] + "\" ORDER BY rowid")
+	if err != nil {
+		return nil, err
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+	s := &sqliteSource{
+		rows: rows,
+		vals: make([]interface{}, len(columns)),
+		ptrs: make([]interface{}, len(columns)),
+	}
+	for i := range s.vals {
+		s.ptrs[i] = &s.vals[i]
+	}
+	s.fields, err = mapColumns(r.planByType[t], columns, r.Lenient, r.filename)
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+	r.sourceByType[t] = s
+	return s, nil
+}
+
+// Read reads the next record from the table
+// corresponding to the type of the given record,
+// which must be a pointer to a struct, returning
+// io.EOF when no more records remain.
+func (r *SQLiteReader) Read(x interface{}) error {
+	if r.closed {
+		return ErrClosedReader
+	}
+	v, err := readTarget(x)
+	if err != nil {
+		return err
+	}
+	s, err := r.source(x)
+	if err != nil {
+		return err
+	}
+	if !s.rows.Next() {
+		err := s.rows.Err()
+		if err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	s.row++
+	err = s.rows.Scan(s.ptrs...)
+	if err != nil {
+		return &ReadError{File: r.filename, Row: s.row, Err: err}
+	}
+
+	v.Set(reflect.Zero(v.Type()))
+	for i, f := range s.fields {
+		if f == nil {
+			continue
+		}
+		val := s.vals[i]
+		err := f.set(v, val, val == nil)
+		if err != nil {
+			return &ReadError{File: r.filename, Row: s.row, Column: f.header, Err: err}
+		}
+	}
+	return nil
+}
+
+// Close closes all open queries,
+// and the database connection.
+//
+// Calling Close more than once is safe,
+// and subsequent calls are a no-op.
+func (r *SQLiteReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.db == nil {
+		return nil
+	}
+
+	var rerr error
+
+	for _, s := range r.sourceByType {
+		err := s.rows.Close()
+		if err != nil {
+			rerr = err
+		}
+	}
+
+	err := r.db.Close()
+	if err != nil {
+		rerr = err
+	}
+
+	return rerr
+}
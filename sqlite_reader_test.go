@@ -0,0 +1,80 @@
+package peanut_test
+
+import (
+	"errors"
+	"os"
+	"reflect"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+// FooSubset is read from the Foo table, which has an extra column.
+type FooSubset struct {
+	StringField string `peanut:"foo_string"`
+}
+
+var _ = Describe("SQLiteReader", func() {
+
+	AfterEach(func() {
+		os.Remove("./test/output-read.sqlite")
+	})
+
+	It("should read back the records written by SQLiteWriter", func() {
+		testWritesAndCloseSequential(peanut.NewSQLiteWriter("./test/output-read"))
+
+		testReadsAndClose(peanut.NewSQLiteReader("./test/output-read"))
+	})
+
+	It("should read back times, nulls and nested structs", func() {
+		w := peanut.NewSQLiteWriter("./test/output-read")
+		for _, x := range testOutputTimes {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		for _, x := range testOutputNullable {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		testWritesNestedAndClose(w)
+
+		testReadsTimesNullableAndNested(peanut.NewSQLiteReader("./test/output-read"))
+	})
+
+	It("should return an error for unexpected columns, unless lenient", func() {
+		testWritesAndCloseSequential(peanut.NewSQLiteWriter("./test/output-read"))
+
+		nameFn := func(t reflect.Type) string { return "Foo" }
+
+		r := peanut.NewSQLiteReader("./test/output-read")
+		r.NameFunc = nameFn
+		err := r.Read(&FooSubset{})
+		Expect(errors.Is(err, peanut.ErrUnexpectedColumn)).To(BeTrue())
+		Expect(err.Error()).To(MatchRegexp("foo_int"))
+		Expect(r.Close()).To(BeNil())
+
+		r = peanut.NewSQLiteReader("./test/output-read")
+		r.NameFunc = nameFn
+		r.Lenient = true
+		defer r.Close()
+		foo, err := readAll[FooSubset](r)
+		Expect(err).To(BeNil())
+		Expect(foo).To(Equal([]*FooSubset{{"test 1"}, {"test 2"}, {"test 3"}}))
+	})
+
+	It("should return an error when the database does not exist", func() {
+		r := peanut.NewSQLiteReader("./test/no-such-db")
+		defer r.Close()
+		err := r.Read(&Foo{})
+		Expect(err).ToNot(BeNil())
+		_, err = os.Stat("./test/no-such-db.sqlite")
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			testReadBadType(peanut.NewSQLiteReader("./test/output-read"))
+		})
+	})
+})
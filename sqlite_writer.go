@@ -5,6 +5,7 @@ import (
 	"os"
 	"reflect"
 	"strings"
+	"time"
 
 	// Import Sqlite db driver.
 	_ "github.com/mattn/go-sqlite3"
@@ -18,6 +19,9 @@ import (
 // writing each record type to an individual table
 // automatically.
 //
+// Tables are named using NameFunc, which defaults to TypeName,
+// using the type's name, or a name given by a struct-level tag.
+//
 // During writing, the database file is held in a
 // temporary location, and only moved into its
 // final destination during a successful Close operation.
@@ -49,13 +53,19 @@ import (
 //  }
 // Compound primary keys are also supported.
 //
+// Columns for nullable fields (pointers and sql.Null* types)
+// are created without a NOT NULL constraint, and nil or
+// invalid values are written as NULL.
+//
 // SQLiteWriter has no support for foreign keys, indexes, etc.
 type SQLiteWriter struct {
 	*base
+	NameFunc     NameFunc                   // NameFunc names the tables, TypeName is used if nil.
 	tmpFilename  string                     // tmpFilename is the filename used by the temp file.
 	dstFilename  string                     // dstFilename is the final destination filename.
 	insertByType map[reflect.Type]*sql.Stmt // insertByType holds prepared INSERT statements.
 	db           *sql.DB                    // db is the database instance.
+	args         []interface{}              // args is reused for each record written.
 }
 
 // TODO(js) Can we unify/simplify the constructors? Use pattern instead of prefix/suffix maybe? (not here, but for others)
@@ -64,7 +74,7 @@ type SQLiteWriter struct {
 // using the given filename + ".sqlite" as its final output location.
 func NewSQLiteWriter(filename string) *SQLiteWriter {
 	w := SQLiteWriter{
-		base:         &base{},
+		base:         &base{format: formatSQLite},
 		dstFilename:  filename + ".sqlite",
 		insertByType: make(map[reflect.Type]*sql.Stmt),
 	}
@@ -73,7 +83,10 @@ func NewSQLiteWriter(filename string) *SQLiteWriter {
 
 func (w *SQLiteWriter) register(x interface{}) (reflect.Type, error) {
 	// Register with base writer.
-	t, ok := w.base.register(x)
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
 		return t, nil
 	}
@@ -107,7 +120,7 @@ func (w *SQLiteWriter) register(x interface{}) (reflect.Type, error) {
 	// log.Println("DDL:", ddl)
 
 	// Execute DDL to create table.
-	_, err := w.db.Exec(ddl)
+	_, err = w.db.Exec(ddl)
 	if err != nil {
 		return nil, err
 	}
@@ -142,60 +155,24 @@ var kindToDBType = map[reflect.Kind]string{
 	reflect.Uint:    "UNSIGNED INT64",
 }
 
-func (w *SQLiteWriter) createDDL(t reflect.Type) string {
-
-	// Create table using type name - quoted.
-	ddl := "CREATE TABLE \"" + t.Name() + "\" (\n"
-
-	// List of DDL statements to build the table definition.
-	var ddlLines []string
-	// List of primary keys.
-	var pks []string
-
-	hdrs := w.headersByType[t]
-	typs := w.typesByType[t]
-	tags := w.tagsByType[t]
-
-	for i := 0; i < len(typs); i++ {
-		// Column name - quoted.
-		col := "\t\"" + hdrs[i] + "\" "
-
-		// Column datatype.
-		col += kindToDBType[typs[i].Kind()]
-		// We ensure kindToDBType has necessary entries using a test,
-		// so no need to check for missing entries here.
-
-		// Column constraints.
-		col += " NOT NULL"
-
-		// Add DDL line to list.
-		ddlLines = append(ddlLines, col)
-
-		// Handle primary key tag.
-		if secondTagValue(tags[i]) == "pk" {
-			// Add column name to primary key list.
-			pks = append(pks, hdrs[i])
-		}
-	}
-
-	// Primary key.
-	if len(pks) > 0 {
-		pk := "PRIMARY KEY ("
-		pk += strings.Join(pks, ", ")
-		pk += ")"
-		ddlLines = append(ddlLines, pk)
+// dbType returns the column datatype used for fields of type t.
+func dbType(t reflect.Type) string {
+	t = columnType(t, formatSQLite)
+	if t == timeType {
+		return "DATETIME"
 	}
+	// We ensure kindToDBType has necessary entries using a test,
+	// so no need to check for missing entries here.
+	return kindToDBType[t.Kind()]
+}
 
-	// Join the lines of DDL together.
-	ddl += strings.Join(ddlLines, ",\n")
-
-	ddl += "\n)"
-	return ddl
+func (w *SQLiteWriter) createDDL(t reflect.Type) string {
+	return createDDL(DialectSQLite, w.nameByType[t], w.planByType[t])
 }
 
 func (w *SQLiteWriter) createInsert(t reflect.Type) string {
 	// TODO(js) Add an option to allow different insert modes (default/ignore/update).
-	s := "INSERT OR IGNORE INTO \"" + t.Name() + "\" ("
+	s := "INSERT OR IGNORE INTO \"" + w.nameByType[t] + "\" ("
 	hdrs := w.headersByType[t]
 	s += strings.Join(hdrs, ",")
 	s += ") VALUES ("
@@ -220,13 +197,32 @@ func (w *SQLiteWriter) Write(x interface{}) error {
 	if err != nil {
 		return err
 	}
-	if len(w.base.tagsByType[t]) == 0 {
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *SQLiteWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
 		return nil
 	}
-
+	var err error
 	// log.Printf("WriteRecord for %s", t.Name())
 	stmt := w.insertByType[t]
-	_, err = stmt.Exec(excelValuesFrom(x)...)
+	w.args, err = p.values(x, w.args[:0])
+	if err != nil {
+		return err
+	}
+	// Times are passed through to the driver, unless the field's
+	// tag specifies a format, in which case they are stored as text.
+	for i, f := range p.fields {
+		if f.formatted {
+			if tm, ok := w.args[i].(time.Time); ok {
+				w.args[i] = tm.Format(f.layout)
+			}
+		}
+	}
+	_, err = stmt.Exec(w.args...)
 	return err
 }
 
@@ -110,6 +110,92 @@ var _ = Describe("SQLiteWriter", func() {
 		Expect(err).ToNot(BeNil())
 	})
 
+	It("should write time fields with a datetime column type", func() {
+		w := newFn("-times")
+
+		testWritesTimesAndClose(w)
+		defer os.Remove("./test/output-times.sqlite")
+
+		output, err := readSQLite("./test/output-times.sqlite")
+		Expect(err).To(BeNil())
+		Expect(output["Times"].types).To(Equal([]string{"TEXT", "DATETIME", "DATETIME", "INT64"}))
+		Expect(output["Times"].data).To(Equal([][]string{
+			{"t1", "2021-04-19T13:45:30Z", "2021-04-19T00:00:00Z", "5400000000000"},
+		}))
+	})
+
+	It("should write null values as NULL in nullable columns", func() {
+		w := newFn("-nullable")
+
+		testWritesNullableAndClose(w)
+		defer os.Remove("./test/output-nullable.sqlite")
+
+		output, err := readSQLite("./test/output-nullable.sqlite")
+		Expect(err).To(BeNil())
+		Expect(output["Nullable"].types).To(Equal([]string{"TEXT", "INT64", "TEXT", "TEXT", "INT64", "DATETIME", "REAL"}))
+
+		db, err := sql.Open("sqlite3", "./test/output-nullable.sqlite")
+		Expect(err).To(BeNil())
+		defer db.Close()
+
+		var nulls int
+		err = db.QueryRow("SELECT COUNT(*) FROM Nullable WHERE int_ptr IS NULL AND string_ptr IS NULL AND null_string IS NULL AND null_int64 IS NULL AND null_time IS NULL AND null_float IS NULL").Scan(&nulls)
+		Expect(err).To(BeNil())
+		Expect(nulls).To(Equal(1))
+
+		var notNull int
+		err = db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('Nullable') WHERE "notnull" = 1`).Scan(&notNull)
+		Expect(err).To(BeNil())
+		Expect(notNull).To(Equal(1))
+	})
+
+	It("should flatten nested structs, with nullable columns for nested pointers", func() {
+		w := newFn("-nested")
+
+		testWritesNestedAndClose(w)
+		defer os.Remove("./test/output-nested.sqlite")
+
+		output, err := readSQLite("./test/output-nested.sqlite")
+		Expect(err).To(BeNil())
+		Expect(output["Nested"].columns).To(Equal([]string{"id", "home_street", "home_city", "work_street", "work_city", "created_by", "updated_by"}))
+
+		db, err := sql.Open("sqlite3", "./test/output-nested.sqlite")
+		Expect(err).To(BeNil())
+		defer db.Close()
+
+		var id string
+		err = db.QueryRow("SELECT id FROM Nested WHERE work_street IS NULL AND work_city IS NULL").Scan(&id)
+		Expect(err).To(BeNil())
+		Expect(id).To(Equal("n2"))
+	})
+
+	It("should write custom types using the interfaces they implement", func() {
+		w := newFn("-custom")
+
+		testWritesCustomAndClose(w)
+		defer os.Remove("./test/output-custom.sqlite")
+
+		output, err := readSQLite("./test/output-custom.sqlite")
+		Expect(err).To(BeNil())
+		Expect(output["Custom"].types).To(Equal([]string{"TEXT", "REAL", "TEXT", "TEXT", "TEXT", "TEXT"}))
+		Expect(output["Custom"].data).To(Equal([][]string{
+			{"c1", "12.34", "warning", "192.168.0.1", `{"x":1,"y":2}`, "CODE-a"},
+		}))
+	})
+
+	It("should name tables using the configured NameFunc", func() {
+		w := peanut.NewSQLiteWriter("./test/output-qualified")
+		w.NameFunc = peanut.QualifiedTypeName
+
+		testWritesAndCloseSequential(w)
+		defer os.Remove("./test/output-qualified.sqlite")
+
+		output, err := readSQLite("./test/output-qualified.sqlite")
+		Expect(err).To(BeNil())
+		Expect(output).To(HaveKey("peanut_test.Foo"))
+		Expect(output).To(HaveKey("peanut_test.Bar"))
+	})
+
 	Context("when given a struct with an unsupported field type", func() {
 
 		It("should return an error with an informative message", func() {
@@ -166,7 +252,7 @@ func readSQLite(filename string) (map[string]*tableResults, error) {
 }
 
 func readData(db *sql.DB, table string) ([][]string, error) {
-	q := "SELECT * FROM " + table
+	q := "SELECT * FROM \"" + table + "\""
 	rows, err := db.Query(q)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,363 @@
+package peanut
+
+// TOMLWriter
+// - See https://toml.io/en/v1.0.0
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+var _ Writer = &TOMLWriter{}
+
+// TOMLWriter writes records to TOML files, writing each
+// record type to an individual TOML file automatically.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".toml"
+//
+// If Compression is set, files are compressed accordingly,
+// and named with its Extension, as in ".toml.gz".
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Each file holds an array of tables, named using NameFunc,
+// with a table for each record, keyed using the names extracted
+// from the struct's field tags, in the order that they appear
+// within the struct:
+//  [[Shape]]
+//  shape_id = "sid1"
+//  name = "Square"
+//  num_sides = 4
+//
+// Times are written as TOML offset date-times, unless they
+// have a format option. As TOML has no null value, keys
+// having null values are omitted. Unsigned integers greater
+// than the largest int64 cannot be written, and result in
+// an error.
+//
+// The caller must call Close on successful completion
+// of all writing, to ensure buffers are flushed and
+// files are properly written to disk.
+//
+// In the event of an error or cancellation, the
+// caller must call Cancel before quiting, to ensure
+// closure and cleanup of any partially written files.
+type TOMLWriter struct {
+	*base
+	NameFunc      NameFunc    // NameFunc names the tables and output files, TypeName is used if nil.
+	Compression   Compression // Compression compresses the output files, which are uncompressed by default.
+	prefix        string
+	suffix        string
+	builderByType map[reflect.Type]*tomlBuilder
+}
+
+// NewTOMLWriter returns a new TOMLWriter, using prefix
+// and suffix when building its output filenames.
+//
+// See TOMLWriter (above) for output filename details.
+func NewTOMLWriter(prefix, suffix string) *TOMLWriter {
+	w := TOMLWriter{
+		base:          &base{format: formatTOML},
+		prefix:        prefix,
+		suffix:        suffix,
+		builderByType: make(map[reflect.Type]*tomlBuilder),
+	}
+	return &w
+}
+
+type tomlBuilder struct {
+	filename string
+	file     *os.File
+	zw       io.WriteCloser // zw compresses output written to file.
+	bw       *bufio.Writer
+	header   []byte        // header begins each table.
+	keys     [][]byte      // keys holds the key of each field, followed by " = ".
+	rows     int           // rows is the number of records written.
+	table    []byte        // table is reused for each record written.
+	values   []interface{} // values is reused for each record written.
+}
+
+func (w *TOMLWriter) register(x interface{}) (reflect.Type, error) {
+	// Register with base writer.
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t, nil
+	}
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, err
+	}
+	if len(w.base.tagsByType[t]) == 0 {
+		return t, nil
+	}
+
+	// log.Printf("Setting up TOML writer for %s", t.Name())
+
+	name := w.prefix + w.nameByType[t] + w.suffix + ".toml" + w.Compression.Extension()
+	file, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		return nil, err
+	}
+	zw, err := w.Compression.newWriter(file)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	p := w.planByType[t]
+	b := &tomlBuilder{
+		filename: name,
+		file:     file,
+		zw:       zw,
+		bw:       bufio.NewWriter(zw),
+		keys:     make([][]byte, len(p.fields)),
+	}
+	b.header = append([]byte("[["), appendTOMLKey(nil, w.nameByType[t])...)
+	b.header = append(b.header, "]]\n"...)
+	for i, f := range p.fields {
+		b.keys[i] = append(appendTOMLKey(nil, f.header), " = "...)
+	}
+	w.builderByType[t] = b
+	return t, nil
+}
+
+// Write is called to persist records.
+// Each record is written as an individual table
+// in the corresponding output file, according to the
+// type of the given record.
+func (w *TOMLWriter) Write(x interface{}) error {
+	if w.closed {
+		return ErrClosedWriter
+	}
+	t, err := w.register(x)
+	if err != nil {
+		return err
+	}
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *TOMLWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	var err error
+	// log.Printf("WriteRecord for %s", t.Name())
+	b := w.builderByType[t]
+	b.values, err = p.values(x, b.values[:0])
+	if err != nil {
+		return err
+	}
+
+	// Build the table before writing it,
+	// so that no partial table is written.
+	b.table = b.table[:0]
+	if b.rows > 0 {
+		b.table = append(b.table, '\n')
+	}
+	b.table = append(b.table, b.header...)
+	for i, val := range b.values {
+		if val == nil {
+			continue
+		}
+		b.table = append(b.table, b.keys[i]...)
+		b.table, err = appendTOMLValue(b.table, val, p.fields[i])
+		if err != nil {
+			return p.errorf(p.fields[i], err)
+		}
+		b.table = append(b.table, '\n')
+	}
+	b.rows++
+	_, err = b.bw.Write(b.table)
+	return err
+}
+
+// appendTOMLKey appends s to b as a key,
+// which is quoted unless it is a valid bare key.
+func appendTOMLKey(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-' {
+			continue
+		}
+		return appendTOMLString(b, s)
+	}
+	if s == "" {
+		return append(b, `""`...)
+	}
+	return append(b, s...)
+}
+
+// appendTOMLValue appends val to b as a TOML value.
+func appendTOMLValue(b []byte, val interface{}, f *fieldPlan) ([]byte, error) {
+	switch v := builtinValue(val).(type) {
+	case time.Time:
+		if f.formatted {
+			return appendTOMLString(b, v.Format(f.layout)), nil
+		}
+		return v.AppendFormat(b, time.RFC3339Nano), nil
+	case time.Duration:
+		return appendTOMLString(b, v.String()), nil
+	case []byte:
+		return appendTOMLString(b, string(v)), nil
+	case string:
+		return appendTOMLString(b, v), nil
+	case bool:
+		return strconv.AppendBool(b, v), nil
+	case int64:
+		return strconv.AppendInt(b, v, 10), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return b, errors.New("value out of range for TOML integer")
+		}
+		return strconv.AppendUint(b, v, 10), nil
+	case float32:
+		return appendTOMLFloat(b, float64(v), 32), nil
+	case float64:
+		return appendTOMLFloat(b, v, 64), nil
+	}
+	return appendTOMLString(b, formatValue(val, f.layout)), nil
+}
+
+// appendTOMLFloat appends x, a float of the given bit size,
+// to b as a TOML float.
+func appendTOMLFloat(b []byte, x float64, bits int) []byte {
+	switch {
+	case math.IsNaN(x):
+		return append(b, "nan"...)
+	case math.IsInf(x, 1):
+		return append(b, "inf"...)
+	case math.IsInf(x, -1):
+		return append(b, "-inf"...)
+	}
+	return append(b, formatDecimal(x, bits)...)
+}
+
+// appendTOMLString appends s to b as a TOML basic string.
+// Invalid UTF-8 is replaced with the Unicode replacement character.
+func appendTOMLString(b []byte, s string) []byte {
+	const hex = "0123456789ABCDEF"
+	b = append(b, '"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b = append(b, '\\', '"')
+		case '\\':
+			b = append(b, '\\', '\\')
+		case '\b':
+			b = append(b, '\\', 'b')
+		case '\t':
+			b = append(b, '\\', 't')
+		case '\n':
+			b = append(b, '\\', 'n')
+		case '\f':
+			b = append(b, '\\', 'f')
+		case '\r':
+			b = append(b, '\\', 'r')
+		default:
+			if r < 0x20 || r == 0x7f {
+				b = append(b, '\\', 'u', '0', '0', hex[r>>4], hex[r&0xf])
+				continue
+			}
+			b = utf8.AppendRune(b, r)
+		}
+	}
+	return append(b, '"')
+}
+
+// Close flushes all buffers and writers,
+// and closes the output files.
+//
+// Calling Close after a previous call to
+// Cancel is safe, and always results in a no-op.
+func (w *TOMLWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, b := range w.builderByType {
+		var cerr error
+		var err error
+
+		err = b.bw.Flush()
+		if err != nil {
+			cerr = err
+		}
+
+		err = b.zw.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		// Chmod the file world-readable (ioutil.TempFile creates files with
+		// mode 0600) before renaming.
+		err = b.file.Chmod(0644)
+		if err != nil {
+			cerr = err
+		}
+
+		// fsync(2) after fchmod(2) orders writes as per
+		// https://lwn.net/Articles/270891/.
+		b.file.Sync()
+
+		err = b.file.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		if cerr != nil {
+			rerr = cerr
+			continue
+		}
+
+		err = os.Rename(b.file.Name(), b.filename)
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// Cancel should be called in the event of an error occurring,
+// to properly close and delete any partially written files.
+func (w *TOMLWriter) Cancel() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, b := range w.builderByType {
+		var err error
+
+		// Release any resources held by the compressor.
+		b.zw.Close()
+
+		err = b.file.Close()
+		if err != nil {
+			rerr = err
+		}
+
+		err = os.Remove(b.file.Name())
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
@@ -0,0 +1,170 @@
+package peanut_test
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+type TOMLKeys struct {
+	_     struct{} `peanut:"my records"`
+	Plain string   `peanut:"plain-key_1"`
+	Space string   `peanut:"with space"`
+}
+
+var _ = Describe("TOMLWriter", func() {
+
+	AfterEach(func() {
+		os.Remove("./test/output-Foo-tables.toml")
+		os.Remove("./test/output-Bar-tables.toml")
+		os.Remove("./test/output-Baz-tables.toml")
+		os.Remove("./test/output-Times-times.toml")
+		os.Remove("./test/output-Nullable-nullable.toml")
+		os.Remove("./test/output-Escaping-escaping.toml")
+		os.Remove("./test/output-my records-keys.toml")
+	})
+
+	It("should write an array of tables in struct field order", func() {
+		w := peanut.NewTOMLWriter("./test/output-", "-tables")
+
+		testWritesAndCloseSequential(w)
+
+		output, err := ioutil.ReadFile("./test/output-Foo-tables.toml")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("" +
+			"[[Foo]]\n" +
+			"foo_string = \"test 1\"\n" +
+			"foo_int = 1\n" +
+			"\n" +
+			"[[Foo]]\n" +
+			"foo_string = \"test 2\"\n" +
+			"foo_int = 2\n" +
+			"\n" +
+			"[[Foo]]\n" +
+			"foo_string = \"test 3\"\n" +
+			"foo_int = 3\n"))
+
+		output, err = ioutil.ReadFile("./test/output-Baz-tables.toml")
+		Expect(err).To(BeNil())
+		var baz map[string][]map[string]interface{}
+		Expect(toml.Unmarshal(output, &baz)).To(BeNil())
+		Expect(baz).To(Equal(map[string][]map[string]interface{}{"Baz": {{
+			"baz_string": "test 1", "baz_bool": true, "baz_float32": 1.234, "baz_float64": 9.876,
+			"baz_int": int64(-12345), "baz_int8": int64(-8), "baz_int16": int64(-16), "baz_int32": int64(-32), "baz_int64": int64(-64),
+			"baz_uint": int64(12345), "baz_uint8": int64(8), "baz_uint16": int64(16), "baz_uint32": int64(32), "baz_uint64": int64(64),
+		}}}))
+
+		Expect("./test/output-Qux-tables.toml").ToNot(BeAnExistingFile())
+	})
+
+	It("should write times and durations, and omit nulls", func() {
+		w := peanut.NewTOMLWriter("./test/output-", "-times")
+
+		testWritesTimesAndClose(w)
+
+		output, err := ioutil.ReadFile("./test/output-Times-times.toml")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("" +
+			"[[Times]]\n" +
+			"id = \"t1\"\n" +
+			"time = 2021-04-19T13:45:30Z\n" +
+			"date = \"2021-04-19\"\n" +
+			"duration = \"1h30m0s\"\n"))
+
+		var times map[string][]map[string]interface{}
+		Expect(toml.Unmarshal(output, &times)).To(BeNil())
+		Expect(times["Times"][0]["time"]).To(BeTemporally("==", testOutputTimes[0].Time))
+
+		w = peanut.NewTOMLWriter("./test/output-", "-nullable")
+
+		testWritesNullableAndClose(w)
+
+		output, err = ioutil.ReadFile("./test/output-Nullable-nullable.toml")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(HaveSuffix("" +
+			"null_float = 1.5\n" +
+			"\n" +
+			"[[Nullable]]\n" +
+			"id = \"n2\"\n"))
+	})
+
+	It("should escape strings and write special float values", func() {
+		w := peanut.NewTOMLWriter("./test/output-", "-escaping")
+		tests := []*Escaping{
+			{Text: "a \"q\" \\ \x01\x7f\t\né", Float: 1},
+			{Text: "", Float: math.Inf(-1), Flag: true},
+			{Text: "x", Float: 1e21},
+		}
+		for _, x := range tests {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		Expect(w.Close()).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Escaping-escaping.toml")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(HavePrefix("" +
+			"[[Escaping]]\n" +
+			"text = \"a \\\"q\\\" \\\\ \\u0001\\u007F\\t\\né\"\n" +
+			"float = 1.0\n" +
+			"flag = false\n"))
+
+		var records map[string][]map[string]interface{}
+		Expect(toml.Unmarshal(output, &records)).To(BeNil())
+		for i, r := range records["Escaping"] {
+			Expect(r["text"]).To(Equal(tests[i].Text))
+			Expect(r["float"]).To(Equal(tests[i].Float))
+			Expect(r["flag"]).To(Equal(tests[i].Flag))
+		}
+	})
+
+	It("should quote keys and table names that are not bare keys", func() {
+		w := peanut.NewTOMLWriter("./test/output-", "-keys")
+		Expect(w.Write(&TOMLKeys{Plain: "a", Space: "b"})).To(BeNil())
+		Expect(w.Close()).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-my records-keys.toml")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("" +
+			"[[\"my records\"]]\n" +
+			"plain-key_1 = \"a\"\n" +
+			"\"with space\" = \"b\"\n"))
+	})
+
+	It("should return an error when an unsigned integer is out of range", func() {
+		w := peanut.NewTOMLWriter("./test/output-", "-range")
+		err := w.Write(&Baz{StringField: "big", Uint64Field: math.MaxUint64})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Baz.Uint64Field"))
+		Expect(w.Cancel()).To(BeNil())
+	})
+
+	It("should not write anything when structs are written and cancel is called", func() {
+		w := peanut.NewTOMLWriter("./test/output-", "-cancel")
+
+		testWritesAndCancel(w)
+
+		Expect("./test/output-Foo-cancel.toml").ToNot(BeAnExistingFile())
+		Expect("./test/output-Bar-cancel.toml").ToNot(BeAnExistingFile())
+	})
+
+	It("should return an error when Write is called after Close", func() {
+		w := peanut.NewTOMLWriter("./test/output-", "-close-write")
+
+		testWriteAfterClose(w)
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			w := peanut.NewTOMLWriter("./no-such-location/output-bogus-", "")
+
+			testWriteBadType(w)
+		})
+	})
+})
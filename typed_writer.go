@@ -0,0 +1,120 @@
+package peanut
+
+import (
+	"fmt"
+	"iter"
+	"reflect"
+)
+
+// TypedWriter is a type-safe wrapper around a Writer,
+// for writing records of type T, which must be a
+// tagged struct type, or a pointer to one.
+//
+// Unlike Writer, where problems with a record's type are
+// only reported when a record is first written, the type T
+// is validated when a TypedWriter is created.
+type TypedWriter[T any] struct {
+	w  Writer
+	t  reflect.Type // t is the struct type of T.
+	p  *typePlan    // p is the plan used by w to encode records of type t.
+	rw recordWriter // rw is w, once records of type t are registered with it, if w is a recordWriter.
+}
+
+// NewTypedWriter returns a new TypedWriter, writing records of type T to w.
+//
+// An error is returned if T is not a struct type (or a pointer to one),
+// if it has no tagged fields, if any tagged field has an unsupported type,
+// or if its generated Encoder methods are out of date.
+func NewTypedWriter[T any](w Writer) (*TypedWriter[T], error) {
+	var zero T
+	t := reflect.TypeOf(&zero).Elem()
+	format := ""
+	if rw, ok := w.(recordWriter); ok {
+		format = rw.outputFormat()
+	}
+	p, err := recordTypePlan(t, format)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedWriter[T]{w: w, t: baseType(zero), p: p}, nil
+}
+
+// recordTypePlan returns the plan for encoding records of type t,
+// for writers of the given format, or an error if t is not suitable
+// for use as a record type.
+func recordTypePlan(t reflect.Type, format string) (*typePlan, error) {
+	st := t
+	if st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
+	if st.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("peanut: unsupported record type: %s", t)
+	}
+	x := reflect.Zero(t).Interface()
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, err
+	}
+	if len(structFields(st)) == 0 {
+		return nil, fmt.Errorf("peanut: no tagged fields in %s", st.Name())
+	}
+	p := planFor(st, format)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p, nil
+}
+
+// Write writes a single record.
+//
+// Once the first record has been written, records are written
+// without looking up their type, by writers supporting it.
+func (tw *TypedWriter[T]) Write(r T) error {
+	if tw.rw != nil {
+		if tw.rw.isClosed() {
+			return ErrClosedWriter
+		}
+		return tw.rw.writeRecord(tw.t, tw.p, r)
+	}
+	err := tw.w.Write(r)
+	if err != nil {
+		return err
+	}
+	if rw, ok := tw.w.(recordWriter); ok {
+		tw.rw = rw
+	}
+	return nil
+}
+
+// WriteAll writes all of the given records,
+// stopping at the first error.
+func (tw *TypedWriter[T]) WriteAll(rs []T) error {
+	for _, r := range rs {
+		err := tw.Write(r)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteSeq writes all of the records yielded by seq,
+// stopping at the first error.
+func (tw *TypedWriter[T]) WriteSeq(seq iter.Seq[T]) error {
+	for r := range seq {
+		err := tw.Write(r)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close calls Close on the underlying writer.
+func (tw *TypedWriter[T]) Close() error {
+	return tw.w.Close()
+}
+
+// Cancel calls Cancel on the underlying writer.
+func (tw *TypedWriter[T]) Cancel() error {
+	return tw.w.Cancel()
+}
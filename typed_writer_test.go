@@ -0,0 +1,116 @@
+package peanut_test
+
+import (
+	"io/ioutil"
+	"os"
+	"slices"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+var _ = Describe("TypedWriter", func() {
+
+	expectedData := []map[string]string{
+		{"foo_string": "test 1", "foo_int": "1"},
+		{"foo_string": "test 2", "foo_int": "2"},
+		{"foo_string": "test 3", "foo_int": "3"},
+	}
+
+	It("should write records one at a time", func() {
+		m := &peanut.MockWriter{}
+		w, err := peanut.NewTypedWriter[*Foo](m)
+		Expect(err).To(BeNil())
+
+		for _, r := range testOutputFoo {
+			err = w.Write(r)
+			Expect(err).To(BeNil())
+		}
+		err = w.Close()
+		Expect(err).To(BeNil())
+
+		Expect(m.Data["Foo"]).To(Equal(expectedData))
+		Expect(m.CalledClose).To(Equal(1))
+	})
+
+	It("should write all records from a slice", func() {
+		m := &peanut.MockWriter{}
+		w, err := peanut.NewTypedWriter[*Foo](m)
+		Expect(err).To(BeNil())
+
+		err = w.WriteAll(testOutputFoo)
+		Expect(err).To(BeNil())
+
+		Expect(m.Data["Foo"]).To(Equal(expectedData))
+	})
+
+	It("should write all records from a sequence", func() {
+		m := &peanut.MockWriter{}
+		w, err := peanut.NewTypedWriter[Baz](m)
+		Expect(err).To(BeNil())
+
+		err = w.WriteSeq(slices.Values(testOutputBaz))
+		Expect(err).To(BeNil())
+		err = w.Cancel()
+		Expect(err).To(BeNil())
+
+		Expect(m.Data["Baz"]).To(HaveLen(1))
+		Expect(m.CalledCancel).To(Equal(1))
+	})
+
+	It("should write records directly to writers supporting it", func() {
+		cw := peanut.NewCSVWriter("./test/output-", "-typed")
+		w, err := peanut.NewTypedWriter[*Foo](cw)
+		Expect(err).To(BeNil())
+		defer os.Remove("./test/output-Foo-typed.csv")
+
+		err = w.WriteAll(testOutputFoo)
+		Expect(err).To(BeNil())
+		err = w.Close()
+		Expect(err).To(BeNil())
+
+		err = w.Write(testOutputFoo[0])
+		Expect(err).To(Equal(peanut.ErrClosedWriter))
+
+		output, err := ioutil.ReadFile("./test/output-Foo-typed.csv")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("foo_string,foo_int\n" +
+			"test 1,1\n" +
+			"test 2,2\n" +
+			"test 3,3\n"))
+	})
+
+	It("should stop writing at the first error", func() {
+		w, err := peanut.NewTypedWriter[*Foo](&failWriter{})
+		Expect(err).To(BeNil())
+
+		err = w.WriteAll(testOutputFoo)
+		Expect(err).ToNot(BeNil())
+	})
+
+	Context("when given an unsuitable type", func() {
+
+		It("should return an error for a type that is not a struct", func() {
+			_, err := peanut.NewTypedWriter[string](&peanut.MockWriter{})
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("should return an error for a struct without tagged fields", func() {
+			_, err := peanut.NewTypedWriter[*Qux](&peanut.MockWriter{})
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(MatchRegexp("Qux"))
+		})
+
+		It("should return an error with an informative message for an unsupported field type", func() {
+			_, err := peanut.NewTypedWriter[BadUnsupported](&peanut.MockWriter{})
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(SatisfyAll(
+				MatchRegexp(`slice`),
+				MatchRegexp("BytesField"),
+				MatchRegexp("BadUnsupported"),
+			))
+		})
+	})
+})
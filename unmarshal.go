@@ -0,0 +1,308 @@
+package peanut
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Unmarshaler is the interface implemented by types that can unmarshal
+// themselves from values read by peanut readers. It is the counterpart
+// of Marshaler, and is usually implemented by the same types.
+//
+// UnmarshalPeanut is called with the format of the reader, as for
+// Marshaler.MarshalPeanut, and a value of the type returned by the
+// PeanutType method of Marshaler. It is not called for null values.
+type Unmarshaler interface {
+	UnmarshalPeanut(format string, v interface{}) error
+}
+
+var (
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	scannerType         = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// Interfaces honoured when unmarshaling values, in order of preference,
+// mirroring those used for marshaling. Unmarshaler is always preferred,
+// and readers for formats not listed here use defaultUnmarshalers.
+var (
+	defaultUnmarshalers = []reflect.Type{textUnmarshalerType, scannerType, jsonUnmarshalerType}
+	formatUnmarshalers  = map[string][]reflect.Type{
+		formatJSONL:  {jsonUnmarshalerType, textUnmarshalerType, scannerType},
+		formatSQLite: {scannerType, textUnmarshalerType, jsonUnmarshalerType},
+	}
+)
+
+// unmarshalerFor returns the interface that will be used to unmarshal
+// values of type t for the given format, or nil if values of type t are
+// read as-is. As for marshalerFor, types with built-in support are always
+// read as-is. Only pointers to t are checked, as unmarshaling requires
+// a pointer receiver. Types implementing Marshaler can only be
+// unmarshaled by implementing Unmarshaler.
+func unmarshalerFor(t reflect.Type, format string) reflect.Type {
+	if t == timeType || t == durationType {
+		return nil
+	}
+	if _, ok := nullTypes[t]; ok {
+		return nil
+	}
+	pt := reflect.PtrTo(t)
+	if implements(t, marshalerType) {
+		if pt.Implements(unmarshalerType) {
+			return unmarshalerType
+		}
+		return nil
+	}
+	list, ok := formatUnmarshalers[format]
+	if !ok {
+		list = defaultUnmarshalers
+	}
+	for _, it := range list {
+		if pt.Implements(it) {
+			return it
+		}
+	}
+	return nil
+}
+
+// valueDecoder returns a func that sets v, a field of type t,
+// from a (non-nil) value read by a reader of the given format,
+// using layout to parse any time.Time values held as text.
+// It returns nil if values of type t cannot be read.
+//
+// The values passed to the returned func are either text (string),
+// raw JSON (json.RawMessage), or values of the types returned by
+// database drivers, such as int64, float64, []byte and time.Time.
+func valueDecoder(t reflect.Type, format, layout string) func(v reflect.Value, val interface{}) error {
+	if t.Kind() == reflect.Ptr {
+		dec := valueDecoder(t.Elem(), format, layout)
+		if dec == nil {
+			return nil
+		}
+		return func(v reflect.Value, val interface{}) error {
+			if v.IsNil() {
+				v.Set(reflect.New(t.Elem()))
+			}
+			return dec(v.Elem(), val)
+		}
+	}
+	if e, ok := nullTypes[t]; ok {
+		dec := valueDecoder(e, format, layout)
+		return func(v reflect.Value, val interface{}) error {
+			// All of the sql.Null* types hold their value in
+			// their first field, followed by a Valid field.
+			if err := dec(v.Field(0), val); err != nil {
+				return err
+			}
+			v.Field(1).SetBool(true)
+			return nil
+		}
+	}
+
+	it := unmarshalerFor(t, format)
+	if it == nil && marshalerFor(t, format) != nil {
+		// Values are written in their marshaled form,
+		// which cannot be read without unmarshaling.
+		return nil
+	}
+	switch it {
+	case unmarshalerType:
+		pt := reflect.New(t).Interface().(Marshaler).PeanutType()
+		dec := valueDecoder(pt, format, layout)
+		if dec == nil {
+			return nil
+		}
+		return func(v reflect.Value, val interface{}) error {
+			pv := reflect.New(pt).Elem()
+			if err := dec(pv, val); err != nil {
+				return err
+			}
+			return v.Addr().Interface().(Unmarshaler).UnmarshalPeanut(format, pv.Interface())
+		}
+	case jsonUnmarshalerType:
+		return func(v reflect.Value, val interface{}) error {
+			var b []byte
+			switch val := val.(type) {
+			case json.RawMessage:
+				b = val
+			case string:
+				b = []byte(val)
+			case []byte:
+				b = val
+			default:
+				return fmt.Errorf("cannot unmarshal %T into %s", val, t)
+			}
+			return v.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(b)
+		}
+	case textUnmarshalerType:
+		return func(v reflect.Value, val interface{}) error {
+			s, err := textOf(val)
+			if err != nil {
+				return err
+			}
+			return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+		}
+	case scannerType:
+		return func(v reflect.Value, val interface{}) error {
+			val, err := plainValue(val)
+			if err != nil {
+				return err
+			}
+			return v.Addr().Interface().(sql.Scanner).Scan(val)
+		}
+	}
+
+	switch t {
+	case timeType:
+		return func(v reflect.Value, val interface{}) error {
+			if tm, ok := val.(time.Time); ok {
+				v.Set(reflect.ValueOf(tm))
+				return nil
+			}
+			s, err := textOf(val)
+			if err != nil {
+				return err
+			}
+			tm, err := time.Parse(layout, s)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(tm))
+			return nil
+		}
+	case durationType:
+		return func(v reflect.Value, val interface{}) error {
+			switch val := val.(type) {
+			case time.Duration:
+				v.SetInt(int64(val))
+				return nil
+			case int64:
+				v.SetInt(val)
+				return nil
+			}
+			s, err := textOf(val)
+			if err != nil {
+				return err
+			}
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return func(v reflect.Value, val interface{}) error {
+			s, err := textOf(val)
+			if err != nil {
+				return err
+			}
+			v.SetString(s)
+			return nil
+		}
+	case reflect.Bool:
+		return func(v reflect.Value, val interface{}) error {
+			s, err := textOf(val)
+			if err != nil {
+				return err
+			}
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return err
+			}
+			v.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value, val interface{}) error {
+			s, err := textOf(val)
+			if err != nil {
+				return err
+			}
+			n, err := strconv.ParseInt(s, 10, t.Bits())
+			if err != nil {
+				return err
+			}
+			v.SetInt(n)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value, val interface{}) error {
+			s, err := textOf(val)
+			if err != nil {
+				return err
+			}
+			n, err := strconv.ParseUint(s, 10, t.Bits())
+			if err != nil {
+				return err
+			}
+			v.SetUint(n)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		return func(v reflect.Value, val interface{}) error {
+			s, err := textOf(val)
+			if err != nil {
+				return err
+			}
+			f, err := strconv.ParseFloat(s, t.Bits())
+			if err != nil {
+				return err
+			}
+			v.SetFloat(f)
+			return nil
+		}
+	}
+	return nil
+}
+
+// plainValue resolves raw JSON values to the plain Go values they
+// hold, using json.Number for numbers. Other values are returned as-is.
+func plainValue(val interface{}) (interface{}, error) {
+	raw, ok := val.(json.RawMessage)
+	if !ok {
+		return val, nil
+	}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	var x interface{}
+	if err := d.Decode(&x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// textOf returns the text of a value read by a reader.
+func textOf(val interface{}) (string, error) {
+	val, err := plainValue(val)
+	if err != nil {
+		return "", err
+	}
+	switch val := val.(type) {
+	case string:
+		return val, nil
+	case []byte:
+		return string(val), nil
+	case json.Number:
+		return val.String(), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64), nil
+	case time.Time:
+		return val.Format(time.RFC3339Nano), nil
+	}
+	return "", fmt.Errorf("cannot read %T as text", val)
+}
@@ -0,0 +1,336 @@
+package peanut
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"unicode"
+)
+
+var _ Writer = &XMLWriter{}
+
+// DefaultXMLRecordName is the name of the element written
+// for each record when XMLWriter.RecordName is empty.
+const DefaultXMLRecordName = "record"
+
+// XMLWriter writes records to XML files, writing
+// each record type to an individual XML file automatically.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".xml"
+//
+// If Compression is set, files are compressed accordingly,
+// and named with its Extension, as in ".xml.gz".
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Each file holds a single XML document, whose root element
+// is named using RootName, or by the same name as the file
+// if RootName is nil. The root element contains an element
+// for each record, named RecordName, which defaults to
+// DefaultXMLRecordName.
+//
+// Fields are written as child elements of their record's
+// element, named using the names extracted from the struct's
+// field tags, in the order that they appear within the struct.
+// Fields whose tag has the attr option are instead written as
+// attributes of their record's element:
+//  type Shape struct {
+//  	ShapeID string `peanut:"id,attr"`
+//  	Name    string `peanut:"name"`
+//  }
+// Values are written as text, as by CSVWriter.
+// Null values are omitted.
+//
+// Element and attribute names must be valid XML names.
+//
+// The caller must call Close on successful completion
+// of all writing, to ensure buffers are flushed and
+// files are properly written to disk.
+//
+// In the event of an error or cancellation, the
+// caller must call Cancel before quiting, to ensure
+// closure and cleanup of any partially written files.
+type XMLWriter struct {
+	*base
+	NameFunc      NameFunc    // NameFunc names the output files, TypeName is used if nil.
+	Compression   Compression // Compression compresses the output files, which are uncompressed by default.
+	RootName      NameFunc    // RootName names the root elements, the output name is used if nil.
+	RecordName    string      // RecordName names the record elements.
+	prefix        string
+	suffix        string
+	builderByType map[reflect.Type]*xmlBuilder
+}
+
+// NewXMLWriter returns a new XMLWriter, using prefix
+// and suffix when building its output filenames.
+//
+// See XMLWriter (above) for output filename details.
+func NewXMLWriter(prefix, suffix string) *XMLWriter {
+	w := XMLWriter{
+		base:          &base{format: formatXML},
+		prefix:        prefix,
+		suffix:        suffix,
+		builderByType: make(map[reflect.Type]*xmlBuilder),
+	}
+	return &w
+}
+
+type xmlBuilder struct {
+	filename string
+	file     *os.File
+	zw       io.WriteCloser // zw compresses output written to file.
+	enc      *xml.Encoder
+	root     xml.StartElement
+	record   xml.StartElement // record is reused for each record written.
+	names    []xml.Name       // names holds the name of each field.
+	attrs    []bool           // attrs reports whether each field is an attribute.
+	values   []interface{}    // values is reused for each record written.
+}
+
+func (w *XMLWriter) register(x interface{}) (reflect.Type, error) {
+	// Register with base writer.
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t, nil
+	}
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, err
+	}
+	if len(w.base.tagsByType[t]) == 0 {
+		return t, nil
+	}
+
+	rootName := w.nameByType[t]
+	if w.RootName != nil {
+		rootName = w.RootName(t)
+	}
+	recordName := w.RecordName
+	if recordName == "" {
+		recordName = DefaultXMLRecordName
+	}
+	p := w.planByType[t]
+	names := []string{rootName, recordName}
+	for _, f := range p.fields {
+		names = append(names, f.header)
+	}
+	for _, name := range names {
+		if !isXMLName(name) {
+			return nil, fmt.Errorf("peanut: invalid XML name %q for %s", name, t.Name())
+		}
+	}
+
+	// log.Printf("Setting up XML writer for %s", t.Name())
+
+	name := w.prefix + w.nameByType[t] + w.suffix + ".xml" + w.Compression.Extension()
+	file, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		return nil, err
+	}
+	zw, err := w.Compression.newWriter(file)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	b := &xmlBuilder{
+		filename: name,
+		file:     file,
+		zw:       zw,
+		enc:      xml.NewEncoder(zw),
+		root:     xml.StartElement{Name: xml.Name{Local: rootName}},
+		record:   xml.StartElement{Name: xml.Name{Local: recordName}},
+		names:    make([]xml.Name, len(p.fields)),
+		attrs:    make([]bool, len(p.fields)),
+	}
+	for i, f := range p.fields {
+		b.names[i] = xml.Name{Local: f.header}
+		b.attrs[i] = hasTagOption(f.tag, "attr")
+	}
+
+	// Begin the document.
+	err = b.enc.EncodeToken(xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="UTF-8"`)})
+	if err == nil {
+		err = b.enc.EncodeToken(xml.CharData("\n"))
+	}
+	if err == nil {
+		err = b.enc.EncodeToken(b.root)
+	}
+	if err != nil {
+		zw.Close()
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	w.builderByType[t] = b
+	return t, nil
+}
+
+// isXMLName reports whether s is a valid XML element or attribute name,
+// without a namespace prefix.
+func isXMLName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if unicode.IsLetter(r) || r == '_' {
+			continue
+		}
+		if i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Write is called to persist records.
+// Each record is written to an individual element
+// in the corresponding output file, according to the
+// type of the given record.
+func (w *XMLWriter) Write(x interface{}) error {
+	if w.closed {
+		return ErrClosedWriter
+	}
+	t, err := w.register(x)
+	if err != nil {
+		return err
+	}
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *XMLWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	var err error
+	// log.Printf("WriteRecord for %s", t.Name())
+	b := w.builderByType[t]
+	b.values, err = p.values(x, b.values[:0])
+	if err != nil {
+		return err
+	}
+
+	b.record.Attr = b.record.Attr[:0]
+	for i, val := range b.values {
+		if b.attrs[i] && val != nil {
+			s := formatValue(val, p.fields[i].layout)
+			b.record.Attr = append(b.record.Attr, xml.Attr{Name: b.names[i], Value: s})
+		}
+	}
+	err = b.enc.EncodeToken(b.record)
+	if err != nil {
+		return err
+	}
+	for i, val := range b.values {
+		if b.attrs[i] || val == nil {
+			continue
+		}
+		s := formatValue(val, p.fields[i].layout)
+		err = b.enc.EncodeElement(s, xml.StartElement{Name: b.names[i]})
+		if err != nil {
+			return err
+		}
+	}
+	return b.enc.EncodeToken(b.record.End())
+}
+
+// Close flushes all buffers and writers,
+// and closes the output files.
+//
+// Calling Close after a previous call to
+// Cancel is safe, and always results in a no-op.
+func (w *XMLWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, b := range w.builderByType {
+		var cerr error
+		var err error
+
+		// End the document.
+		err = b.enc.EncodeToken(b.root.End())
+		if err == nil {
+			err = b.enc.EncodeToken(xml.CharData("\n"))
+		}
+		if err == nil {
+			err = b.enc.Close()
+		}
+		if err != nil {
+			cerr = err
+		}
+
+		err = b.zw.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		// Chmod the file world-readable (ioutil.TempFile creates files with
+		// mode 0600) before renaming.
+		err = b.file.Chmod(0644)
+		if err != nil {
+			cerr = err
+		}
+
+		// fsync(2) after fchmod(2) orders writes as per
+		// https://lwn.net/Articles/270891/.
+		b.file.Sync()
+
+		err = b.file.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		if cerr != nil {
+			rerr = cerr
+			continue
+		}
+
+		err = os.Rename(b.file.Name(), b.filename)
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// Cancel should be called in the event of an error occurring,
+// to properly close and delete any partially written files.
+func (w *XMLWriter) Cancel() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, b := range w.builderByType {
+		var err error
+
+		// Release any resources held by the compressor.
+		b.zw.Close()
+
+		err = b.file.Close()
+		if err != nil {
+			rerr = err
+		}
+
+		err = os.Remove(b.file.Name())
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
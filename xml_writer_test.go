@@ -0,0 +1,213 @@
+package peanut_test
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"reflect"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jimsmart/peanut"
+)
+
+type XMLShape struct {
+	ShapeID  string  `peanut:"id,attr"`
+	Name     string  `peanut:"name"`
+	NumSides *int    `peanut:"sides,attr"`
+	Notes    *string `peanut:"notes"`
+}
+
+type XMLBadName struct {
+	ID string `peanut:"1st"`
+}
+
+var _ = Describe("XMLWriter", func() {
+
+	newFn := func(suffix string) peanut.Writer {
+		w := peanut.NewXMLWriter("./test/output-", suffix)
+		return w
+	}
+
+	const header = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+
+	expectedOutput1 := header + `<Foo>` +
+		`<record><foo_string>test 1</foo_string><foo_int>1</foo_int></record>` +
+		`<record><foo_string>test 2</foo_string><foo_int>2</foo_int></record>` +
+		`<record><foo_string>test 3</foo_string><foo_int>3</foo_int></record>` +
+		`</Foo>` + "\n"
+
+	expectedOutput2 := header + `<Bar>` +
+		`<record><bar_int>1</bar_int><bar_string>test 1</bar_string></record>` +
+		`<record><bar_int>2</bar_int><bar_string>test 2</bar_string></record>` +
+		`<record><bar_int>3</bar_int><bar_string>test 3</bar_string></record>` +
+		`</Bar>` + "\n"
+
+	expectedOutput3 := header + `<Baz><record>` +
+		`<baz_string>test 1</baz_string><baz_bool>true</baz_bool><baz_float32>1.234</baz_float32><baz_float64>9.876</baz_float64>` +
+		`<baz_int>-12345</baz_int><baz_int8>-8</baz_int8><baz_int16>-16</baz_int16><baz_int32>-32</baz_int32><baz_int64>-64</baz_int64>` +
+		`<baz_uint>12345</baz_uint><baz_uint8>8</baz_uint8><baz_uint16>16</baz_uint16><baz_uint32>32</baz_uint32><baz_uint64>64</baz_uint64>` +
+		`</record></Baz>` + "\n"
+
+	AfterEach(func() {
+		os.Remove("./test/output-Foo-sequential.xml")
+		os.Remove("./test/output-Bar-sequential.xml")
+		os.Remove("./test/output-Baz-sequential.xml")
+		os.Remove("./test/output-Qux-sequential.xml")
+		os.Remove("./test/output-Foo-interleave.xml")
+		os.Remove("./test/output-Bar-interleave.xml")
+		os.Remove("./test/output-Baz-interleave.xml")
+		os.Remove("./test/output-Qux-interleave.xml")
+		os.Remove("./test/output-XMLShape-attr.xml")
+		os.Remove("./test/output-Foo-names.xml")
+		os.Remove("./test/output-Times-times.xml")
+		os.Remove("./test/output-Nullable-nullable.xml")
+	})
+
+	It("should write the correct data when sequential structs are written", func() {
+		w := newFn("-sequential")
+
+		testWritesAndCloseSequential(w)
+
+		output1, err := ioutil.ReadFile("./test/output-Foo-sequential.xml")
+		Expect(err).To(BeNil())
+		Expect(string(output1)).To(Equal(expectedOutput1))
+
+		output2, err := ioutil.ReadFile("./test/output-Bar-sequential.xml")
+		Expect(err).To(BeNil())
+		Expect(string(output2)).To(Equal(expectedOutput2))
+
+		output3, err := ioutil.ReadFile("./test/output-Baz-sequential.xml")
+		Expect(err).To(BeNil())
+		Expect(string(output3)).To(Equal(expectedOutput3))
+
+		Expect("./test/output-Qux-sequential.xml").ToNot(BeAnExistingFile())
+	})
+
+	It("should write the correct data when interleaved structs are written", func() {
+		w := newFn("-interleave")
+
+		testWritesAndCloseInterleaved(w)
+
+		output1, err := ioutil.ReadFile("./test/output-Foo-interleave.xml")
+		Expect(err).To(BeNil())
+		Expect(string(output1)).To(Equal(expectedOutput1))
+
+		output2, err := ioutil.ReadFile("./test/output-Bar-interleave.xml")
+		Expect(err).To(BeNil())
+		Expect(string(output2)).To(Equal(expectedOutput2))
+
+		output3, err := ioutil.ReadFile("./test/output-Baz-interleave.xml")
+		Expect(err).To(BeNil())
+		Expect(string(output3)).To(Equal(expectedOutput3))
+
+		Expect("./test/output-Qux-interleave.xml").ToNot(BeAnExistingFile())
+	})
+
+	It("should write fields tagged with attr as attributes, escaping text and omitting nulls", func() {
+		w := newFn("-attr")
+
+		sides := 3
+		notes := "a < b & c"
+		err := w.Write(&XMLShape{ShapeID: "s1", Name: `"Tri" & <angle>`, NumSides: &sides, Notes: &notes})
+		Expect(err).To(BeNil())
+		err = w.Write(&XMLShape{ShapeID: "s2", Name: "Blob"})
+		Expect(err).To(BeNil())
+		err = w.Close()
+		Expect(err).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-XMLShape-attr.xml")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal(header + `<XMLShape>` +
+			`<record id="s1" sides="3"><name>&#34;Tri&#34; &amp; &lt;angle&gt;</name><notes>a &lt; b &amp; c</notes></record>` +
+			`<record id="s2"><name>Blob</name></record>` +
+			`</XMLShape>` + "\n"))
+
+		// Output should be well-formed.
+		var doc struct {
+			Records []struct {
+				ID    string `xml:"id,attr"`
+				Sides string `xml:"sides,attr"`
+				Name  string `xml:"name"`
+			} `xml:"record"`
+		}
+		Expect(xml.Unmarshal(output, &doc)).To(BeNil())
+		Expect(doc.Records).To(HaveLen(2))
+		Expect(doc.Records[0].Name).To(Equal(`"Tri" & <angle>`))
+		Expect(doc.Records[0].Sides).To(Equal("3"))
+	})
+
+	It("should use the configured root and record element names", func() {
+		w := peanut.NewXMLWriter("./test/output-", "-names")
+		w.RootName = func(t reflect.Type) string {
+			return "foos"
+		}
+		w.RecordName = "foo"
+
+		err := w.Write(testOutputFoo[0])
+		Expect(err).To(BeNil())
+		err = w.Close()
+		Expect(err).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Foo-names.xml")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal(header + `<foos><foo><foo_string>test 1</foo_string><foo_int>1</foo_int></foo></foos>` + "\n"))
+	})
+
+	It("should write time fields using RFC 3339 or the tagged format, and nulls as nothing", func() {
+		w := newFn("-times")
+
+		testWritesTimesAndClose(w)
+
+		output, err := ioutil.ReadFile("./test/output-Times-times.xml")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal(header + `<Times><record><id>t1</id><time>2021-04-19T13:45:30Z</time><date>2021-04-19</date><duration>1h30m0s</duration></record></Times>` + "\n"))
+
+		w = newFn("-nullable")
+
+		testWritesNullableAndClose(w)
+
+		output, err = ioutil.ReadFile("./test/output-Nullable-nullable.xml")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal(header + `<Nullable>` +
+			`<record><id>n1</id><int_ptr>1</int_ptr><string_ptr>test 1</string_ptr><null_string>test 1</null_string><null_int64>1</null_int64><null_time>2021-04-19T13:45:30Z</null_time><null_float>1.5</null_float></record>` +
+			`<record><id>n2</id></record>` +
+			`</Nullable>` + "\n"))
+	})
+
+	It("should return an error when a name is not a valid XML name", func() {
+		w := newFn("-badname")
+
+		err := w.Write(&XMLBadName{ID: "x"})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring(`"1st"`))
+		Expect(w.Cancel()).To(BeNil())
+	})
+
+	It("should not write anything when structs are written and cancel is called", func() {
+		w := newFn("-cancel")
+
+		testWritesAndCancel(w)
+
+		Expect("./test/output-Foo-cancel.xml").ToNot(BeAnExistingFile())
+		Expect("./test/output-Bar-cancel.xml").ToNot(BeAnExistingFile())
+	})
+
+	It("should return an error when Write is called after Close", func() {
+		w := newFn("-close-write")
+
+		testWriteAfterClose(w)
+
+		Expect("./test/output-Foo-close-write.xml").ToNot(BeAnExistingFile())
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			w := peanut.NewXMLWriter("./no-such-location/output-bogus-", "")
+
+			testWriteBadType(w)
+		})
+	})
+})
@@ -0,0 +1,344 @@
+package peanut
+
+// YAMLWriter
+// - See https://yaml.org/spec/1.2.2/
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var _ Writer = &YAMLWriter{}
+
+// YAMLWriter writes records to YAML files, writing each
+// record type to an individual YAML file automatically.
+//
+// Filenames for each corresponding record type are derived
+// accordingly:
+//  prefix + NameFunc(type) + suffix + ".yaml"
+//
+// If Compression is set, files are compressed accordingly,
+// and named with its Extension, as in ".yaml.gz".
+//
+// NameFunc defaults to TypeName, which uses the type's
+// name, or a name given by a struct-level tag.
+//
+// Each file holds a sequence, with a mapping for each record,
+// keyed using the names extracted from the struct's field tags,
+// in the order that they appear within the struct. If Documents
+// is set, each record is instead written as an individual
+// document, in a multi-document stream.
+//
+// Null values are written as null.
+//
+// The caller must call Close on successful completion
+// of all writing, to ensure buffers are flushed and
+// files are properly written to disk.
+//
+// In the event of an error or cancellation, the
+// caller must call Cancel before quiting, to ensure
+// closure and cleanup of any partially written files.
+type YAMLWriter struct {
+	*base
+	NameFunc      NameFunc    // NameFunc names the output files, TypeName is used if nil.
+	Compression   Compression // Compression compresses the output files, which are uncompressed by default.
+	Documents     bool        // Documents writes each record as a document, rather than as a sequence item.
+	prefix        string
+	suffix        string
+	builderByType map[reflect.Type]*yamlBuilder
+}
+
+// NewYAMLWriter returns a new YAMLWriter, using prefix
+// and suffix when building its output filenames.
+//
+// See YAMLWriter (above) for output filename details.
+func NewYAMLWriter(prefix, suffix string) *YAMLWriter {
+	w := YAMLWriter{
+		base:          &base{format: formatYAML},
+		prefix:        prefix,
+		suffix:        suffix,
+		builderByType: make(map[reflect.Type]*yamlBuilder),
+	}
+	return &w
+}
+
+type yamlBuilder struct {
+	filename string
+	file     *os.File
+	zw       io.WriteCloser // zw compresses output written to file.
+	bw       *bufio.Writer
+	buf      bytes.Buffer  // buf holds each encoded record.
+	root     *yaml.Node    // root is encoded for each record written.
+	scalars  []yaml.Node   // scalars holds the values of each record written.
+	rows     int           // rows is the number of records written.
+	values   []interface{} // values is reused for each record written.
+}
+
+func (w *YAMLWriter) register(x interface{}) (reflect.Type, error) {
+	// Register with base writer.
+	t, ok, err := w.base.register(x, w.NameFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t, nil
+	}
+	if err := allFieldsSupportedKinds(x); err != nil {
+		return nil, err
+	}
+	if len(w.base.tagsByType[t]) == 0 {
+		return t, nil
+	}
+
+	// log.Printf("Setting up YAML writer for %s", t.Name())
+
+	name := w.prefix + w.nameByType[t] + w.suffix + ".yaml" + w.Compression.Extension()
+	file, err := ioutil.TempFile("", "atomic-")
+	if err != nil {
+		return nil, err
+	}
+	zw, err := w.Compression.newWriter(file)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	// Build the nodes encoded for each record,
+	// only the values change from record to record.
+	p := w.planByType[t]
+	b := &yamlBuilder{
+		filename: name,
+		file:     file,
+		zw:       zw,
+		bw:       bufio.NewWriter(zw),
+		scalars:  make([]yaml.Node, len(p.fields)),
+	}
+	m := &yaml.Node{Kind: yaml.MappingNode}
+	for i, f := range p.fields {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: f.header}
+		m.Content = append(m.Content, key, &b.scalars[i])
+	}
+	b.root = m
+	if !w.Documents {
+		b.root = &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{m}}
+	}
+	w.builderByType[t] = b
+	return t, nil
+}
+
+// Write is called to persist records.
+// Each record is written as an individual mapping
+// in the corresponding output file, according to the
+// type of the given record.
+func (w *YAMLWriter) Write(x interface{}) error {
+	if w.closed {
+		return ErrClosedWriter
+	}
+	t, err := w.register(x)
+	if err != nil {
+		return err
+	}
+	return w.writeRecord(t, w.planByType[t], x)
+}
+
+// writeRecord writes the record x, of the registered type t,
+// using the plan p. The writer must not be closed.
+func (w *YAMLWriter) writeRecord(t reflect.Type, p *typePlan, x interface{}) error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	var err error
+	// log.Printf("WriteRecord for %s", t.Name())
+	b := w.builderByType[t]
+	b.values, err = p.values(x, b.values[:0])
+	if err != nil {
+		return err
+	}
+	for i, val := range b.values {
+		setYAMLScalar(&b.scalars[i], val, p.fields[i])
+	}
+
+	b.buf.Reset()
+	enc := yaml.NewEncoder(&b.buf)
+	enc.SetIndent(2)
+	err = enc.Encode(b.root)
+	if err == nil {
+		err = enc.Close()
+	}
+	if err != nil {
+		return err
+	}
+	if w.Documents && b.rows > 0 {
+		b.bw.WriteString("---\n")
+	}
+	b.rows++
+	_, err = b.bw.Write(b.buf.Bytes())
+	return err
+}
+
+// yamlOldBool holds the strings read as booleans by YAML 1.1 readers.
+var yamlOldBool = map[string]bool{
+	"y": true, "yes": true, "on": true,
+	"n": true, "no": true, "off": true,
+}
+
+// setYAMLScalar sets n to a scalar node holding val.
+func setYAMLScalar(n *yaml.Node, val interface{}, f *fieldPlan) {
+	n.Kind = yaml.ScalarNode
+	n.Style = 0
+	switch v := builtinValue(val).(type) {
+	case nil:
+		n.Tag, n.Value = "!!null", "null"
+	case time.Time:
+		n.Tag, n.Value = "!!timestamp", v.Format(f.layout)
+		if f.formatted {
+			n.Tag = "!!str"
+		}
+	case time.Duration:
+		n.Tag, n.Value = "!!str", v.String()
+	case []byte:
+		n.Tag, n.Value = "!!str", string(v)
+	case string:
+		n.Tag, n.Value = "!!str", v
+		if yamlOldBool[strings.ToLower(n.Value)] {
+			// Quoted, as with yaml.Marshal, for YAML 1.1 readers.
+			n.Style = yaml.DoubleQuotedStyle
+		}
+	case bool:
+		n.Tag, n.Value = "!!bool", strconv.FormatBool(v)
+	case int64:
+		n.Tag, n.Value = "!!int", strconv.FormatInt(v, 10)
+	case uint64:
+		n.Tag, n.Value = "!!int", strconv.FormatUint(v, 10)
+	case float32:
+		n.Tag, n.Value = "!!float", yamlFloat(float64(v), 32)
+	case float64:
+		n.Tag, n.Value = "!!float", yamlFloat(v, 64)
+	default:
+		n.Tag, n.Value = "!!str", formatValue(val, f.layout)
+	}
+	if n.Tag == "!!str" && strings.ContainsAny(n.Value, "\r\n") {
+		// Quoted, as the emitter writes strings of only
+		// line breaks as block scalars that read back empty.
+		n.Style = yaml.DoubleQuotedStyle
+	}
+}
+
+// yamlFloat returns the text of x, a float of the given bit size,
+// as a YAML float.
+func yamlFloat(x float64, bits int) string {
+	switch {
+	case math.IsNaN(x):
+		return ".nan"
+	case math.IsInf(x, 1):
+		return ".inf"
+	case math.IsInf(x, -1):
+		return "-.inf"
+	}
+	return formatDecimal(x, bits)
+}
+
+// formatDecimal returns the shortest text representing the
+// finite float x, always having a decimal point or an exponent,
+// so that it is not mistaken for an integer.
+func formatDecimal(x float64, bits int) string {
+	s := strconv.FormatFloat(x, 'g', -1, bits)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// Close flushes all buffers and writers,
+// and closes the output files.
+//
+// Calling Close after a previous call to
+// Cancel is safe, and always results in a no-op.
+func (w *YAMLWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, b := range w.builderByType {
+		var cerr error
+		var err error
+
+		err = b.bw.Flush()
+		if err != nil {
+			cerr = err
+		}
+
+		err = b.zw.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		// Chmod the file world-readable (ioutil.TempFile creates files with
+		// mode 0600) before renaming.
+		err = b.file.Chmod(0644)
+		if err != nil {
+			cerr = err
+		}
+
+		// fsync(2) after fchmod(2) orders writes as per
+		// https://lwn.net/Articles/270891/.
+		b.file.Sync()
+
+		err = b.file.Close()
+		if err != nil {
+			cerr = err
+		}
+
+		if cerr != nil {
+			rerr = cerr
+			continue
+		}
+
+		err = os.Rename(b.file.Name(), b.filename)
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// Cancel should be called in the event of an error occurring,
+// to properly close and delete any partially written files.
+func (w *YAMLWriter) Cancel() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var rerr error
+	for _, b := range w.builderByType {
+		var err error
+
+		// Release any resources held by the compressor.
+		b.zw.Close()
+
+		err = b.file.Close()
+		if err != nil {
+			rerr = err
+		}
+
+		err = os.Remove(b.file.Name())
+		if err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
@@ -0,0 +1,159 @@
+package peanut_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+
+	"github.com/jimsmart/peanut"
+)
+
+var _ = Describe("YAMLWriter", func() {
+
+	AfterEach(func() {
+		os.Remove("./test/output-Foo-sequence.yaml")
+		os.Remove("./test/output-Bar-sequence.yaml")
+		os.Remove("./test/output-Baz-sequence.yaml")
+		os.Remove("./test/output-Foo-documents.yaml")
+		os.Remove("./test/output-Times-times.yaml")
+		os.Remove("./test/output-Nullable-nullable.yaml")
+		os.Remove("./test/output-Escaping-quoting.yaml")
+	})
+
+	It("should write a sequence of mappings in struct field order", func() {
+		w := peanut.NewYAMLWriter("./test/output-", "-sequence")
+
+		testWritesAndCloseSequential(w)
+
+		output, err := ioutil.ReadFile("./test/output-Foo-sequence.yaml")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("" +
+			"- foo_string: test 1\n" +
+			"  foo_int: 1\n" +
+			"- foo_string: test 2\n" +
+			"  foo_int: 2\n" +
+			"- foo_string: test 3\n" +
+			"  foo_int: 3\n"))
+
+		output, err = ioutil.ReadFile("./test/output-Baz-sequence.yaml")
+		Expect(err).To(BeNil())
+		var baz []map[string]interface{}
+		Expect(yaml.Unmarshal(output, &baz)).To(BeNil())
+		Expect(baz).To(Equal([]map[string]interface{}{{
+			"baz_string": "test 1", "baz_bool": true, "baz_float32": 1.234, "baz_float64": 9.876,
+			"baz_int": -12345, "baz_int8": -8, "baz_int16": -16, "baz_int32": -32, "baz_int64": -64,
+			"baz_uint": 12345, "baz_uint8": 8, "baz_uint16": 16, "baz_uint32": 32, "baz_uint64": 64,
+		}}))
+
+		Expect("./test/output-Qux-sequence.yaml").ToNot(BeAnExistingFile())
+	})
+
+	It("should write a document for each record when Documents is set", func() {
+		w := peanut.NewYAMLWriter("./test/output-", "-documents")
+		w.Documents = true
+		for _, x := range testOutputFoo {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		Expect(w.Close()).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Foo-documents.yaml")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("" +
+			"foo_string: test 1\n" +
+			"foo_int: 1\n" +
+			"---\n" +
+			"foo_string: test 2\n" +
+			"foo_int: 2\n" +
+			"---\n" +
+			"foo_string: test 3\n" +
+			"foo_int: 3\n"))
+	})
+
+	It("should write times, durations and nulls", func() {
+		w := peanut.NewYAMLWriter("./test/output-", "-times")
+
+		testWritesTimesAndClose(w)
+
+		output, err := ioutil.ReadFile("./test/output-Times-times.yaml")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("" +
+			"- id: t1\n" +
+			"  time: 2021-04-19T13:45:30Z\n" +
+			"  date: \"2021-04-19\"\n" +
+			"  duration: 1h30m0s\n"))
+
+		var times []map[string]interface{}
+		Expect(yaml.Unmarshal(output, &times)).To(BeNil())
+		Expect(times[0]["time"]).To(Equal(testOutputTimes[0].Time))
+		Expect(times[0]["date"]).To(Equal("2021-04-19"))
+
+		w = peanut.NewYAMLWriter("./test/output-", "-nullable")
+
+		testWritesNullableAndClose(w)
+
+		output, err = ioutil.ReadFile("./test/output-Nullable-nullable.yaml")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(HaveSuffix("" +
+			"- id: n2\n" +
+			"  int_ptr: null\n" +
+			"  string_ptr: null\n" +
+			"  null_string: null\n" +
+			"  null_int64: null\n" +
+			"  null_time: null\n" +
+			"  null_float: null\n"))
+	})
+
+	It("should quote strings that would otherwise be read as other types", func() {
+		w := peanut.NewYAMLWriter("./test/output-", "-quoting")
+		tests := []*Escaping{
+			{Text: "yes", Float: 1},
+			{Text: "123", Float: 1e21},
+			{Text: "null", Float: -0.5},
+			{Text: "a: b\n# c"},
+			{Text: "\n"},
+			{Text: "\r\n"},
+		}
+		for _, x := range tests {
+			Expect(w.Write(x)).To(BeNil())
+		}
+		Expect(w.Close()).To(BeNil())
+
+		output, err := ioutil.ReadFile("./test/output-Escaping-quoting.yaml")
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(ContainSubstring("- text: \"yes\"\n  float: 1.0\n"))
+
+		var records []map[string]interface{}
+		Expect(yaml.Unmarshal(output, &records)).To(BeNil())
+		for i, r := range records {
+			Expect(r["text"]).To(Equal(tests[i].Text))
+			Expect(r["float"]).To(BeEquivalentTo(tests[i].Float))
+		}
+	})
+
+	It("should not write anything when structs are written and cancel is called", func() {
+		w := peanut.NewYAMLWriter("./test/output-", "-cancel")
+
+		testWritesAndCancel(w)
+
+		Expect("./test/output-Foo-cancel.yaml").ToNot(BeAnExistingFile())
+		Expect("./test/output-Bar-cancel.yaml").ToNot(BeAnExistingFile())
+	})
+
+	It("should return an error when Write is called after Close", func() {
+		w := peanut.NewYAMLWriter("./test/output-", "-close-write")
+
+		testWriteAfterClose(w)
+	})
+
+	Context("when given a struct with an unsupported field type", func() {
+
+		It("should return an error with an informative message", func() {
+			w := peanut.NewYAMLWriter("./no-such-location/output-bogus-", "")
+
+			testWriteBadType(w)
+		})
+	})
+})